@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"ecohead/phptooling"
+	"ecohead/phptooling/internal/wizard"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/telemetry"
+	"ecohead/phptooling/pkg/tools"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	command := "install"
+	args := os.Args[1:]
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	cfg := config.Default()
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&cfg.Templates.Source, "templates", os.Getenv("PHPTOOLING_TEMPLATES"), "git repository or .tar.gz URL containing config templates, a ref can be appended after # (e.g. https://github.com/org/templates.git#v1.2.0)")
+	flags.BoolVar(&cfg.Templates.Refresh, "refresh-templates", false, "fetch the remote templates again instead of using the cached ones")
+	flags.StringVar(&cfg.Templates.Sha256, "templates-sha256", os.Getenv("PHPTOOLING_TEMPLATES_SHA256"), "expected SHA-256 of the templates archive, the installation stops when it differs")
+	flags.Func("templates-signing-key", "id of a GPG key allowed to sign the templates archive (<URL>.asc) or the fetched commit, can be repeated", func(key string) error {
+		cfg.Templates.SigningKeys = append(cfg.Templates.SigningKeys, key)
+
+		return nil
+	})
+	flags.StringVar((*string)(&cfg.InstallMethod), "install-method", string(config.ComposerInstall), "composer, phar to download the tools having one instead of installing them with composer, phive, bin-plugin for vendor-bin namespaces of bamarni/composer-bin-plugin, or require-dev to require them in the project")
+	flags.Func("global-tool", "tool installed with composer global require and shared with your other projects (e.g. phpstan), can be repeated", func(tool string) error {
+		cfg.GlobalTools = append(cfg.GlobalTools, tools.Tool(tool))
+
+		return nil
+	})
+	flags.BoolVar(&cfg.Composer.IgnoreLocks, "ignore-tool-locks", false, "ignore the composer.lock of the tool directories in git, install-php then updates the tools to their latest versions, also set by composer.ignore_locks in "+config.FileName)
+	flags.IntVar(&cfg.Parallelism, "jobs", 1, "number of tools installed by composer at the same time, their output is then interleaved")
+	flags.IntVar(&cfg.Composer.ProcessTimeout, "composer-timeout", 0, "seconds composer waits for the processes it runs (e.g. git clones) before failing, 300 by default of composer, also set by composer.process_timeout in "+config.FileName)
+
+	// The report command runs the installed tools without wizard, on the host by default like in CI
+	if command == "report" {
+		flags.StringVar((*string)(&cfg.Report.Format), "format", string(config.SarifReport), "format of the report: sarif to merge the issues of the tools into one SARIF log, junit for a JUnit XML test suite per tool, html for a page summarising them, badges for shields.io endpoint files")
+		flags.BoolFunc("html", "same as --format=html", func(string) error {
+			cfg.Report.Format = config.HTMLReport
+
+			return nil
+		})
+		flags.StringVar(&cfg.Report.File, "report-file", "", "file of the project the report is written to, phptooling.sarif, phptooling.junit.xml or phptooling.html by default (directory of the badges, build/badges by default)")
+		flags.BoolVar(&cfg.Report.Upload, "upload", false, "upload the SARIF log to GitHub code scanning, from GitHub Actions with the security-events: write permission")
+		flags.BoolVar(&cfg.Report.FailOnIssues, "fail-on-issues", false, "exit with code 10 when blocking tools found issues, the tools set as advisory in the policy of "+config.FileName+" are only reported")
+		flags.BoolVar(&cfg.Report.Comment, "comment", false, "post a summary of the issues on the pull request (GitHub Actions, GITHUB_TOKEN needs the pull-requests: write permission) or merge request (GitLab CI, with an access token in GITLAB_TOKEN), updating the previous one")
+		flags.StringVar((*string)(&cfg.Environment), "environment", string(config.Local), "where the tools are run: local, docker-compose or ddev")
+		flags.StringVar(&cfg.DockerService, "docker-service", "", "docker compose service running the tools with --environment=docker-compose")
+	}
+
+	// Proposed by the wizard in a terminal, the answers must be given as flags without one (CI, pipes)
+	var answers wizard.Answers
+
+	if command == "install" || command == "hooks" {
+		flags.StringVar(&answers.Environment, "environment", "", "where PHP commands are run: local, docker-compose, ddev or kubernetes, detected by default")
+		flags.StringVar(&answers.DockerService, "docker-service", "", "docker compose service running PHP commands with --environment=docker-compose")
+		flags.StringVar(&answers.KubernetesTarget, "kubernetes-target", "", "pod or resource owning pods (e.g. deploy/app) running PHP commands with --environment=kubernetes")
+		flags.StringVar(&answers.ToolsDirectory, "tools-directory", "", "directory the tools are installed in, ./tools by default")
+		flags.StringVar(&answers.HookManager, "hook-manager", "", "native, versioned, lefthook or husky")
+		flags.StringVar(&answers.PreCommit, "pre-commit", "", "comma separated checks run on staged files before each commit: php-lint and the installed tools checking them")
+		flags.StringVar(&answers.PrePush, "pre-push", "", "comma separated checks run on the whole project before each push: phpunit and the installed tools checking it")
+		flags.BoolVar(&answers.CommitMsg, "commit-msg", false, "validate commit messages against conventional commits")
+		flags.BoolVar(&answers.AutoFix, "auto-fix", false, "fix and re-stage style issues before each commit instead of failing it")
+	}
+
+	if command == "install" {
+		flags.StringVar(&answers.Tools, "tools", "", "comma separated tools to install (e.g. phpstan,phpcs), required without terminal")
+		flags.StringVar(&answers.JustFile, "justfile", "", "file the recipes are appended to: justfile, or "+config.ImportedJustFile+" imported by the justfile, recipes already defined are left out")
+		flags.StringVar(&answers.Framework, "framework", "", "symfony, laravel, wordpress, drupal or none, detected by default")
+		flags.StringVar(&answers.Paths, "paths", "", "comma separated directories analysed, globs like modules/* are expanded, detected by default")
+		flags.StringVar(&answers.Installed, "installed", "", "what to do with the selected tools already installed in the tools directory: update (by default), reinstall the versions of their lock or skip them")
+		flags.StringVar(&answers.Outputs, "outputs", "", "comma separated additional files: github-composite-action, github-diff-workflow, git-hooks, editorconfig, phpstorm, vscode, neovim, language-server or coverage")
+	}
+
+	var logOptions logging.Options
+	flags.BoolVar(&logOptions.Verbose, "verbose", false, "show debug messages, the duration and the error output of every command")
+	flags.BoolVar(&logOptions.Quiet, "quiet", false, "skip the wizard (the answers are given as flags) and only show warnings and errors, followed by a result line such as \"result=failed command=install exit_code=8 error=...\"")
+	flags.StringVar(&logOptions.File, "log-file", "", "append every message and command output to this file as JSON lines")
+
+	var output string
+	flags.StringVar(&output, "output", "text", "text, or json to write the events of the run (commands run, files written, tools installed, result) to stdout as JSON lines, messages then go to stderr")
+
+	var theme string
+	flags.StringVar(&theme, "theme", "", "theme of the wizard: catppuccin, dracula or plain, catppuccin by default and plain when colors are disabled by NO_COLOR or TERM=dumb")
+
+	var noTelemetry bool
+	flags.BoolVar(&noTelemetry, "no-telemetry", false, "never ask for nor send anonymous usage statistics (also disabled by DO_NOT_TRACK=1)")
+
+	// Errors are reported by the flag set, which exits with code 2
+	flags.Parse(args)
+	logOptions.Json = output == "json"
+
+	if output != "text" && output != "json" {
+		fmt.Fprintln(os.Stderr, "Error: unknown output "+output+", expected text or json")
+		os.Exit(2)
+	}
+
+	themeErr := wizard.SetTheme(theme)
+
+	if themeErr != nil {
+		fmt.Fprintln(os.Stderr, "Error:", themeErr)
+		os.Exit(2)
+	}
+
+	closeLog, logErr := logging.Setup(logOptions)
+
+	if logErr != nil {
+		fmt.Fprintln(os.Stderr, "Error:", logErr)
+		os.Exit(failure.ExitCode(logErr))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-interrupts
+		// A second Ctrl+C quits immediately
+		signal.Stop(interrupts)
+		slog.Warn("Interrupted, stopping after the current command (press Ctrl+C again to quit immediately)")
+		cancel()
+	}()
+
+	telemetryAllowed := !noTelemetry && telemetry.Allowed()
+	err := run(ctx, command, cfg, answers, telemetryAllowed)
+	cancel()
+
+	if err != nil {
+		slog.Error(err.Error(), "exit_code", failure.ExitCode(err))
+	}
+
+	if err != nil {
+		logging.Event("result", "command", command, "exit_code", failure.ExitCode(err), "error", err.Error())
+	} else {
+		logging.Event("result", "command", command, "exit_code", 0)
+	}
+
+	// The events already end with the result
+	if logOptions.Quiet && !logOptions.Json {
+		printResult(command, err)
+	}
+
+	if telemetryAllowed {
+		sendTelemetry(command, cfg, err)
+	}
+
+	closeLog()
+	os.Exit(failure.ExitCode(err))
+}
+
+func run(ctx context.Context, command string, cfg *config.Config, answers wizard.Answers, askTelemetry bool) error {
+	pluginErr := tools.LoadPlugins(tools.PluginDirectories(runner.LocalWorkingDirectory()))
+
+	if pluginErr != nil {
+		return pluginErr
+	}
+
+	// After the plugins, hooks may refer to the tools they define
+	fileErr := cfg.LoadFile(runner.LocalWorkingDirectory())
+
+	if fileErr != nil {
+		return fileErr
+	}
+
+	if cfg.InstallMethod != config.ComposerInstall && cfg.InstallMethod != config.PharInstall && cfg.InstallMethod != config.PhiveInstall && cfg.InstallMethod != config.BinPluginInstall && cfg.InstallMethod != config.RequireDevInstall {
+		return failure.New(failure.Configuration, "run", "unknown install method "+string(cfg.InstallMethod)+", expected composer, phar, phive, bin-plugin or require-dev")
+	}
+
+	for _, tool := range cfg.GlobalTools {
+		if _, found := tools.Get(tool); !found {
+			return failure.New(failure.Configuration, "run", "unknown tool "+string(tool)+" given to --global-tool")
+		}
+	}
+
+	switch command {
+	case "install":
+		return runInstallCommand(ctx, cfg, answers, askTelemetry)
+	case "hooks":
+		return runHooksCommand(ctx, cfg, answers)
+	case "restore":
+		return phptooling.Restore(ctx)
+	case "undo":
+		return phptooling.Undo(ctx)
+	case "report":
+		return runReportCommand(ctx, cfg)
+	}
+
+	return failure.New(failure.Configuration, "run", "unknown command "+command)
+}
+
+func runInstallCommand(ctx context.Context, cfg *config.Config, answers wizard.Answers, askTelemetry bool) error {
+	detectErr := phptooling.Detect(cfg)
+
+	if detectErr != nil {
+		return detectErr
+	}
+
+	answersErr := answers.Apply(cfg)
+
+	if answersErr != nil {
+		return answersErr
+	}
+
+	// Before the wizard, which proposes the environment that can be used
+	environmentErr := wizard.CheckEnvironment(ctx, cfg)
+
+	if environmentErr != nil {
+		return environmentErr
+	}
+
+	// Without terminal, the form would fail or fill the output with escape sequences, scripts use the quiet mode
+	if !logging.IsInteractive() {
+		err := wizard.AnswerInstall(cfg, answers)
+
+		if err != nil {
+			return err
+		}
+
+		return phptooling.Install(ctx, cfg)
+	}
+
+	environment := cfg.Environment
+	err := wizard.RunInstall(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.Environment != environment {
+		environmentErr = wizard.CheckEnvironment(ctx, cfg)
+
+		if environmentErr != nil {
+			return environmentErr
+		}
+	}
+
+	if askTelemetry {
+		consentErr := wizard.AskTelemetryConsent()
+
+		if consentErr != nil {
+			return consentErr
+		}
+	}
+
+	return phptooling.Install(ctx, cfg)
+}
+
+func runHooksCommand(ctx context.Context, cfg *config.Config, answers wizard.Answers) error {
+	detectErr := phptooling.Detect(cfg)
+
+	if detectErr != nil {
+		return detectErr
+	}
+
+	answersErr := answers.Apply(cfg)
+
+	if answersErr != nil {
+		return answersErr
+	}
+
+	// Before the wizard, which proposes the environment that can be used
+	environmentErr := wizard.CheckEnvironment(ctx, cfg)
+
+	if environmentErr != nil {
+		return environmentErr
+	}
+
+	var err error
+	environment := cfg.Environment
+
+	if logging.IsInteractive() {
+		err = wizard.RunHooks(cfg)
+	} else {
+		err = wizard.AnswerHooks(cfg, answers)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if cfg.Environment != environment {
+		environmentErr = wizard.CheckEnvironment(ctx, cfg)
+
+		if environmentErr != nil {
+			return environmentErr
+		}
+	}
+
+	return phptooling.InstallHooks(ctx, cfg)
+}
+
+func runReportCommand(ctx context.Context, cfg *config.Config) error {
+	defaultFile, found := config.ReportFiles[cfg.Report.Format]
+
+	if !found {
+		return failure.New(failure.Configuration, "report", "unknown report format "+string(cfg.Report.Format)+", expected sarif, junit, html or badges")
+	}
+
+	if cfg.Report.File == "" {
+		cfg.Report.File = defaultFile
+	}
+
+	if cfg.Report.Upload && cfg.Report.Format != config.SarifReport {
+		return failure.New(failure.Configuration, "report", "only SARIF logs can be uploaded to GitHub code scanning")
+	}
+
+	if cfg.Environment != config.Local && cfg.Environment != config.DockerCompose && cfg.Environment != config.Ddev {
+		return failure.New(failure.Configuration, "report", "unknown environment "+string(cfg.Environment)+", expected local, docker-compose or ddev")
+	}
+
+	if cfg.Environment == config.DockerCompose && cfg.DockerService == "" {
+		return failure.New(failure.Configuration, "report", "--docker-service is required with --environment=docker-compose")
+	}
+
+	// Only the analysed paths are detected, the environment is given by the flags
+	environment := cfg.Environment
+	detectErr := phptooling.Detect(cfg)
+	cfg.Environment = environment
+
+	if detectErr != nil {
+		return detectErr
+	}
+
+	err := phptooling.Report(ctx, cfg)
+
+	// No wizard shows the output of the tool which failed
+	if output := failure.OutputOf(err); output != "" {
+		fmt.Fprintln(os.Stderr, output)
+	}
+
+	return err
+}
+
+/**
+ * Print the result of the command as one line of key=value pairs for the scripts running it, the error is quoted
+ */
+func printResult(command string, err error) {
+	if err == nil {
+		fmt.Println("result=succeeded command=" + command + " exit_code=0")
+		return
+	}
+
+	fmt.Println("result=failed command=" + command + " exit_code=" + strconv.Itoa(failure.ExitCode(err)) + " error=" + strconv.Quote(err.Error()))
+}
+
+/**
+ * Send the anonymous usage statistics of the command when the user accepted it, failures are only logged
+ */
+func sendTelemetry(command string, cfg *config.Config, err error) {
+	consent, _ := telemetry.ReadConsent()
+
+	if !consent.Enabled || (command != "install" && command != "hooks") {
+		return
+	}
+
+	// The context of the command is cancelled at this point
+	sendErr := telemetry.Send(context.Background(), telemetry.NewEvent(command, cfg, failure.ExitCode(err)))
+
+	if sendErr != nil {
+		slog.Debug("Could not send the telemetry", "error", sendErr)
+	}
+}
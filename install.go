@@ -0,0 +1,702 @@
+package phptooling
+
+import (
+	"bytes"
+	"cmp"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/generator"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/pipeline"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/json"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecipeData holds the variables available in the recipe templates of the registry, the paths of the tools directory
+// and of the binaries are quoted for the shell when needed
+type RecipeData struct {
+	PhpAlias       string
+	ComposerAlias  string
+	ToolsDirectory string
+	Binary         string
+	// Binary of the fix settings of the tool, empty when it has none
+	FixBinary string
+	Paths     []string
+}
+
+// Number of steps listed by the summary with their duration
+const slowestSteps = 5
+
+// Additional attempts of composer require, which mostly fails on network issues
+const composerRetries = 1
+
+/**
+ * Return the steps of the installation, named to tell what was completed when it stops. Only the composer require of
+ * tools run concurrently, the other steps touch the files of the project one at a time: the configuration of each
+ * tool waits for the previous one so that recipes are appended in the order of the Config. Hooks of the project file
+ * run first, after their tool and last.
+ */
+func getInstallSteps(g *generator.Generator) []pipeline.Step {
+	steps := []pipeline.Step{
+		{Name: "justfile", Run: g.InitializeJustFile},
+		{Name: "cache directory", Run: func() error {
+			_, err := g.CreateDirectory(g.Config.CacheDirectory)
+
+			return err
+		}},
+	}
+
+	// Tools required by the project have no directory of their own
+	if !g.Config.UsesRequireDev() {
+		steps = append(steps, pipeline.Step{Name: "tools directory", Run: func() error {
+			if g.Config.WipeToolsDirectory {
+				wipeErr := g.WipeDirectory(g.Config.ToolsDirectory)
+
+				if wipeErr != nil {
+					return wipeErr
+				}
+			}
+
+			_, err := g.CreateDirectory(g.Config.ToolsDirectory)
+
+			return err
+		}})
+	}
+
+	for _, tool := range g.Config.Tools {
+		if g.Config.UsesBinPlugin() && !g.Config.InstallsGlobally(tool) {
+			steps = append(steps, pipeline.Step{Name: "bin plugin", DependsOn: []string{"tools directory"}, Retries: composerRetries, Run: g.InstallBinPlugin})
+			break
+		}
+	}
+
+	for _, tool := range g.Config.Tools {
+		if g.Config.UsesPhive(tool) {
+			steps = append(steps, pipeline.Step{Name: "phive", DependsOn: []string{"tools directory"}, Retries: composerRetries, Run: g.InstallPhive})
+			break
+		}
+	}
+
+	toolSteps := []string{"justfile"}
+
+	for _, tool := range g.Config.Tools {
+		definition, found := tools.Get(tool)
+
+		if !found {
+			continue
+		}
+
+		if g.Config.ToolActions[tool] == config.SkipTool {
+			slog.Info("Skipping "+definition.Name+", already installed", "tool", tool)
+			continue
+		}
+
+		if g.Config.InstallsGlobally(tool) {
+			steps = append(steps, getGlobalSteps(g, definition, toolSteps[len(toolSteps)-1])...)
+		} else if g.Config.UsesRequireDev() {
+			steps = append(steps, getRequireDevSteps(g, definition, toolSteps[len(toolSteps)-1])...)
+		} else if g.Config.UsesPhive(tool) {
+			steps = append(steps, getPhiveSteps(g, definition, toolSteps[len(toolSteps)-1])...)
+		} else if g.Config.UsesPhar(tool) {
+			steps = append(steps, getPharSteps(g, definition, toolSteps[len(toolSteps)-1])...)
+		} else {
+			steps = append(steps, getComposerSteps(g, definition, toolSteps[len(toolSteps)-1])...)
+		}
+
+		toolSteps = append(toolSteps, definition.Name)
+
+		if commands := g.Config.Scripts.AfterTool[tool]; len(commands) > 0 {
+			hookStep := definition.Name + " after_tool hook"
+			steps = append(steps, pipeline.Step{Name: hookStep, DependsOn: []string{definition.Name}, Run: func() error {
+				return runHookCommands(g, commands, tool)
+			}})
+			toolSteps = append(toolSteps, hookStep)
+		}
+	}
+
+	steps = append(steps,
+		pipeline.Step{Name: ".gitignore", Run: g.UpdateGitIgnore},
+		// Hooks and recipes of the outputs run the installed tools
+		pipeline.Step{Name: "additional files", DependsOn: toolSteps, Run: g.GenerateOutputs},
+	)
+
+	if commands := g.Config.Scripts.BeforeInstall; len(commands) > 0 {
+		for i := range steps {
+			if len(steps[i].DependsOn) == 0 {
+				steps[i].DependsOn = []string{"before_install hook"}
+			}
+		}
+
+		steps = append([]pipeline.Step{{Name: "before_install hook", Run: func() error {
+			return runHookCommands(g, commands, "")
+		}}}, steps...)
+	}
+
+	if commands := g.Config.Scripts.AfterAll; len(commands) > 0 {
+		var names []string
+
+		for _, step := range steps {
+			names = append(names, step.Name)
+		}
+
+		steps = append(steps, pipeline.Step{Name: "after_all hook", DependsOn: names, Run: func() error {
+			return runHookCommands(g, commands, "")
+		}})
+	}
+
+	return steps
+}
+
+/**
+ * Return the steps installing the tool with composer, named after it once configured after the previous step
+ */
+func getComposerSteps(g *generator.Generator, definition tools.Definition, previousStep string) []pipeline.Step {
+	var dir string
+	packages := definition.PackageNames(string(g.Config.Framework), g.Config.PhpCS.Standard)
+	requirements := g.Config.Requirements(definition)
+	packagesDependencies := []string{definition.Name + " directory"}
+
+	if g.Config.UsesBinPlugin() {
+		packagesDependencies = append(packagesDependencies, "bin plugin")
+	}
+
+	return []pipeline.Step{
+		{Name: definition.Name + " directory", DependsOn: []string{"tools directory"}, Run: func() error {
+			var err error
+			dir, err = g.CreateToolDirectory(string(definition.Id))
+
+			if err != nil {
+				return err
+			}
+
+			return prepareToolComposerFile(g, dir)
+		}},
+		{
+			Name:       definition.Name + " packages",
+			DependsOn:  packagesDependencies,
+			Retries:    composerRetries,
+			Concurrent: true,
+			Run: func() error {
+				if g.Config.ToolActions[definition.Id] == config.ReinstallTool {
+					return installToolPackages(g, dir, requirements...)
+				}
+
+				return requireToolPackages(g, dir, requirements...)
+			},
+		},
+		{Name: definition.Name, DependsOn: []string{definition.Name + " packages", previousStep}, Run: func() error {
+			recordErr := g.RecordTool(definition.Id, packages)
+
+			if recordErr != nil {
+				return recordErr
+			}
+
+			return configureTool(g, definition)
+		}},
+	}
+}
+
+/**
+ * Return the steps downloading the phar of the tool, the release recorded in the lock is downloaded again and checked
+ */
+func getPharSteps(g *generator.Generator, definition tools.Definition, previousStep string) []pipeline.Step {
+	var url, sha256 string
+	var data []byte
+
+	return []pipeline.Step{
+		{Name: definition.Name + " directory", DependsOn: []string{"tools directory"}, Run: func() error {
+			_, err := g.CreateToolDirectory(string(definition.Id))
+
+			if err != nil {
+				return err
+			}
+
+			url, sha256, err = g.PharSource(definition)
+
+			return err
+		}},
+		{Name: definition.Name + " phar", DependsOn: []string{definition.Name + " directory"}, Concurrent: true, Run: func() error {
+			var err error
+			data, url, err = g.DownloadPhar(url, sha256, definition.SigningKeys)
+
+			return err
+		}},
+		{Name: definition.Name, DependsOn: []string{definition.Name + " phar", previousStep}, Run: func() error {
+			writeErr := g.WritePhar(definition, data, url)
+
+			if writeErr != nil {
+				return writeErr
+			}
+
+			return configureTool(g, definition)
+		}},
+	}
+}
+
+/**
+ * Return the steps installing the tool with PHIVE, which is downloaded first if needed. PHIVE updates its
+ * configuration on each installation, so they don't run concurrently.
+ */
+func getPhiveSteps(g *generator.Generator, definition tools.Definition, previousStep string) []pipeline.Step {
+	return []pipeline.Step{
+		{Name: definition.Name + " phive", DependsOn: []string{"phive"}, Retries: composerRetries, Run: func() error {
+			return g.InstallWithPhive(definition)
+		}},
+		{Name: definition.Name, DependsOn: []string{definition.Name + " phive", previousStep}, Run: func() error {
+			recordErr := g.RecordPhive(definition)
+
+			if recordErr != nil {
+				return recordErr
+			}
+
+			return configureTool(g, definition)
+		}},
+	}
+}
+
+/**
+ * Return the steps installing the tool with composer global require, which don't run concurrently as they update the
+ * same global composer.json
+ */
+func getGlobalSteps(g *generator.Generator, definition tools.Definition, previousStep string) []pipeline.Step {
+	packages := definition.PackageNames(string(g.Config.Framework), g.Config.PhpCS.Standard)
+
+	return []pipeline.Step{
+		{Name: definition.Name + " global packages", Retries: composerRetries, Run: func() error {
+			return g.InstallGlobally(definition)
+		}},
+		{Name: definition.Name, DependsOn: []string{definition.Name + " global packages", previousStep}, Run: func() error {
+			recordErr := g.RecordGlobal(definition.Id, packages)
+
+			if recordErr != nil {
+				return recordErr
+			}
+
+			return configureTool(g, definition)
+		}},
+	}
+}
+
+/**
+ * Return the steps requiring the tool in the project, which don't run concurrently as they update its composer.json
+ */
+func getRequireDevSteps(g *generator.Generator, definition tools.Definition, previousStep string) []pipeline.Step {
+	packages := definition.PackageNames(string(g.Config.Framework), g.Config.PhpCS.Standard)
+
+	return []pipeline.Step{
+		{Name: definition.Name + " packages", Retries: composerRetries, Run: func() error {
+			return g.RequireInProject(definition)
+		}},
+		{Name: definition.Name, DependsOn: []string{definition.Name + " packages", previousStep}, Run: func() error {
+			recordErr := g.RecordRequireDev(definition.Id, packages)
+
+			if recordErr != nil {
+				return recordErr
+			}
+
+			return configureTool(g, definition)
+		}},
+	}
+}
+
+/**
+ * Run the commands of a hook of the project file on the host, one shell each. The environment tells how to run
+ * commands where PHP runs, e.g. "$PHPTOOLING_PHP vendor/bin/phpunit", and which tool was installed for after_tool.
+ */
+func runHookCommands(g *generator.Generator, commands []string, tool tools.Tool) error {
+	prefix := g.Runner.NonInteractivePrefix()
+	env := []string{
+		"PHPTOOLING_PREFIX=" + prefix,
+		"PHPTOOLING_PHP=" + strings.TrimSpace(prefix+" php"),
+		"PHPTOOLING_COMPOSER=" + strings.TrimSpace(prefix+" composer"),
+		"PHPTOOLING_TOOLS_DIRECTORY=" + g.RelativeToolsDirectory(),
+		"PHPTOOLING_TOOL=" + string(tool),
+	}
+
+	for _, command := range commands {
+		err := g.RunScript(command, env)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Configure the installed tool following its registry definition: recipe, config files and baseline
+ */
+func configureTool(g *generator.Generator, definition tools.Definition) error {
+	if definition.Recipe != "" {
+		recipeErr := addRecipe(g, definition, string(definition.Id), definition.Recipe)
+
+		if recipeErr != nil {
+			return recipeErr
+		}
+	}
+
+	if definition.CI != nil {
+		ciRecipeErr := addRecipe(g, definition, string(definition.Id)+"-ci", getCIRecipe(definition))
+
+		if ciRecipeErr != nil {
+			return ciRecipeErr
+		}
+	}
+
+	for _, file := range definition.ConfigFiles(string(g.Config.Framework)) {
+		copyErr := g.CopyTemplate(file.Template, file.Destination)
+
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+
+	if definition.Baseline != nil && isBaselineEnabled(g, definition.Id) {
+		return generateBaseline(g, definition)
+	}
+
+	return nil
+}
+
+/**
+ * Back up the composer files of the tool directory and set the PHP version of the project as platform, so that
+ * composer resolves compatible versions. The minimum stability of the tool lets its dependencies be pre-releases too,
+ * and the repositories of the Config are used along with (or instead of) packagist.org.
+ */
+func prepareToolComposerFile(g *generator.Generator, dir string) error {
+	// Restored on rollback, the vendor/ directory of a tool installed by a previous run is then outdated until
+	// "just install-php" is run
+	for _, file := range []string{"composer.json", "composer.lock"} {
+		backupErr := g.BackupFile(path.Join(g.Config.ToolsDirectory, path.Base(dir), file))
+
+		if backupErr != nil {
+			return backupErr
+		}
+	}
+
+	composerFile := path.Join(g.Config.ToolsDirectory, path.Base(dir), "composer.json")
+	composerJson := make(map[string]interface{})
+	data, readErr := g.Files.ReadFile(composerFile)
+
+	if readErr == nil {
+		parseErr := json.Unmarshal(data, &composerJson)
+
+		if parseErr != nil {
+			return failure.Wrap(failure.Environment, "parse "+composerFile, parseErr)
+		}
+	} else if g.Config.PhpVersion != "" {
+		composerJson["config"] = map[string]interface{}{"platform": map[string]interface{}{"php": g.Config.PhpVersion}}
+	}
+
+	previous, _ := encodeComposerJson(composerJson)
+	delete(composerJson, "minimum-stability")
+	delete(composerJson, "repositories")
+
+	if stability := g.Config.MinimumStability(tools.Tool(path.Base(dir))); stability != "" {
+		composerJson["minimum-stability"] = stability
+	}
+
+	if repositories := getComposerRepositories(g); len(repositories) > 0 {
+		composerJson["repositories"] = repositories
+	}
+	content, encodeErr := encodeComposerJson(composerJson)
+
+	if encodeErr != nil {
+		return failure.Wrap(failure.Environment, "write "+composerFile, encodeErr)
+	}
+
+	// Existing files are only rewritten when the keys managed here change, and nothing is written for an empty one
+	if (readErr == nil && content == previous) || (readErr != nil && len(composerJson) == 0) {
+		return nil
+	}
+
+	return g.WriteFile(composerFile, content)
+}
+
+/**
+ * Return the composer repositories of the Config as written in composer.json, packagist.org comes after them unless
+ * disabled
+ */
+func getComposerRepositories(g *generator.Generator) []interface{} {
+	var repositories []interface{}
+
+	for _, repository := range g.Config.Composer.Repositories {
+		repositories = append(repositories, repository)
+	}
+
+	if g.Config.Composer.DisablePackagist {
+		repositories = append(repositories, map[string]bool{"packagist.org": false})
+	}
+
+	return repositories
+}
+
+func encodeComposerJson(composerJson map[string]interface{}) (string, error) {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	// Constraints such as >=1.0 are kept readable
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	err := encoder.Encode(composerJson)
+
+	return content.String(), err
+}
+
+/**
+ * Install the packages in the tool directory, only running composer so that tools can be installed concurrently.
+ * With bamarni/composer-bin-plugin, the directory is the namespace of the tool.
+ */
+func requireToolPackages(g *generator.Generator, dir string, packages ...string) error {
+	if g.Config.UsesBinPlugin() {
+		return g.Run(append(append([]string{"composer", "bin", path.Base(dir), "require", "--dev"}, packages...), "--with-all-dependencies"))
+	}
+
+	return g.Run(append(append([]string{"composer", "require", "--dev"}, packages...), "--with-all-dependencies", "--working-dir", dir))
+}
+
+/**
+ * Install the packages of the composer.lock of the tool directory again, which are required without one
+ */
+func installToolPackages(g *generator.Generator, dir string, packages ...string) error {
+	_, statErr := g.Files.Stat(path.Join(g.Config.ToolsDirectory, path.Base(dir), "composer.lock"))
+
+	if statErr != nil {
+		return requireToolPackages(g, dir, packages...)
+	}
+
+	if g.Config.UsesBinPlugin() {
+		return g.Run([]string{"composer", "bin", path.Base(dir), "install"})
+	}
+
+	return g.Run([]string{"composer", "install", "--working-dir", dir})
+}
+
+/**
+ * Append the rendered recipe template of the tool to the justfile
+ */
+func addRecipe(g *generator.Generator, definition tools.Definition, name string, recipe string) error {
+	return g.AddToJustFile(name, func(composerAlias string, phpAlias string, toolsDir string) (string, error) {
+		data := RecipeData{
+			PhpAlias:       phpAlias,
+			ComposerAlias:  composerAlias,
+			ToolsDirectory: runner.QuoteArgument(toolsDir),
+			Binary:         g.JustFileBinary(definition.Id, g.Config.Binary(definition.Id), toolsDir),
+			Paths:          g.Config.Paths,
+		}
+
+		if definition.Fix != nil && definition.Fix.Binary != "" {
+			data.FixBinary = g.JustFileBinary(definition.Id, definition.Fix.Binary, toolsDir)
+		}
+
+		rendered, err := tools.Render(recipe, data)
+
+		return "\n" + rendered, err
+	})
+}
+
+/**
+ * Return the recipe template running the tool with its CI arguments, e.g. phpstan-ci
+ */
+func getCIRecipe(definition tools.Definition) string {
+	description := "printing its issues as GitHub Actions annotations"
+
+	if definition.CI.Format == tools.CheckstyleFormat {
+		description = "printing a checkstyle report, e.g. for cs2pr in CI"
+	}
+
+	return `# Launch ` + definition.Name + ` ` + description + `
+` + string(definition.Id) + `-ci:
+    [[ .PhpAlias ]] [[ .Binary ]] ` + definition.CI.Arguments + `
+`
+}
+
+func isBaselineEnabled(g *generator.Generator, tool tools.Tool) bool {
+	switch tool {
+	case tools.PhpStan:
+		return g.Config.PhpStan.Baseline
+	case tools.Psalm:
+		return g.Config.Psalm.Baseline
+	}
+
+	return false
+}
+
+func generateBaseline(g *generator.Generator, definition tools.Definition) error {
+	baseline := definition.Baseline
+	_, err := g.Files.Stat(baseline.File)
+
+	if err != nil || !baseline.KeepExisting {
+		if baseline.InitialContent != "" {
+			// Not recorded in the lock, the tool writes the actual baseline
+			writeErr := g.WriteFile(baseline.File, baseline.InitialContent)
+
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+
+		binary, binaryErr := g.ToolBinary(definition.Id, g.Config.Binary(definition.Id))
+
+		if binaryErr != nil {
+			return binaryErr
+		}
+
+		runErr := g.Run(append([]string{"php", binary}, strings.Fields(baseline.Arguments)...))
+
+		if runErr != nil {
+			return runErr
+		}
+	}
+
+	if baseline.Recipe != "" {
+		return addRecipe(g, definition, string(definition.Id)+"-baseline", baseline.Recipe)
+	}
+
+	return nil
+}
+
+/**
+ * Print what the installation changed in the project followed by the commands to run next, the summary is also
+ * emitted as an event
+ */
+func printSummary(g *generator.Generator, durations map[string]time.Duration) {
+	summary := g.Summary()
+	logging.Event("summary", "created", summary.Created, "modified", summary.Modified, "recipes", summary.Recipes)
+
+	if !logging.OnConsole(slog.LevelInfo) {
+		return
+	}
+
+	lines := []string{""}
+	lines = append(lines, getSummaryList("Created", summary.Created)...)
+	lines = append(lines, getSummaryList("Modified", summary.Modified)...)
+	lines = append(lines, getSummaryList("Recipes added to the justfile", summary.Recipes)...)
+	lines = append(lines, getSummaryList("Slowest steps", getSlowestSteps(durations))...)
+	var steps []string
+
+	if slices.Contains(summary.Recipes, "install-php") {
+		steps = append(steps, "just install-php to install the dependencies and the tools, e.g. after cloning the project")
+	}
+
+	var checks []string
+
+	// The first recipe of each tool runs its check
+	for _, tool := range g.Config.Tools {
+		definition, _ := tools.Get(tool)
+
+		if names := generator.RecipeNames(definition.Recipe); len(names) > 0 && slices.Contains(summary.Recipes, names[0]) {
+			checks = append(checks, "just "+names[0])
+		}
+	}
+
+	for _, recipe := range []string{"qa-diff", "coverage"} {
+		if slices.Contains(summary.Recipes, recipe) {
+			checks = append(checks, "just "+recipe)
+		}
+	}
+
+	if len(checks) > 0 {
+		steps = append(steps, strings.Join(checks, ", ")+" to run the checks")
+	}
+
+	if g.Config.HasOutput(config.GitHooks) && g.Config.Hooks.Manager == config.VersionedHooks {
+		steps = append(steps, "sh "+generator.VersionedHooksDirectory+"/bootstrap.sh, once by each member of the team, to enable the versioned git hooks")
+	}
+
+	// Git hooks and the result caches aren't versioned
+	changed := slices.DeleteFunc(slices.Concat(summary.Created, summary.Modified), func(file string) bool {
+		return strings.HasPrefix(file, ".git/") || strings.HasPrefix(g.RelativeCacheDirectory()+"/", strings.TrimSuffix(file, "/")+"/")
+	})
+
+	if len(changed) > 0 {
+		steps = append(steps, "git add "+strings.Join(changed, " ")+" && git commit to share the changes once reviewed")
+	}
+
+	lines = append(lines, "Next steps:")
+
+	for i, step := range steps {
+		lines = append(lines, "  "+strconv.Itoa(i+1)+". "+step)
+	}
+
+	for _, line := range lines {
+		logging.Println(line)
+	}
+}
+
+/**
+ * Warn about the files the generated recipes refer to which don't exist, e.g. a binary outside of the directory its
+ * tool was installed in. The installation isn't failed as the tools are installed.
+ */
+func reportInconsistentRecipes(g *generator.Generator) {
+	problems, err := g.ValidateRecipes()
+
+	if err != nil {
+		slog.Warn("Could not check the generated recipes", "error", err)
+		return
+	}
+
+	for _, problem := range problems {
+		slog.Warn("Inconsistent recipes: " + problem)
+		logging.Event("validation", "problem", problem)
+	}
+}
+
+/**
+ * Return the steps which took the longest with their duration and share of the total, the steps run concurrently are
+ * counted separately
+ */
+func getSlowestSteps(durations map[string]time.Duration) []string {
+	var names []string
+	var total time.Duration
+
+	for name, duration := range durations {
+		total += duration
+
+		// The steps writing files go unnoticed
+		if duration >= 100*time.Millisecond {
+			names = append(names, name)
+		}
+	}
+
+	if total < time.Second {
+		return nil
+	}
+
+	slices.SortFunc(names, func(a string, b string) int {
+		return cmp.Compare(durations[b], durations[a])
+	})
+
+	var lines []string
+
+	for _, name := range names[:min(len(names), slowestSteps)] {
+		share := strconv.Itoa(int(100*durations[name]/total)) + "%"
+		lines = append(lines, name+" "+durations[name].Round(100*time.Millisecond).String()+" ("+share+")")
+	}
+
+	return lines
+}
+
+/**
+ * Return the titled list of the summary, nothing when it is empty
+ */
+func getSummaryList(title string, items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+
+	lines := []string{title + ":"}
+
+	for _, item := range items {
+		lines = append(lines, "  "+item)
+	}
+
+	return append(lines, "")
+}
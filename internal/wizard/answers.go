@@ -0,0 +1,286 @@
+package wizard
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Answers holds the answers given as flags, proposed by the wizard and required when it doesn't run
+type Answers struct {
+	Environment      string
+	DockerService    string
+	KubernetesTarget string
+	ToolsDirectory   string
+	JustFile         string
+	Framework        string
+	// Comma separated lists
+	Paths       string
+	Tools       string
+	Outputs     string
+	HookManager string
+	PreCommit   string
+	PrePush     string
+	CommitMsg   bool
+	AutoFix     bool
+	// Action applied to every selected tool already installed
+	Installed string
+}
+
+// Level of PHPStan recommended by the wizard without baseline
+const recommendedPhpStanLevel = "5"
+
+/**
+ * Copy the answers given into the Config, the empty ones keep the detected or default values
+ */
+func (answers Answers) Apply(cfg *config.Config) error {
+	operation := "read the answers"
+
+	if answers.Environment != "" {
+		environment := config.Environment(answers.Environment)
+
+		if !slices.Contains([]config.Environment{config.Local, config.DockerCompose, config.Ddev, config.Kubernetes}, environment) {
+			return failure.New(failure.Configuration, operation, "unknown environment "+answers.Environment+", expected local, docker-compose, ddev or kubernetes")
+		}
+
+		cfg.Environment = environment
+	}
+
+	if answers.DockerService != "" {
+		cfg.DockerService = answers.DockerService
+	}
+
+	if answers.KubernetesTarget != "" {
+		cfg.Kubernetes.Target = answers.KubernetesTarget
+	}
+
+	if answers.ToolsDirectory != "" {
+		directory, directoryErr := ParseToolsDirectory(answers.ToolsDirectory)
+
+		if directoryErr != nil {
+			return failure.New(failure.Configuration, operation, directoryErr.Error()+", "+answers.ToolsDirectory+" given to --tools-directory")
+		}
+
+		cfg.ToolsDirectory = directory
+	}
+
+	if answers.JustFile != "" {
+		if answers.JustFile != "justfile" && answers.JustFile != config.ImportedJustFile {
+			return failure.New(failure.Configuration, operation, "unknown file "+answers.JustFile+" given to --justfile, expected justfile or "+config.ImportedJustFile)
+		}
+
+		cfg.JustFile = answers.JustFile
+	}
+
+	if answers.Framework != "" {
+		framework := config.Framework(answers.Framework)
+
+		if !slices.Contains([]config.Framework{config.Symfony, config.Laravel, config.WordPress, config.Drupal, config.NoFramework}, framework) {
+			return failure.New(failure.Configuration, operation, "unknown framework "+answers.Framework+", expected symfony, laravel, wordpress, drupal or none")
+		}
+
+		cfg.Framework = framework
+	}
+
+	if answers.Paths != "" {
+		cfg.Paths = ParsePaths(answers.Paths)
+	}
+
+	for _, tool := range splitList(answers.Tools) {
+		if _, found := tools.Get(tools.Tool(tool)); !found {
+			return failure.New(failure.Configuration, operation, "unknown tool "+tool+" given to --tools")
+		}
+
+		cfg.Tools = append(cfg.Tools, tools.Tool(tool))
+	}
+
+	var outputs []string
+
+	for _, option := range getOutputOptions(cfg) {
+		outputs = append(outputs, string(option.Value))
+	}
+
+	for _, output := range splitList(answers.Outputs) {
+		// Coverage is only proposed to projects with tests
+		if !slices.Contains(outputs, output) {
+			return failure.New(failure.Configuration, operation, "unknown output "+output+" given to --outputs, expected one of "+strings.Join(outputs, ", "))
+		}
+
+		cfg.Outputs = append(cfg.Outputs, config.Output(output))
+	}
+
+	if answers.HookManager != "" {
+		manager := config.HookManager(answers.HookManager)
+
+		if !slices.Contains([]config.HookManager{config.NativeHooks, config.VersionedHooks, config.Lefthook, config.Husky}, manager) {
+			return failure.New(failure.Configuration, operation, "unknown hook manager "+answers.HookManager+", expected native, versioned, lefthook or husky")
+		}
+
+		cfg.Hooks.Manager = manager
+	}
+
+	for _, tool := range splitList(answers.PreCommit) {
+		cfg.Hooks.PreCommit = append(cfg.Hooks.PreCommit, tools.Tool(tool))
+	}
+
+	for _, tool := range splitList(answers.PrePush) {
+		cfg.Hooks.PrePush = append(cfg.Hooks.PrePush, tools.Tool(tool))
+	}
+
+	if answers.Installed != "" {
+		action := config.ToolAction(answers.Installed)
+
+		// Applied by AnswerInstall to the tools it finds installed
+		if !slices.Contains([]config.ToolAction{config.UpdateTool, config.ReinstallTool, config.SkipTool}, action) {
+			return failure.New(failure.Configuration, operation, "unknown action "+answers.Installed+" given to --installed, expected update, reinstall or skip")
+		}
+	}
+
+	cfg.Hooks.CommitMsg = cfg.Hooks.CommitMsg || answers.CommitMsg
+	cfg.Hooks.AutoFix = cfg.Hooks.AutoFix || answers.AutoFix
+
+	return nil
+}
+
+/**
+ * Stand in for RunInstall when no question can be asked (see logging.IsInteractive): the answers must have been
+ * applied, the questions without answer get the recommended one and the missing required answers are reported
+ */
+func AnswerInstall(cfg *config.Config, answers Answers) error {
+	if len(cfg.Tools) == 0 {
+		return getMissingAnswerError("--tools")
+	}
+
+	environmentErr := checkEnvironmentAnswers(cfg)
+
+	if environmentErr != nil {
+		return environmentErr
+	}
+
+	if cfg.PhpStan.Level == "" {
+		cfg.PhpStan.Level = recommendedPhpStanLevel
+	}
+
+	cfg.GlobalTools = slices.DeleteFunc(cfg.GlobalTools, func(tool tools.Tool) bool {
+		return !cfg.IsToolSelected(tool)
+	})
+
+	// Both tools were asked for, they are installed
+	for _, overlap := range tools.FindOverlaps(cfg.Tools) {
+		slog.Warn(tools.Name(overlap.Tool)+" and "+tools.Name(overlap.Other)+" overlap", "guidance", overlap.Guidance)
+	}
+
+	if directory := getForeignToolsDirectory(cfg); directory != "" {
+		slog.Warn("The tools directory already exists and isn't empty, the tools are installed next to its content", "directory", directory)
+	}
+
+	cfg.ToolActions = make(map[tools.Tool]config.ToolAction)
+
+	if answers.Installed != "" {
+		for _, tool := range getSelectedInstalledTools(cfg) {
+			cfg.ToolActions[tool] = config.ToolAction(answers.Installed)
+		}
+	}
+
+	if cfg.HasOutput(config.GitHooks) {
+		return checkHooksAnswers(cfg)
+	}
+
+	return nil
+}
+
+/**
+ * Stand in for RunHooks when no question can be asked, the hooks run the tools recorded in the lock or found in the
+ * tools directory
+ */
+func AnswerHooks(cfg *config.Config, answers Answers) error {
+	projectLock, locked, lockErr := lock.Read(runner.LocalWorkingDirectory())
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	if locked && answers.ToolsDirectory == "" {
+		cfg.ToolsDirectory = projectLock.ToolsDirectory
+	}
+
+	environmentErr := checkEnvironmentAnswers(cfg)
+
+	if environmentErr != nil {
+		return environmentErr
+	}
+
+	if locked && path.Clean(cfg.ToolsDirectory) == projectLock.ToolsDirectory {
+		cfg.Tools = projectLock.InstalledTools()
+	} else {
+		cfg.Tools = tools.DetectInstalled(runner.LocalWorkingDirectory(), cfg.ToolsDirectory)
+	}
+
+	return checkHooksAnswers(cfg)
+}
+
+func checkEnvironmentAnswers(cfg *config.Config) error {
+	if cfg.Environment == config.DockerCompose && cfg.DockerService == "" {
+		return getMissingAnswerError("--docker-service (or --environment when PHP doesn't run in docker compose)")
+	}
+
+	if cfg.Environment == config.Kubernetes && cfg.Kubernetes.Target == "" {
+		return getMissingAnswerError("--kubernetes-target")
+	}
+
+	return nil
+}
+
+/**
+ * Check that hooks were asked for and that each of them can run in its hook, like the options of askHooks
+ */
+func checkHooksAnswers(cfg *config.Config) error {
+	if len(cfg.Hooks.PreCommit) == 0 && len(cfg.Hooks.PrePush) == 0 && !cfg.Hooks.CommitMsg {
+		return getMissingAnswerError("--pre-commit, --pre-push or --commit-msg")
+	}
+
+	for _, tool := range cfg.Hooks.PreCommit {
+		if tool != tools.PhpLint && !isHookTool(cfg, tool, tools.PreCommitHook) {
+			return failure.New(failure.Configuration, "read the answers", string(tool)+" can't run before each commit, expected php-lint or an installed tool checking staged files")
+		}
+	}
+
+	for _, tool := range cfg.Hooks.PrePush {
+		if tool != tools.PhpUnit && !isHookTool(cfg, tool, tools.PrePushHook) {
+			return failure.New(failure.Configuration, "read the answers", string(tool)+" can't run before each push, expected phpunit or an installed tool checking the whole project")
+		}
+	}
+
+	return nil
+}
+
+func isHookTool(cfg *config.Config, tool tools.Tool, hook string) bool {
+	definition, found := tools.Get(tool)
+
+	return found && definition.Hook == hook && cfg.IsToolSelected(tool)
+}
+
+func getMissingAnswerError(flags string) error {
+	return failure.New(failure.Configuration, "read the answers", "the wizard only runs in a terminal outside of quiet mode, "+flags+" must be given")
+}
+
+/**
+ * Return the values of a comma separated list
+ */
+func splitList(list string) []string {
+	var values []string
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,169 @@
+package wizard
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/generator"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/timing"
+	"ecohead/phptooling/pkg/tools"
+	"github.com/charmbracelet/huh"
+)
+
+/**
+ * Ask the questions of the hooks command until the answers are confirmed, the hooks run the tools recorded in the lock
+ * or found in the tools directory
+ */
+func RunHooks(cfg *config.Config) error {
+	projectLock, locked, lockErr := lock.Read(runner.LocalWorkingDirectory())
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	if locked {
+		cfg.ToolsDirectory = projectLock.ToolsDirectory
+	}
+
+	preview := false
+
+	for {
+		environmentGroups, environmentErr := getEnvironmentGroups(cfg)
+
+		if environmentErr != nil {
+			return environmentErr
+		}
+
+		groups := append(environmentGroups,
+			huh.NewGroup(
+				getSectionHeader(projectSection),
+				huh.NewInput().
+					Title("In which directory tooling is installed?").
+					Placeholder("./tools").
+					Validate(func(answer string) error {
+						_, err := ParseToolsDirectory(answer)
+
+						return err
+					}).
+					Value(&cfg.ToolsDirectory),
+			),
+			getPreviewGroup(projectSection, &preview),
+		)
+
+		err := huh.NewForm(groups...).WithTheme(theme()).Run()
+
+		if err != nil {
+			return wrapFormError(err)
+		}
+
+		// Validated by the form
+		cfg.ToolsDirectory, _ = ParseToolsDirectory(cfg.ToolsDirectory)
+
+		if locked && cfg.ToolsDirectory == projectLock.ToolsDirectory {
+			cfg.Tools = projectLock.InstalledTools()
+		} else {
+			// Tools installed before the lock was introduced
+			cfg.Tools = tools.DetectInstalled(runner.LocalWorkingDirectory(), cfg.ToolsDirectory)
+		}
+
+		cfg.ResolveConflict = ResolveConflict
+		cfg.ConfirmRollback = ConfirmRollback
+		cfg.PreviewChange = nil
+
+		if preview {
+			cfg.PreviewChange = PreviewChange
+		}
+
+		hooksErr := askHooks(cfg)
+
+		if hooksErr != nil {
+			return hooksErr
+		}
+
+		confirmed, confirmErr := confirmAnswers("Install the hooks with these answers?", "Install", getHooksSummary(cfg))
+
+		if confirmErr != nil || confirmed {
+			return confirmErr
+		}
+	}
+}
+
+func askHooks(cfg *config.Config) error {
+	hookManagerOptions := []huh.Option[config.HookManager]{
+		huh.NewOption("Native git hooks", config.NativeHooks),
+		huh.NewOption("Versioned hooks shared with the team ("+generator.VersionedHooksDirectory+"/ and core.hooksPath)", config.VersionedHooks),
+		huh.NewOption("Lefthook (lefthook.yml)", config.Lefthook),
+	}
+
+	nodePackage, packageErr := project.ReadNodePackage(runner.LocalWorkingDirectory())
+
+	if packageErr != nil {
+		return packageErr
+	}
+
+	if nodePackage.UsesHusky() {
+		// Hooks are already managed by husky, don't create a competing mechanism by default
+		cfg.Hooks.Manager = config.Husky
+		hookManagerOptions = append([]huh.Option[config.HookManager]{huh.NewOption("Husky (append to the existing .husky/ hooks)", config.Husky)}, hookManagerOptions...)
+	}
+
+	preCommitOptions := []huh.Option[tools.Tool]{huh.NewOption("PHP lint", tools.PhpLint)}
+
+	prePushOptions := []huh.Option[tools.Tool]{}
+	// Measured by the report command, on the whole project
+	history := timing.Read(runner.LocalWorkingDirectory())
+
+	for _, tool := range cfg.Tools {
+		definition, _ := tools.Get(tool)
+		label := tools.Name(tool) + formatEstimate(history.EstimateRun(tool))
+
+		switch definition.Hook {
+		case tools.PreCommitHook:
+			preCommitOptions = append(preCommitOptions, huh.NewOption(label, tool))
+		case tools.PrePushHook:
+			prePushOptions = append(prePushOptions, huh.NewOption(label, tool))
+		}
+	}
+
+	prePushOptions = append(prePushOptions, huh.NewOption("PHPUnit tests (vendor/bin/phpunit)", tools.PhpUnit))
+
+	err := huh.NewForm(
+		huh.NewGroup(
+			getSectionHeader(hooksSection),
+			huh.NewSelect[config.HookManager]().
+				Title("How do you want to manage git hooks?").
+				Options(hookManagerOptions...).
+				Value(&cfg.Hooks.Manager),
+			huh.NewMultiSelect[tools.Tool]().
+				Title("Which checks should run on staged files before each commit?").
+				Options(preCommitOptions...).
+				Value(&cfg.Hooks.PreCommit),
+			huh.NewMultiSelect[tools.Tool]().
+				Title("Which checks should run on the whole project before each push?").
+				Options(prePushOptions...).
+				Value(&cfg.Hooks.PrePush),
+			huh.NewConfirm().
+				Title("Do you want to validate commit messages against conventional commits?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&cfg.Hooks.CommitMsg),
+		),
+		huh.NewGroup(
+			getSectionHeader(hooksSection),
+			huh.NewConfirm().
+				Title("Should style issues be fixed and re-staged automatically instead of failing the commit?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&cfg.Hooks.AutoFix),
+		).WithHideFunc(func() bool {
+			return !cfg.Hooks.HasPreCommitFixer()
+		}),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	return nil
+}
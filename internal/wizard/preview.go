@@ -0,0 +1,131 @@
+package wizard
+
+import (
+	"ecohead/phptooling/pkg/logging"
+	"fmt"
+	"github.com/charmbracelet/huh"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+)
+
+// One change is previewed at a time, even when tools are installed in parallel
+var previewing sync.Mutex
+
+type previewAction string
+
+const (
+	acceptChange previewAction = "accept"
+	skipChange   previewAction = "skip"
+	editChange   previewAction = "edit"
+)
+
+/**
+ * Ask whether each change should be previewed, answered at the end of the section of the wizard
+ */
+func getPreviewGroup(section string, preview *bool) *huh.Group {
+	return huh.NewGroup(
+		getSectionHeader(section),
+		huh.NewConfirm().
+			Title("Do you want to review the changes of each file before it is written?").
+			Description("The diff is shown for every generated file and justfile block, to accept, skip or edit it").
+			Affirmative("Yes").
+			Negative("No").
+			Value(preview),
+	)
+}
+
+/**
+ * Show the diff of a change and ask whether to write it, skip it or edit it first in the editor of the user
+ */
+func PreviewChange(destination string, diff string, content string) (string, bool) {
+	previewing.Lock()
+	defer previewing.Unlock()
+	defer logging.PauseStatus()()
+	fmt.Println("Changes of " + destination + ":\n" + diff)
+
+	action := acceptChange
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[previewAction]().
+				Title("What do you want to do with the changes of "+destination+"?").
+				Options(
+					huh.NewOption("Accept, write them", acceptChange),
+					huh.NewOption("Skip, leave the file as it is", skipChange),
+					huh.NewOption("Edit them in $EDITOR before writing them", editChange),
+				).
+				Value(&action),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil || action == skipChange {
+		return content, false
+	}
+
+	if action == editChange {
+		edited, editErr := editContent(destination, content)
+
+		if editErr != nil {
+			slog.Warn("Could not edit the changes, writing them as proposed", "file", destination, "error", editErr)
+			return content, true
+		}
+
+		return edited, true
+	}
+
+	return content, true
+}
+
+/**
+ * Open the content in the editor of the user ($VISUAL, $EDITOR or vi), in a temporary file named like the destination
+ * for syntax highlighting
+ */
+func editContent(destination string, content string) (string, error) {
+	file, createErr := os.CreateTemp("", "phptooling-*-"+path.Base(destination))
+
+	if createErr != nil {
+		return "", createErr
+	}
+
+	defer os.Remove(file.Name())
+
+	_, writeErr := file.WriteString(content)
+
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+
+	if writeErr != nil {
+		return "", writeErr
+	}
+
+	editor := os.Getenv("VISUAL")
+
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may be given with its arguments, e.g. "code --wait"
+	arguments := append(strings.Fields(editor), file.Name())
+	command := exec.Command(arguments[0], arguments[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	runErr := command.Run()
+
+	if runErr != nil {
+		return "", runErr
+	}
+
+	edited, readErr := os.ReadFile(file.Name())
+
+	return string(edited), readErr
+}
@@ -0,0 +1,168 @@
+package wizard
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/tools"
+	"github.com/charmbracelet/huh"
+	"strings"
+)
+
+// Sections of the wizard, shown above their questions
+const (
+	projectSection  = "Project (1/4)"
+	toolsSection    = "Tools (2/4)"
+	rulesetsSection = "Rulesets (3/4)"
+	outputsSection  = "Outputs (4/4)"
+	hooksSection    = "Git hooks"
+)
+
+/**
+ * Return the title of a page of the wizard, skipped when moving through the questions (shift+tab goes back)
+ */
+func getSectionHeader(section string) *huh.Note {
+	return huh.NewNote().Title(section)
+}
+
+/**
+ * Show the summary of the answers and ask whether to go on with them, false to edit them again
+ */
+func confirmAnswers(title string, affirmative string, summary string) (bool, error) {
+	confirmed := true
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(title).
+				Description(summary).
+				Affirmative(affirmative).
+				Negative("Edit them").
+				Value(&confirmed),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return false, wrapFormError(err)
+	}
+
+	return confirmed, nil
+}
+
+/**
+ * Summarise the answers of the install wizard, one line by answer
+ */
+func getInstallSummary(cfg *config.Config) string {
+	lines := []string{
+		"Environment: " + getEnvironmentSummary(cfg),
+		"Framework: " + string(cfg.Framework),
+		"Tools directory: " + cfg.ToolsDirectory,
+		"Analysed paths: " + strings.Join(cfg.Paths, ", "),
+		"Tools: " + getToolsSummary(cfg.Tools),
+		"Install method: " + string(cfg.InstallMethod),
+	}
+
+	if installed := getInstalledSummary(cfg); installed != "" {
+		lines = append(lines, "Already installed: "+installed)
+	}
+
+	if !cfg.UsesRequireDev() {
+		lines = append(lines, "Composer locks of the tools: "+getToolLocksSummary(cfg))
+	}
+
+	if len(cfg.GlobalTools) > 0 {
+		lines = append(lines, "Installed with composer global: "+getToolsSummary(cfg.GlobalTools))
+	}
+
+	if cfg.WipeToolsDirectory {
+		lines = append(lines, "The existing tools directory is wiped")
+	}
+
+	outputs := make([]string, len(cfg.Outputs))
+
+	for i, output := range cfg.Outputs {
+		outputs[i] = string(output)
+	}
+
+	lines = append(lines, "Additional files: "+getListSummary(outputs), "Recipes written to: "+cfg.JustFile)
+
+	if cfg.HasOutput(config.GitHooks) {
+		lines = append(lines, getHooksLines(cfg)...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/**
+ * Summarise the answers of the hooks wizard, one line by answer
+ */
+func getHooksSummary(cfg *config.Config) string {
+	lines := []string{
+		"Environment: " + getEnvironmentSummary(cfg),
+		"Tools directory: " + cfg.ToolsDirectory,
+		"Installed tools: " + getToolsSummary(cfg.Tools),
+	}
+
+	return strings.Join(append(lines, getHooksLines(cfg)...), "\n")
+}
+
+func getHooksLines(cfg *config.Config) []string {
+	commitMsg := "no"
+
+	if cfg.Hooks.CommitMsg {
+		commitMsg = "conventional commits"
+	}
+
+	return []string{
+		"Hooks managed by: " + string(cfg.Hooks.Manager),
+		"Before each commit: " + getToolsSummary(cfg.Hooks.PreCommit),
+		"Before each push: " + getToolsSummary(cfg.Hooks.PrePush),
+		"Commit messages: " + commitMsg,
+	}
+}
+
+func getInstalledSummary(cfg *config.Config) string {
+	var actions []string
+
+	for _, tool := range cfg.Tools {
+		if action, found := cfg.ToolActions[tool]; found {
+			actions = append(actions, tools.Name(tool)+" ("+string(action)+")")
+		}
+	}
+
+	return strings.Join(actions, ", ")
+}
+
+func getToolLocksSummary(cfg *config.Config) string {
+	if cfg.Composer.IgnoreLocks {
+		return "ignored, install-php updates the tools"
+	}
+
+	return "committed"
+}
+
+func getEnvironmentSummary(cfg *config.Config) string {
+	switch cfg.Environment {
+	case config.DockerCompose:
+		return "docker compose " + cfg.DockerCommand + " in the " + cfg.DockerService + " service"
+	case config.Kubernetes:
+		return "kubectl exec in " + cfg.Kubernetes.Target
+	}
+
+	return string(cfg.Environment)
+}
+
+func getToolsSummary(selected []tools.Tool) string {
+	names := make([]string, len(selected))
+
+	for i, tool := range selected {
+		names[i] = tools.Name(tool)
+	}
+
+	return getListSummary(names)
+}
+
+func getListSummary(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,47 @@
+package wizard
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"os"
+)
+
+// Themes of the forms by name, plain has no colors
+var themes = map[string]func() *huh.Theme{
+	"catppuccin": huh.ThemeCatppuccin,
+	"dracula":    huh.ThemeDracula,
+	"plain":      huh.ThemeBase,
+}
+
+// Theme of the forms, set by SetTheme
+var theme = huh.ThemeCatppuccin
+
+/**
+ * Select the theme of the forms by name, catppuccin by default. Colors are disabled everywhere, along with the theme,
+ * when NO_COLOR is set (https://no-color.org) or the terminal is dumb.
+ */
+func SetTheme(name string) error {
+	noColor := os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+
+	if name == "" && noColor {
+		name = "plain"
+	} else if name == "" {
+		name = "catppuccin"
+	}
+
+	selected, found := themes[name]
+
+	if !found {
+		return failure.New(failure.Configuration, "select the theme", "unknown theme "+name+", expected catppuccin, dracula or plain")
+	}
+
+	theme = selected
+
+	if noColor || name == "plain" {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	return nil
+}
@@ -0,0 +1,1251 @@
+package wizard
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/generator"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/pipeline"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/telemetry"
+	"ecohead/phptooling/pkg/tools"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// installAnswers holds the answers of the install wizard which are parsed into the Config once it ends
+type installAnswers struct {
+	paths               string
+	versions            string
+	preReleaseTools     []tools.Tool
+	preReleaseStability string
+	preview             bool
+	// Opposite of the IgnoreLocks of the composer Config, asked as a yes/no question
+	commitToolLocks bool
+	// Recipes of the justfile of the project, asked where the recipes go when it has one
+	justFileRecipes []string
+	askJustFile     bool
+	// Tools found in the proposed tools directory when the wizard starts
+	installed []tools.Tool
+}
+
+// Styles of the hints following the labels of the options
+var (
+	dimmed      = lipgloss.NewStyle().Faint(true)
+	highlighted = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	warning     = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+// Number of compose services listed at once, more are scrolled and can be filtered
+const maxListedServices = 10
+
+/**
+ * Ask every question of the install command, the Config holds the proposed answers. The answers are summarised at
+ * the end, to be edited again until they are confirmed.
+ */
+func RunInstall(cfg *config.Config) error {
+	preReleaseTools, preReleaseStability := getPreReleaseAnswers(cfg.Stability)
+	answers := installAnswers{
+		paths:               strings.Join(cfg.Paths, ", "),
+		versions:            formatVersions(cfg.Versions),
+		preReleaseTools:     preReleaseTools,
+		preReleaseStability: preReleaseStability,
+		commitToolLocks:     !cfg.Composer.IgnoreLocks,
+	}
+	answers.justFileRecipes, answers.askJustFile = getForeignJustFile()
+	answers.installed = tools.DetectInstalled(runner.LocalWorkingDirectory(), cfg.ToolsDirectory)
+
+	// A rerun proposes the tools it finds, to be kept as they are or installed again
+	if len(cfg.Tools) == 0 {
+		cfg.Tools = slices.Clone(answers.installed)
+	}
+
+	if answers.askJustFile {
+		// Proposed as the existing recipes stay apart from the generated ones
+		cfg.JustFile = config.ImportedJustFile
+	}
+
+	for {
+		groups, groupsErr := getInstallGroups(cfg, &answers)
+
+		if groupsErr != nil {
+			return groupsErr
+		}
+
+		err := huh.NewForm(groups...).WithTheme(theme()).Run()
+
+		if err != nil {
+			return wrapFormError(err)
+		}
+
+		applyInstallAnswers(cfg, answers)
+
+		directoryErr := askForeignToolsDirectory(cfg)
+
+		if directoryErr != nil {
+			return directoryErr
+		}
+
+		overlapsErr := askOverlappingTools(cfg)
+
+		if overlapsErr != nil {
+			return overlapsErr
+		}
+
+		installedErr := askInstalledTools(cfg)
+
+		if installedErr != nil {
+			return installedErr
+		}
+
+		if cfg.HasOutput(config.GitHooks) {
+			hooksErr := askHooks(cfg)
+
+			if hooksErr != nil {
+				return hooksErr
+			}
+		}
+
+		confirmed, confirmErr := confirmAnswers("Install the tools with these answers?", "Install", getInstallSummary(cfg))
+
+		if confirmErr != nil || confirmed {
+			return confirmErr
+		}
+	}
+}
+
+/**
+ * Return the pages of the install wizard, grouped by section
+ */
+func getInstallGroups(cfg *config.Config, answers *installAnswers) ([]*huh.Group, error) {
+	toolOptions := make([]huh.Option[tools.Tool], len(tools.Available))
+
+	for i, tool := range tools.Available {
+		toolOptions[i] = huh.NewOption(tools.Name(tool), tool)
+	}
+
+	environmentGroups, environmentErr := getEnvironmentGroups(cfg)
+
+	if environmentErr != nil {
+		return nil, environmentErr
+	}
+
+	groups := append(environmentGroups,
+		huh.NewGroup(
+			getSectionHeader(projectSection),
+			huh.NewSelect[config.Framework]().
+				Title("Which framework does this project use?").
+				Options(
+					huh.NewOption("Symfony", config.Symfony),
+					huh.NewOption("Laravel", config.Laravel),
+					huh.NewOption("WordPress", config.WordPress),
+					huh.NewOption("Drupal", config.Drupal),
+					huh.NewOption("None", config.NoFramework),
+				).
+				Value(&cfg.Framework),
+		),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewInput().
+				Title("In which directory tooling will be installed?").
+				Placeholder("./tools").
+				Validate(func(answer string) error {
+					_, err := ParseToolsDirectory(answer)
+
+					return err
+				}).
+				Value(&cfg.ToolsDirectory),
+			huh.NewInput().
+				Title("Which directories should be analysed? (comma separated, globs like modules/* are expanded)").
+				Placeholder("src, tests").
+				Validate(func(answer string) error {
+					if len(ParsePaths(answer)) == 0 {
+						return errors.New("please enter at least one directory")
+					}
+
+					return nil
+				}).
+				Value(&answers.paths),
+			huh.NewMultiSelect[tools.Tool]().
+				Title("Which tools do you want to install?").
+				Description(getRecommendationsDescription(cfg.Framework)).
+				Options(getToolOptions(cfg.Framework, answers.installed)...).
+				Value(&cfg.Tools),
+		),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewSelect[config.InstallMethod]().
+				Title("How should the tools be installed?").
+				Description("Phars are faster to install, tools without one (or needing plugins) still use composer. The composer-bin-plugin namespaces are in the tools directory.").
+				Options(
+					huh.NewOption("With composer, one project per tool", config.ComposerInstall),
+					huh.NewOption("As phars, checked against the checksums of the lock", config.PharInstall),
+					huh.NewOption("With PHIVE, checking the GPG signatures of the phars (needs gpg where PHP runs)", config.PhiveInstall),
+					huh.NewOption("With bamarni/composer-bin-plugin, one namespace per tool in the composer.json of the project", config.BinPluginInstall),
+					huh.NewOption("In the require-dev of the project, sharing its dependencies and its composer.json", config.RequireDevInstall),
+				).
+				Value(&cfg.InstallMethod),
+		),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewConfirm().
+				Title("Should the composer.lock of the tool directories be committed?").
+				Description("Committed, everyone installs the same versions with just install-php. Ignored, install-php updates the tools to their latest versions.").
+				Affirmative("Commit them").
+				Negative("Ignore them").
+				Value(&answers.commitToolLocks),
+		).WithHideFunc(func() bool {
+			return cfg.UsesRequireDev()
+		}),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewText().
+				Title("Which versions should be installed? (one \"<tool> <constraint>\" per line, e.g. \"phpstan ^1.12\")").
+				Description("Leave empty to install the latest versions, phars can only be pinned to an exact version").
+				Lines(4).
+				Validate(func(answer string) error {
+					_, err := ParseVersions(answer)
+
+					return err
+				}).
+				Value(&answers.versions),
+		),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewMultiSelect[tools.Tool]().
+				Title("Which tools should accept pre-releases, e.g. to test a release candidate?").
+				Description("They are installed with composer, with this minimum stability in their composer.json").
+				Options(toolOptions...).
+				Value(&answers.preReleaseTools),
+		),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewSelect[string]().
+				Title("Down to which stability?").
+				Options(
+					huh.NewOption("Release candidates", "RC"),
+					huh.NewOption("Betas", "beta"),
+					huh.NewOption("Alphas", "alpha"),
+					huh.NewOption("Development versions", "dev"),
+				).
+				Value(&answers.preReleaseStability),
+		).WithHideFunc(func() bool {
+			return len(answers.preReleaseTools) == 0
+		}),
+		// Tools installed globally in a container would be lost along with it
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewMultiSelect[tools.Tool]().
+				Title("Which tools should rather be installed with composer global, shared with your other projects?").
+				Description("Recipes run them from the global vendor/bin of composer, select none to install them in the project").
+				Options(toolOptions...).
+				Value(&cfg.GlobalTools),
+		).WithHideFunc(func() bool {
+			return cfg.Environment != config.Local
+		}),
+		huh.NewGroup(
+			getSectionHeader(toolsSection),
+			huh.NewConfirm().
+				Title("Do you want to generate a PHPStan baseline ignoring the errors of the existing code?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&cfg.PhpStan.Baseline),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpStan)
+		}),
+		// Existing errors are ignored by the baseline, so the strictest level is recommended along with it
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewSelect[string]().
+				Title("Which PHPStan level do you want to use?").
+				Description("Recommended: max, as existing errors are ignored by the baseline").
+				Options(getPhpStanLevelOptions("max")...).
+				Value(&cfg.PhpStan.Level),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpStan) || !cfg.PhpStan.Baseline
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewSelect[string]().
+				Title("Which PHPStan level do you want to use?").
+				Description("Recommended: 5, then raise it once existing errors are fixed").
+				Options(getPhpStanLevelOptions("5")...).
+				Value(&cfg.PhpStan.Level),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpStan) || cfg.PhpStan.Baseline
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewSelect[string]().
+				Title("Which PHP CS Fixer ruleset do you want to use?").
+				Options(
+					huh.NewOption("Symfony", "@Symfony"),
+					huh.NewOption("PSR-12", "@PSR12"),
+					huh.NewOption("PER Coding Style", "@PER-CS"),
+					huh.NewOption("Custom", "custom"),
+				).
+				Value(&cfg.PhpCsFixer.Ruleset),
+			huh.NewConfirm().
+				Title("Do you want to enable risky rules?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&cfg.PhpCsFixer.Risky),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpCsFixer)
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewInput().
+				Title("Which rule sets and rules do you want to enable? (comma separated)").
+				Placeholder("@PhpCsFixer, strict_param").
+				Value(&cfg.PhpCsFixer.CustomRules),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpCsFixer) || cfg.PhpCsFixer.Ruleset != "custom"
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewSelect[string]().
+				Title("Which coding standard do you want PHP CS to check?").
+				Options(
+					huh.NewOption("Symfony", "Symfony"),
+					huh.NewOption("PSR-12", "PSR12"),
+					huh.NewOption("Slevomat (PSR-12 with Slevomat sniffs)", "Slevomat"),
+					huh.NewOption("Doctrine", "Doctrine"),
+				).
+				Value(&cfg.PhpCS.Standard),
+		).WithHideFunc(func() bool {
+			// These frameworks come with their own coding standard
+			return !cfg.IsToolSelected(tools.PhpCS) || cfg.Framework == config.WordPress || cfg.Framework == config.Drupal
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewMultiSelect[string]().
+				Title("Which PHP MD rulesets do you want to enable?").
+				Options(
+					huh.NewOption("Clean code", "cleancode"),
+					huh.NewOption("Code size", "codesize"),
+					huh.NewOption("Controversial", "controversial"),
+					huh.NewOption("Design", "design"),
+					huh.NewOption("Naming", "naming"),
+					huh.NewOption("Unused code", "unusedcode"),
+				).
+				Value(&cfg.PhpMD.Rulesets),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpMD)
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewInput().
+				Title("From which cyclomatic complexity should a method be reported?").
+				Validate(validatePositiveNumber).
+				Value(&cfg.PhpMD.Complexity),
+			huh.NewInput().
+				Title("From how many lines should a method be reported as too long?").
+				Validate(validatePositiveNumber).
+				Value(&cfg.PhpMD.MethodLength),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.PhpMD) || !slices.Contains(cfg.PhpMD.Rulesets, "codesize")
+		}),
+		huh.NewGroup(
+			getSectionHeader(rulesetsSection),
+			huh.NewConfirm().
+				Title("Do you want to generate a Psalm baseline ignoring the errors of the existing code?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&cfg.Psalm.Baseline),
+		).WithHideFunc(func() bool {
+			return !cfg.IsToolSelected(tools.Psalm)
+		}),
+		huh.NewGroup(
+			getSectionHeader(outputsSection),
+			huh.NewMultiSelect[config.Output]().
+				Title("Which additional files do you want to generate?").
+				Options(getOutputOptions(cfg)...).
+				Value(&cfg.Outputs),
+		),
+	)
+	groups = append(groups, getJustFileGroups(cfg, *answers)...)
+
+	return append(groups, getPreviewGroup(outputsSection, &answers.preview)), nil
+}
+
+/**
+ * Parse the answers of the wizard into the Config, along with the callbacks of an interactive installation
+ */
+func applyInstallAnswers(cfg *config.Config, answers installAnswers) {
+	// Validated by the form
+	cfg.ToolsDirectory, _ = ParseToolsDirectory(cfg.ToolsDirectory)
+	cfg.Paths = ParsePaths(answers.paths)
+	cfg.Versions, _ = ParseVersions(answers.versions)
+	cfg.Composer.IgnoreLocks = !answers.commitToolLocks
+	cfg.Stability = make(map[tools.Tool]string)
+
+	for _, tool := range answers.preReleaseTools {
+		if cfg.IsToolSelected(tool) {
+			cfg.Stability[tool] = answers.preReleaseStability
+		}
+	}
+	// Only the selected tools are installed
+	cfg.GlobalTools = slices.DeleteFunc(cfg.GlobalTools, func(tool tools.Tool) bool {
+		return !cfg.IsToolSelected(tool)
+	})
+	cfg.ResolveConflict = ResolveConflict
+	cfg.ConfirmRollback = ConfirmRollback
+	cfg.ReportStep = ReportStep
+	cfg.PreviewChange = nil
+
+	if answers.preview {
+		cfg.PreviewChange = PreviewChange
+	} else if answers.askJustFile && cfg.JustFile == "justfile" {
+		cfg.PreviewChange = previewJustFileChange
+	}
+}
+
+/**
+ * Return the tools to choose from along with what they do, whether they are installed, recommended for the framework
+ * of the project or abandoned
+ */
+func getToolOptions(framework config.Framework, installed []tools.Tool) []huh.Option[tools.Tool] {
+	options := make([]huh.Option[tools.Tool], len(tools.Available))
+
+	for i, tool := range tools.Available {
+		definition, _ := tools.Get(tool)
+		label := definition.Name
+
+		if definition.Description != "" {
+			label += dimmed.Render(" - " + definition.Description)
+		}
+
+		if slices.Contains(installed, tool) {
+			label += highlighted.Render(" (installed)")
+		} else if definition.Maintenance == tools.AbandonedTool {
+			label += warning.Render(" (abandoned)")
+		} else if slices.Contains(definition.Recommended, string(framework)) {
+			label += highlighted.Render(" (recommended)")
+		}
+
+		options[i] = huh.NewOption(label, tool)
+	}
+
+	return options
+}
+
+func getRecommendationsDescription(framework config.Framework) string {
+	if framework == config.NoFramework {
+		return "Recommendations are for projects without framework"
+	}
+
+	return "Recommendations are for " + string(framework) + " projects"
+}
+
+/**
+ * Return the additional files which can be generated, coverage reports need the tests of the project
+ */
+func getOutputOptions(cfg *config.Config) []huh.Option[config.Output] {
+	options := []huh.Option[config.Output]{
+		huh.NewOption("GitHub composite action (.github/actions/php-quality)", config.GitHubCompositeAction),
+		huh.NewOption("GitHub workflow and qa-diff recipe checking changed files only", config.GitHubDiffWorkflow),
+		huh.NewOption("Git hooks", config.GitHooks),
+		huh.NewOption(".editorconfig matching the coding standard", config.EditorConfig),
+		huh.NewOption("PhpStorm settings running the installed tools (.idea)", config.PhpStorm),
+		huh.NewOption("VS Code settings of the tool extensions and tasks running the recipes (.vscode)", config.VsCode),
+		huh.NewOption("Neovim snippet registering the tools in none-ls and nvim-lint (.phptooling/nvim.lua)", config.Neovim),
+		huh.NewOption("Language server settings matching the PHP version of the project (phpactor or intelephense)", config.LanguageServer),
+	}
+
+	if cfg.TestFramework != "" {
+		options = append(options, huh.NewOption("Coverage recipe and CI steps writing clover and cobertura reports ("+cfg.TestFramework.Binary()+")", config.Coverage))
+	}
+
+	return options
+}
+
+/**
+ * Return the recipes of the justfile of the project and true when phptooling didn't write it
+ */
+func getForeignJustFile() ([]string, bool) {
+	existing, readErr := os.ReadFile(filepath.Join(runner.LocalWorkingDirectory(), "justfile"))
+	projectLock, _, lockErr := lock.Read(runner.LocalWorkingDirectory())
+
+	// Files already holding recipes of phptooling keep receiving them
+	if readErr != nil || lockErr != nil || len(projectLock.Blocks) > 0 {
+		return nil, false
+	}
+
+	return generator.RecipeNames(string(existing)), true
+}
+
+/**
+ * Return the form group asking where the recipes go when the project has its own justfile, none otherwise. Blocks
+ * appended to it are shown first.
+ */
+func getJustFileGroups(cfg *config.Config, answers installAnswers) []*huh.Group {
+	if !answers.askJustFile {
+		return nil
+	}
+
+	description := "Recipes of phptooling with the name of an existing one are left out, just refuses duplicates"
+
+	if len(answers.justFileRecipes) > 0 {
+		description = "It defines " + strings.Join(answers.justFileRecipes, ", ") + ". " + description
+	}
+
+	return []*huh.Group{
+		huh.NewGroup(
+			getSectionHeader(outputsSection),
+			huh.NewSelect[string]().
+				Title("The project already has a justfile, where should the recipes be written?").
+				Description(description).
+				Options(
+					huh.NewOption("In "+config.ImportedJustFile+", imported by the justfile (needs just 1.19 or later)", config.ImportedJustFile),
+					huh.NewOption("At the end of the justfile, showing each block before appending it", "justfile"),
+				).
+				Value(&cfg.JustFile),
+		),
+	}
+}
+
+/**
+ * Only preview the blocks appended to the justfile of the project, when the other changes aren't reviewed
+ */
+func previewJustFileChange(destination string, diff string, content string) (string, bool) {
+	if destination != "justfile" {
+		return content, true
+	}
+
+	return PreviewChange(destination, diff, content)
+}
+
+/**
+ * Return the form groups asking how PHP commands are run, shared by every command
+ */
+func getEnvironmentGroups(cfg *config.Config) ([]*huh.Group, error) {
+	var composeServices []runner.ComposeService
+
+	if composeFile := runner.DetectComposeFile(runner.LocalWorkingDirectory()); composeFile != "" {
+		services, err := runner.ComposeServices(runner.LocalWorkingDirectory(), composeFile)
+
+		// The service can still be typed
+		if err != nil {
+			slog.Warn("Could not list the services of "+composeFile, "error", err)
+		}
+
+		composeServices = services
+	}
+
+	servicesOptions := make([]huh.Option[string], len(composeServices))
+
+	for i, service := range composeServices {
+		label := service.Name
+
+		if service.Image != "" {
+			label += dimmed.Render(" - " + service.Image)
+		}
+
+		if service.Php {
+			label += highlighted.Render(" (PHP)")
+		}
+
+		servicesOptions[i] = huh.NewOption(label, service.Name)
+	}
+
+	// The services likely running PHP come first, the first one is proposed
+	if cfg.DockerService == "" && len(composeServices) > 0 && composeServices[0].Php {
+		cfg.DockerService = composeServices[0].Name
+	}
+
+	servicesDescription, servicesHeight := "", 0
+
+	if len(composeServices) > maxListedServices {
+		servicesDescription = "Type / to filter the services by name"
+		servicesHeight = maxListedServices + 2
+	}
+
+	var serviceField huh.Field = huh.NewSelect[string]().
+		Title("Which service do you want to use for running PHP commands?").
+		Description(servicesDescription).
+		Options(servicesOptions...).
+		Height(servicesHeight).
+		Value(&cfg.DockerService)
+
+	if len(composeServices) == 0 {
+		serviceField = huh.NewInput().
+			Title("Which service do you want to use for running PHP commands?").
+			Description("No service was found in the compose file, type the name of the service").
+			Validate(func(answer string) error {
+				if strings.TrimSpace(answer) == "" {
+					return errors.New("please enter a service")
+				}
+
+				return nil
+			}).
+			Value(&cfg.DockerService)
+	}
+
+	return []*huh.Group{
+		huh.NewGroup(
+			getSectionHeader(projectSection),
+			huh.NewSelect[config.Environment]().
+				Title("Where are PHP commands run in this project?").
+				Options(
+					huh.NewOption("On this machine", config.Local),
+					huh.NewOption("In a docker compose service", config.DockerCompose),
+					huh.NewOption("In the ddev web container", config.Ddev),
+					huh.NewOption("In a Kubernetes pod (kubectl exec)", config.Kubernetes),
+				).
+				Value(&cfg.Environment),
+		),
+		huh.NewGroup(
+			getSectionHeader(projectSection),
+			serviceField,
+			huh.NewSelect[string]().
+				Title("Which variant do you want to use for running commands?").
+				Options(
+					huh.NewOption("exec", "exec"),
+					huh.NewOption("run", "run"),
+				).
+				Value(&cfg.DockerCommand),
+		).WithHideFunc(func() bool {
+			return cfg.Environment != config.DockerCompose
+		}),
+		huh.NewGroup(
+			getSectionHeader(projectSection),
+			huh.NewSelect[string]().
+				Title("Which composer cache should the containers started by run use?").
+				Options(
+					huh.NewOption("A docker volume shared by every run (recommended)", "phptooling-composer-cache"),
+					huh.NewOption("The composer cache of this machine (~/.composer/cache)", "~/.composer/cache"),
+					huh.NewOption("None, each command starts with an empty cache", ""),
+				).
+				Value(&cfg.DockerComposerCache),
+		).WithHideFunc(func() bool {
+			return cfg.Environment != config.DockerCompose || cfg.DockerCommand != "run"
+		}),
+		huh.NewGroup(
+			getSectionHeader(projectSection),
+			huh.NewInput().
+				Title("In which pod should PHP commands be run?").
+				Description("A pod name or a resource owning pods, e.g. deploy/app").
+				Validate(func(answer string) error {
+					if strings.TrimSpace(answer) == "" {
+						return errors.New("please enter a pod or a resource")
+					}
+
+					return nil
+				}).
+				Value(&cfg.Kubernetes.Target),
+			huh.NewInput().
+				Title("In which container of the pod? (empty for the default one)").
+				Value(&cfg.Kubernetes.Container),
+			huh.NewInput().
+				Title("In which namespace? (empty for the one of the current context)").
+				Value(&cfg.Kubernetes.Namespace),
+		).WithHideFunc(func() bool {
+			return cfg.Environment != config.Kubernetes
+		}),
+	}, nil
+}
+
+/**
+ * Check that docker runs when PHP commands are run in docker compose or ddev. In a terminal, running them on this
+ * machine instead is proposed, otherwise the error tells how to go on.
+ */
+func CheckEnvironment(ctx context.Context, cfg *config.Config) error {
+	if cfg.Environment != config.DockerCompose && cfg.Environment != config.Ddev {
+		return nil
+	}
+
+	dockerErr := runner.CheckDocker(ctx, cfg.Environment == config.Ddev)
+
+	if dockerErr == nil {
+		return nil
+	}
+
+	if !logging.IsInteractive() {
+		return failure.Wrap(failure.Environment, "start docker or run PHP commands on this machine with --environment=local", dockerErr)
+	}
+
+	local := true
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Docker can't be used, do you want to run PHP commands on this machine instead?").
+				Description(warning.Render(dockerErr.Error()) + "\nPHP and composer must then be installed here, otherwise start docker and run phptooling again").
+				Affirmative("Yes, run them here").
+				Negative("No, stop").
+				Value(&local),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	if !local {
+		return failure.Wrap(failure.Aborted, "start docker and run phptooling again", dockerErr)
+	}
+
+	cfg.Environment = config.Local
+	slog.Info("PHP commands are run on this machine")
+
+	return nil
+}
+
+/**
+ * Classify the error of a form, leaving the wizard with ctrl+c is reported as an abort
+ */
+func wrapFormError(err error) error {
+	if errors.Is(err, huh.ErrUserAborted) {
+		return failure.Wrap(failure.Aborted, "wizard", err)
+	}
+
+	return failure.Wrap(failure.Environment, "wizard", err)
+}
+
+/**
+ * Show the changes proposed for an existing file and ask what to do with them
+ */
+func ResolveConflict(destination string, diff string) config.Resolution {
+	defer logging.PauseStatus()()
+	fmt.Println(destination + " already exists, changes proposed by phptooling:\n" + diff)
+
+	resolution := config.Skip
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[config.Resolution]().
+				Title("What do you want to do with "+destination+"?").
+				Options(
+					huh.NewOption("Skip, keep the current file", config.Skip),
+					huh.NewOption("Merge, changed blocks are surrounded by conflict markers to resolve", config.Merge),
+					huh.NewOption("Overwrite with the proposed file", config.Overwrite),
+				).
+				Value(&resolution),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return config.Skip
+	}
+
+	return resolution
+}
+
+/**
+ * Ask whether the changes of a failed installation should be reverted
+ */
+func ConfirmRollback() bool {
+	rollback := true
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("The installation failed, do you want to revert the changes listed above?").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&rollback),
+		),
+	).WithTheme(theme()).Run()
+
+	return err == nil && rollback
+}
+
+/**
+ * Ask once whether anonymous usage statistics may be sent, nothing is sent unless the user accepts
+ */
+func AskTelemetryConsent() error {
+	if _, asked := telemetry.ReadConsent(); asked {
+		return nil
+	}
+
+	consent := telemetry.Consent{}
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Help improving phptooling by sending anonymous usage statistics?").
+				Description("Only the selected tools, outputs and framework, the environment (local, docker...), the OS and the exit code\n" +
+					"are sent after each run, never paths or names. Change your mind in ~/.config/phptooling/telemetry.json\n" +
+					"or run with --no-telemetry.").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&consent.Enabled),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	return telemetry.SaveConsent(consent)
+}
+
+// Frames of the spinner shown next to the running steps
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Steps of the pipeline in their order along with their last event, listed below the console messages
+var installSteps struct {
+	sync.Mutex
+	names  []string
+	events map[string]pipeline.Event
+}
+
+/**
+ * Report the installation steps as a list showing whether each one is pending, running, done or failed: the output of
+ * their commands is hidden, shown above the list when a step fails, and the list is printed once every step ended.
+ * Without a terminal, each step is printed as it starts and ends with the output of its commands. Hidden in quiet
+ * mode.
+ */
+func ReportStep(event pipeline.Event) {
+	if !logging.OnConsole(slog.LevelInfo) {
+		return
+	}
+
+	updateStep(event)
+
+	if event.Status == pipeline.Pending {
+		logging.StartStatus(renderSteps)
+		return
+	}
+
+	listed := logging.StatusShown()
+
+	switch event.Status {
+	case pipeline.Running:
+		if !listed && event.Attempt == 1 {
+			logging.Println(lipgloss.NewStyle().Bold(true).Render("• " + event.Step))
+		}
+	case pipeline.Retrying:
+		logging.Println(lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render("↻ "+event.Step) + " failed, trying again: " + event.Err.Error())
+	case pipeline.Failed:
+		if !listed {
+			logging.Println(renderStep(event, 0))
+		}
+
+		printHiddenOutput(event.Err)
+	case pipeline.Succeeded, pipeline.Skipped:
+		if !listed {
+			logging.Println(renderStep(event, 0))
+		}
+	}
+
+	if listed && areStepsEnded() {
+		logging.StopStatus()
+		logging.Println(renderSteps(0))
+	}
+}
+
+/**
+ * Record the event of the step, steps are listed in the order they are first reported
+ */
+func updateStep(event pipeline.Event) {
+	installSteps.Lock()
+	defer installSteps.Unlock()
+
+	if installSteps.events == nil {
+		installSteps.events = make(map[string]pipeline.Event)
+	}
+
+	if _, found := installSteps.events[event.Step]; !found {
+		installSteps.names = append(installSteps.names, event.Step)
+	}
+
+	// Retries keep running, the error is shown above the list
+	if event.Status == pipeline.Retrying {
+		event.Status = pipeline.Running
+	}
+
+	installSteps.events[event.Step] = event
+}
+
+func areStepsEnded() bool {
+	installSteps.Lock()
+	defer installSteps.Unlock()
+
+	for _, event := range installSteps.events {
+		if event.Status == pipeline.Pending || event.Status == pipeline.Running {
+			return false
+		}
+	}
+
+	return true
+}
+
+func renderSteps(frame int) string {
+	installSteps.Lock()
+	defer installSteps.Unlock()
+
+	lines := make([]string, len(installSteps.names))
+
+	for i, name := range installSteps.names {
+		lines[i] = renderStep(installSteps.events[name], frame)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/**
+ * Return the line of the step in the list, its duration is shown once it ended
+ */
+func renderStep(event pipeline.Event, frame int) string {
+	duration := event.Duration.Round(100 * time.Millisecond).String()
+
+	switch event.Status {
+	case pipeline.Running:
+		line := lipgloss.NewStyle().Foreground(lipgloss.Color("5")).Render(spinnerFrames[frame%len(spinnerFrames)]) + " " + event.Step
+
+		if event.Attempt > 1 {
+			line += lipgloss.NewStyle().Faint(true).Render(" attempt " + strconv.Itoa(event.Attempt))
+		}
+
+		return line + formatEstimate(event.Estimate)
+	case pipeline.Succeeded:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Render("✓ "+event.Step) + " " + duration
+	case pipeline.Failed:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render("✗ "+event.Step) + " " + duration
+	case pipeline.Skipped:
+		return lipgloss.NewStyle().Faint(true).Render("- " + event.Step + " skipped")
+	}
+
+	return lipgloss.NewStyle().Faint(true).Render("· "+event.Step) + formatEstimate(event.Estimate)
+}
+
+/**
+ * Return the estimated duration following a step or a tool, nothing when it is unknown or below a second
+ */
+func formatEstimate(estimate time.Duration) string {
+	if estimate < time.Second {
+		return ""
+	}
+
+	return dimmed.Render(" ~" + estimate.Round(time.Second).String())
+}
+
+/**
+ * Print the output of the failed command, indented below the step
+ */
+func printHiddenOutput(err error) {
+	output := failure.OutputOf(err)
+
+	if output == "" {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		logging.Println(lipgloss.NewStyle().Faint(true).Render("    " + line))
+	}
+}
+
+/**
+ * Parse a comma separated list of directories, expanding globs against the project
+ */
+func ParsePaths(answer string) []string {
+	var paths []string
+	projectDirectory := runner.LocalWorkingDirectory()
+
+	for _, answerPath := range strings.Split(answer, ",") {
+		answerPath = strings.Trim(strings.TrimSpace(answerPath), "/")
+
+		if answerPath == "" {
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(projectDirectory, answerPath))
+
+		if err != nil || !strings.ContainsAny(answerPath, "*?[") {
+			paths = append(paths, path.Clean(answerPath))
+			continue
+		}
+
+		for _, match := range matches {
+			relativePath, relErr := filepath.Rel(projectDirectory, match)
+
+			if relErr == nil {
+				paths = append(paths, filepath.ToSlash(relativePath))
+			}
+		}
+	}
+
+	return paths
+}
+
+/**
+ * Parse the tools directory into a path relative to the project, absolute paths are accepted inside of it
+ */
+func ParseToolsDirectory(answer string) (string, error) {
+	directory := strings.TrimSpace(answer)
+
+	if directory == "" {
+		return "", errors.New("please enter a directory")
+	}
+
+	if filepath.IsAbs(directory) {
+		relativePath, relErr := filepath.Rel(runner.LocalWorkingDirectory(), directory)
+
+		if relErr != nil {
+			return "", errors.New("the tools directory must be inside the project")
+		}
+
+		directory = relativePath
+	}
+
+	directory = path.Clean(filepath.ToSlash(directory))
+
+	if directory == ".." || strings.HasPrefix(directory, "../") {
+		return "", errors.New("the tools directory must be inside the project")
+	}
+
+	if directory == "." {
+		return "", errors.New("the tools need a directory of their own")
+	}
+
+	return directory, nil
+}
+
+/**
+ * Return the tools directory when it already exists with files which weren't installed by phptooling, empty otherwise
+ */
+func getForeignToolsDirectory(cfg *config.Config) string {
+	// The namespaces of the bin plugin are reused on purpose, required tools have no directory
+	if cfg.UsesBinPlugin() || cfg.UsesRequireDev() {
+		return ""
+	}
+
+	directory := path.Clean(cfg.ToolsDirectory)
+	entries, readErr := os.ReadDir(filepath.Join(runner.LocalWorkingDirectory(), directory))
+	projectLock, _, lockErr := lock.Read(runner.LocalWorkingDirectory())
+
+	if readErr != nil || len(entries) == 0 || (lockErr == nil && projectLock.ToolsDirectory == directory && len(projectLock.Tools) > 0) {
+		return ""
+	}
+
+	return directory
+}
+
+/**
+ * Ask whether the tools directory should be reused or wiped when it holds other files
+ */
+func askForeignToolsDirectory(cfg *config.Config) error {
+	directory := getForeignToolsDirectory(cfg)
+
+	if directory == "" {
+		return nil
+	}
+
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[bool]().
+				Title(directory+"/ already exists and isn't empty, what do you want to do with it?").
+				Options(
+					huh.NewOption("Reuse it, the tools are installed next to its content", false),
+					huh.NewOption("Wipe it, its content is kept in the backups of the run for phptooling restore", true),
+				).
+				Value(&cfg.WipeToolsDirectory),
+		),
+	).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	return nil
+}
+
+/**
+ * Ask which ones to keep among the selected tools doing part of the same job, along with how to choose
+ */
+func askOverlappingTools(cfg *config.Config) error {
+	overlaps := tools.FindOverlaps(cfg.Tools)
+
+	if len(overlaps) == 0 {
+		return nil
+	}
+
+	removed := make([]tools.Tool, len(overlaps))
+	fields := []huh.Field{getSectionHeader(toolsSection)}
+
+	for i, overlap := range overlaps {
+		name, otherName := tools.Name(overlap.Tool), tools.Name(overlap.Other)
+		fields = append(fields, huh.NewSelect[tools.Tool]().
+			Title(name+" and "+otherName+" overlap, which ones do you want to install?").
+			Description(overlap.Guidance).
+			Options(
+				huh.NewOption("Both", tools.Tool("")),
+				huh.NewOption("Only "+name, overlap.Other),
+				huh.NewOption("Only "+otherName, overlap.Tool),
+			).
+			Value(&removed[i]))
+	}
+
+	err := huh.NewForm(huh.NewGroup(fields...)).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	cfg.Tools = slices.DeleteFunc(cfg.Tools, func(tool tools.Tool) bool {
+		return slices.Contains(removed, tool)
+	})
+	cfg.GlobalTools = slices.DeleteFunc(cfg.GlobalTools, func(tool tools.Tool) bool {
+		return slices.Contains(removed, tool)
+	})
+
+	return nil
+}
+
+/**
+ * Ask what to do with each selected tool already installed in the tools directory, unless it is wiped
+ */
+func askInstalledTools(cfg *config.Config) error {
+	installed := getSelectedInstalledTools(cfg)
+	actions := make([]config.ToolAction, len(installed))
+	fields := []huh.Field{getSectionHeader(toolsSection)}
+
+	for i, tool := range installed {
+		definition, _ := tools.Get(tool)
+		actions[i] = config.UpdateTool
+
+		if action, found := cfg.ToolActions[tool]; found {
+			actions[i] = action
+		}
+
+		fields = append(fields, huh.NewSelect[config.ToolAction]().
+			Title(definition.Name+" is already installed, what do you want to do with it?").
+			Options(
+				huh.NewOption("Update it within its version constraint", config.UpdateTool),
+				huh.NewOption("Reinstall the versions of its lock", config.ReinstallTool),
+				huh.NewOption("Skip it, its files and recipes stay as they are", config.SkipTool),
+			).
+			Value(&actions[i]))
+	}
+
+	cfg.ToolActions = make(map[tools.Tool]config.ToolAction)
+
+	if len(installed) == 0 {
+		return nil
+	}
+
+	err := huh.NewForm(huh.NewGroup(fields...)).WithTheme(theme()).Run()
+
+	if err != nil {
+		return wrapFormError(err)
+	}
+
+	for i, tool := range installed {
+		cfg.ToolActions[tool] = actions[i]
+	}
+
+	return nil
+}
+
+/**
+ * Return the selected tools found in the tools directory, the global and require-dev ones are always required again
+ */
+func getSelectedInstalledTools(cfg *config.Config) []tools.Tool {
+	if cfg.WipeToolsDirectory || cfg.UsesRequireDev() {
+		return nil
+	}
+
+	var selected []tools.Tool
+
+	for _, tool := range tools.DetectInstalled(runner.LocalWorkingDirectory(), cfg.ToolsDirectory) {
+		if cfg.IsToolSelected(tool) && !cfg.InstallsGlobally(tool) {
+			selected = append(selected, tool)
+		}
+	}
+
+	return selected
+}
+
+/**
+ * Parse version constraints given as "<tool> <constraint>" lines
+ */
+func ParseVersions(answer string) (map[tools.Tool]string, error) {
+	versions := make(map[tools.Tool]string)
+
+	for _, line := range strings.Split(answer, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 {
+			return nil, errors.New("expected \"<tool> <constraint>\", got \"" + strings.TrimSpace(line) + "\"")
+		}
+
+		if _, found := tools.Get(tools.Tool(fields[0])); !found {
+			return nil, errors.New("unknown tool " + fields[0] + ", expected an id such as phpstan or phpcsfixer")
+		}
+
+		versions[tools.Tool(fields[0])] = strings.Join(fields[1:], " ")
+	}
+
+	return versions, nil
+}
+
+/**
+ * Format version constraints as parsed by ParseVersions, sorted by tool
+ */
+func formatVersions(versions map[tools.Tool]string) string {
+	var lines []string
+
+	for tool, constraint := range versions {
+		lines = append(lines, string(tool)+" "+constraint)
+	}
+
+	slices.Sort(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+/**
+ * Return the tools accepting pre-releases and their stability as proposed answers, RC by default
+ */
+func getPreReleaseAnswers(stabilities map[tools.Tool]string) ([]tools.Tool, string) {
+	var preReleaseTools []tools.Tool
+
+	for tool, stability := range stabilities {
+		if stability != "stable" {
+			preReleaseTools = append(preReleaseTools, tool)
+		}
+	}
+
+	if len(preReleaseTools) == 0 {
+		return nil, "RC"
+	}
+
+	slices.Sort(preReleaseTools)
+
+	return preReleaseTools, stabilities[preReleaseTools[0]]
+}
+
+func validatePositiveNumber(value string) error {
+	number, err := strconv.Atoi(value)
+
+	if err != nil || number <= 0 {
+		return errors.New("please enter a positive number")
+	}
+
+	return nil
+}
+
+func getPhpStanLevelOptions(recommendedLevel string) []huh.Option[string] {
+	var options []huh.Option[string]
+
+	for _, level := range []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "max"} {
+		options = append(options, huh.NewOption(level, level).Selected(level == recommendedLevel))
+	}
+
+	return options
+}
@@ -0,0 +1,371 @@
+package phptooling
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/filesystem"
+	"ecohead/phptooling/pkg/generator"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/pipeline"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/timing"
+	"embed"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+//go:embed all:config-files/*
+var contentFS embed.FS
+
+type Config = config.Config
+
+/**
+ * Fill the Config with what can be guessed from the project: ddev or docker compose, PHP version and framework
+ */
+func Detect(cfg *Config) error {
+	projectDirectory := runner.LocalWorkingDirectory()
+
+	if runner.DetectDdev(projectDirectory) {
+		cfg.Environment = config.Ddev
+	} else if runner.DetectComposeFile(projectDirectory) != "" {
+		cfg.Environment = config.DockerCompose
+	}
+
+	phpVersion, versionErr := project.DetectPhpVersion(projectDirectory)
+
+	if versionErr != nil {
+		return versionErr
+	}
+
+	cfg.PhpVersion = phpVersion
+
+	if cfg.PhpVersion != "" {
+		slog.Info("Detected PHP version from composer.json", "version", cfg.PhpVersion)
+	}
+
+	if composerJson, found, _ := project.ReadComposerJson(projectDirectory); found {
+		framework, paths, frameworkErr := project.DetectFramework(projectDirectory)
+
+		if frameworkErr != nil {
+			return frameworkErr
+		}
+
+		cfg.Framework = framework
+		cfg.TestFramework = project.DetectTestFramework(composerJson)
+
+		if paths != nil {
+			cfg.Paths = paths
+		}
+	}
+
+	binDirectory, binPlugin, binErr := project.DetectBinPlugin(projectDirectory)
+
+	if binErr != nil {
+		return binErr
+	}
+
+	// The tools follow the namespaces the project already has, unless another method was chosen
+	if binPlugin && cfg.InstallMethod == config.ComposerInstall {
+		cfg.InstallMethod = config.BinPluginInstall
+		cfg.ToolsDirectory = binDirectory
+		slog.Info("Detected "+project.BinPluginPackage+", tools are installed in its namespaces", "directory", binDirectory)
+	}
+
+	projectLock, _, lockErr := lock.Read(projectDirectory)
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	// The recipes keep going to the file of the previous runs
+	for _, block := range projectLock.Blocks {
+		if block.File == config.ImportedJustFile {
+			cfg.JustFile = config.ImportedJustFile
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Install the tools of the Config in the project along with their configuration, recipes and additional outputs,
+ * the files touched before an error are rolled back when the Config confirms it. Other runs can't write to the
+ * project meanwhile.
+ */
+func Install(ctx context.Context, cfg *Config) (err error) {
+	// These frameworks come with their own coding standard
+	if cfg.Framework == config.WordPress {
+		cfg.PhpCS.Standard = "WordPress"
+	} else if cfg.Framework == config.Drupal {
+		cfg.PhpCS.Standard = "Drupal"
+	}
+
+	unlock, lockErr := filesystem.LockRun(runner.LocalWorkingDirectory())
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	defer unlock()
+
+	g, err := newGenerator(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer g.Close()
+
+	// Nothing is written before knowing that the tools can run
+	supportErr := g.CheckPhpSupport()
+
+	if supportErr != nil {
+		return supportErr
+	}
+
+	extensionsErr := g.CheckPhpExtensions()
+
+	if extensionsErr != nil {
+		return extensionsErr
+	}
+
+	var completed []string
+	durations := make(map[string]time.Duration)
+	history := timing.Read(runner.LocalWorkingDirectory())
+	steps := getInstallSteps(g)
+
+	for i := range steps {
+		steps[i].Estimate = history.EstimateInstall(steps[i].Name)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if len(completed) > 0 {
+			slog.Warn("Completed before the installation stopped: " + strings.Join(completed, ", "))
+		}
+
+		rollback(g)
+	}()
+
+	// Ctrl+C stops the installation after the current commands
+	err = pipeline.Pipeline{
+		Steps:       steps,
+		Parallelism: cfg.Parallelism,
+		Report: func(event pipeline.Event) {
+			if event.Status == pipeline.Succeeded {
+				completed = append(completed, event.Step)
+				durations[event.Step] = event.Duration
+				history.RecordInstall(event.Step, event.Duration)
+			}
+
+			reportStep(cfg, event)
+		},
+	}.Run(ctx)
+
+	// The durations only inform the next runs
+	historyErr := history.Write(runner.LocalWorkingDirectory())
+
+	if historyErr != nil {
+		slog.Debug("Could not record the durations of the steps", "error", historyErr)
+	}
+
+	if err == nil {
+		reportInconsistentRecipes(g)
+		printSummary(g, durations)
+	}
+
+	return err
+}
+
+/**
+ * Tell the Config about the progress of the step, its status is only logged when it doesn't report it
+ */
+func reportStep(cfg *Config, event pipeline.Event) {
+	slog.Debug("Step "+string(event.Status), "step", event.Step, "attempt", event.Attempt, "duration", event.Duration)
+	logging.Event("step", "step", event.Step, "status", event.Status, "attempt", event.Attempt, "duration_ms", event.Duration.Milliseconds())
+
+	if cfg.ReportStep != nil {
+		cfg.ReportStep(event)
+		return
+	}
+
+	switch event.Status {
+	case pipeline.Succeeded:
+		slog.Info("Completed "+event.Step, "duration", event.Duration.Round(time.Millisecond))
+	case pipeline.Retrying:
+		slog.Warn("Retrying " + event.Step + " after an error: " + event.Err.Error())
+	}
+}
+
+/**
+ * Write the git hooks of the Config, running the tools already installed in the tools directory
+ */
+func InstallHooks(ctx context.Context, cfg *Config) (err error) {
+	unlock, lockErr := filesystem.LockRun(runner.LocalWorkingDirectory())
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	defer unlock()
+
+	g, err := newGenerator(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer g.Close()
+
+	defer func() {
+		if err != nil {
+			rollback(g)
+		}
+	}()
+
+	return g.GenerateHooks()
+}
+
+/**
+ * List the files touched by the failed run and revert them if confirmed, the backups are kept otherwise
+ */
+func rollback(g *generator.Generator) {
+	if !g.HasTouchedFiles() {
+		return
+	}
+
+	g.ReportTouchedFiles()
+
+	if g.Config.ConfirmRollback != nil && g.Config.ConfirmRollback() {
+		rollbackErr := g.Rollback()
+
+		if rollbackErr == nil {
+			slog.Info("The project was rolled back to its state before the run")
+			return
+		}
+
+		slog.Error("The rollback failed", "error", rollbackErr)
+	}
+
+	slog.Warn(`Run "phptooling restore" to revert these files`)
+}
+
+/**
+ * Revert the files touched by the last run in the current directory
+ */
+func Restore(ctx context.Context) error {
+	projectDirectory := runner.LocalWorkingDirectory()
+	unlock, lockErr := filesystem.LockRun(projectDirectory)
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	defer unlock()
+
+	return generator.Restore(getRestoredFiles(ctx, projectDirectory), projectDirectory)
+}
+
+/**
+ * Undo the last run in the current directory, the blocks it appended are removed without reverting later changes
+ */
+func Undo(ctx context.Context) error {
+	projectDirectory := runner.LocalWorkingDirectory()
+	unlock, lockErr := filesystem.LockRun(projectDirectory)
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	defer unlock()
+
+	return generator.Undo(getRestoredFiles(ctx, projectDirectory), projectDirectory)
+}
+
+/**
+ * Return the runner of the commands in the environment of the Config
+ */
+func NewCommandRunner(cfg *Config) runner.CommandRunner {
+	switch cfg.Environment {
+	case config.DockerCompose:
+		return runner.ComposeRunner{Service: cfg.DockerService, Command: cfg.DockerCommand, ComposerCache: cfg.DockerComposerCache, ComposerAuth: cfg.Composer.AuthFile}
+	case config.Ddev:
+		return runner.DdevRunner{}
+	case config.Kubernetes:
+		return runner.KubernetesRunner{Target: cfg.Kubernetes.Target, Container: cfg.Kubernetes.Container, Namespace: cfg.Kubernetes.Namespace}
+	}
+
+	return runner.LocalRunner{}
+}
+
+/**
+ * Return the files of a project whose environment doesn't share its directory with the host, the given paths are
+ * written in the environment of the runner as well
+ */
+func newMirror(ctx context.Context, commandRunner runner.CommandRunner, paths []string) filesystem.Mirror {
+	return filesystem.Mirror{
+		Host:        filesystem.Host{Root: runner.LocalWorkingDirectory()},
+		Environment: filesystem.Commands{Runner: commandRunner, Context: ctx},
+		Paths:       paths,
+	}
+}
+
+/**
+ * Return the files the backups of a run are restored to: the ones of the host, along with the pod the run mirrored
+ * files in
+ */
+func getRestoredFiles(ctx context.Context, projectDirectory string) generator.ProjectFiles {
+	return func(manifest generator.BackupManifest) filesystem.FileSystem {
+		if manifest.Kubernetes == nil {
+			return filesystem.Host{Root: projectDirectory}
+		}
+
+		cfg := config.Default()
+		cfg.Environment = config.Kubernetes
+		cfg.Kubernetes = *manifest.Kubernetes
+
+		return newMirror(ctx, runner.WithCachedWorkingDirectory(NewCommandRunner(cfg)), manifest.Mirrored)
+	}
+}
+
+func newGenerator(ctx context.Context, cfg *Config) (*generator.Generator, error) {
+	settingsErr := runner.ExportComposerSettings(cfg.Composer.ProcessTimeout)
+
+	if settingsErr != nil {
+		return nil, settingsErr
+	}
+
+	if cfg.Composer.AuthFile != "" {
+		authErr := runner.ExportComposerAuth(cfg.Composer.AuthFile)
+
+		if authErr != nil {
+			return nil, authErr
+		}
+	}
+
+	commandRunner := NewCommandRunner(cfg)
+
+	// Attaching to the container for each command is what makes them slow
+	if commandRunner.NonInteractivePrefix() != "" {
+		commandRunner = runner.WithSessions(commandRunner)
+	}
+
+	g := generator.New(ctx, cfg, commandRunner, contentFS)
+
+	// The pod doesn't share the project directory with the host
+	if cfg.Environment == config.Kubernetes {
+		g.Files = newMirror(ctx, g.Runner, g.ToolPaths())
+	}
+
+	if cfg.Templates.Source != "" {
+		return g, g.FetchRemoteTemplates()
+	}
+
+	return g, nil
+}
@@ -0,0 +1,486 @@
+package config
+
+import (
+	"ecohead/phptooling/pkg/pipeline"
+	"ecohead/phptooling/pkg/tools"
+)
+
+// Framework selects the variants of config templates in config-files/frameworks/, Symfony ones are the default templates
+type Framework string
+
+const (
+	Symfony     Framework = "symfony"
+	Laravel     Framework = "laravel"
+	WordPress   Framework = "wordpress"
+	Drupal      Framework = "drupal"
+	NoFramework Framework = "none"
+)
+
+// Environment tells where PHP commands are run
+type Environment string
+
+const (
+	Local         Environment = "local"
+	DockerCompose Environment = "docker-compose"
+	Ddev          Environment = "ddev"
+	Kubernetes    Environment = "kubernetes"
+)
+
+type Output string
+
+const (
+	GitHubCompositeAction Output = "github-composite-action"
+	GitHubDiffWorkflow    Output = "github-diff-workflow"
+	GitHooks              Output = "git-hooks"
+	EditorConfig          Output = "editorconfig"
+	// Settings of the quality tools integrations of PhpStorm, in .idea
+	PhpStorm Output = "phpstorm"
+	// Settings of the VS Code extensions of the tools and tasks running the recipes, in .vscode
+	VsCode Output = "vscode"
+	// Lua snippet registering the tools in the diagnostics plugins of Neovim
+	Neovim Output = "neovim"
+	// Settings of the language server (phpactor or intelephense) matching the PHP version and the tools directory
+	LanguageServer Output = "language-server"
+	// Recipe and CI steps running the tests with code coverage, proposed when the project has a TestFramework
+	Coverage Output = "coverage"
+)
+
+// Policy tells whether the issues found by a tool fail the checks
+type Policy string
+
+const (
+	Blocking Policy = "blocking"
+	// The issues are still reported
+	Advisory Policy = "advisory"
+)
+
+// TestFramework runs the tests of the project, detected from its composer.json
+type TestFramework string
+
+const (
+	PhpUnitTests TestFramework = "phpunit"
+	PestTests    TestFramework = "pest"
+)
+
+/**
+ * Return the binary of the test framework, relative to the project
+ */
+func (testFramework TestFramework) Binary() string {
+	return "vendor/bin/" + string(testFramework)
+}
+
+type HookManager string
+
+const (
+	NativeHooks    HookManager = "native"
+	VersionedHooks HookManager = "versioned"
+	Lefthook       HookManager = "lefthook"
+	Husky          HookManager = "husky"
+)
+
+// InstallMethod tells how tools are installed in the tools directory
+type InstallMethod string
+
+const (
+	ComposerInstall InstallMethod = "composer"
+	// Tools with a phar are downloaded without their dependencies, the others are still installed with composer
+	PharInstall InstallMethod = "phar"
+	// Phars installed and verified by PHIVE, under the same conditions
+	PhiveInstall InstallMethod = "phive"
+	// Every tool in a namespace of bamarni/composer-bin-plugin, required by the composer.json of the project. The
+	// tools directory is the target directory of the plugin, vendor-bin by default.
+	BinPluginInstall InstallMethod = "bin-plugin"
+	// Every tool in the require-dev of the project, sharing its dependencies
+	RequireDevInstall InstallMethod = "require-dev"
+)
+
+// Separate file of the recipes, imported by the justfile of the project (just 1.19 and later)
+const ImportedJustFile = "phptooling.just"
+
+// Stabilities of composer, from the most stable
+var Stabilities = []string{"stable", "RC", "beta", "alpha", "dev"}
+
+// Resolution tells what to do with an existing file whose content differs from the generated one
+type Resolution string
+
+const (
+	Skip      Resolution = "skip"
+	Merge     Resolution = "merge"
+	Overwrite Resolution = "overwrite"
+)
+
+// ToolAction tells what to do with a selected tool already installed in the tools directory
+type ToolAction string
+
+const (
+	// Require its packages again, updating them within their constraint, and download the latest phar
+	UpdateTool ToolAction = "update"
+	// Install the packages of its composer.lock again, and the phar release of the lock
+	ReinstallTool ToolAction = "reinstall"
+	// Leave it as it is, its configuration and recipes included
+	SkipTool ToolAction = "skip"
+)
+
+// Config holds every answer needed to install the tools, filled by the wizard or by programs using the library
+type Config struct {
+	Environment   Environment
+	DockerService string
+	DockerCommand string
+	// Composer cache mounted when DockerCommand is run, see runner.ComposeRunner
+	DockerComposerCache string
+	Kubernetes          KubernetesConfig
+	ToolsDirectory      string
+	// The existing tools directory is emptied before the installation, it is restored on rollback
+	WipeToolsDirectory bool
+	// File the recipes are appended to, the justfile or ImportedJustFile
+	JustFile      string
+	InstallMethod InstallMethod
+	// Tools installed with composer global require instead, shared by the projects of the user. The install method
+	// doesn't apply to them.
+	GlobalTools []tools.Tool
+	// Version constraint of the main package of each tool (e.g. ^1.12 for PHPStan), the latest version is installed
+	// for the others. Phars can only be pinned to an exact version.
+	Versions map[tools.Tool]string
+	// Minimum stability of each tool (one of Stabilities), e.g. RC to test a release candidate, stable for the
+	// others. Tools accepting pre-releases are installed with composer.
+	Stability map[tools.Tool]string
+	// What to do with the tools already installed, their packages are required again and phars of the lock
+	// downloaded again when missing
+	ToolActions map[tools.Tool]ToolAction
+	Paths       []string
+	PhpVersion  string
+	// Directory of the result caches of the tools (PHPStan, PHP CS Fixer, PHP_CodeSniffer, Psalm), kept between
+	// runs and ignored by git
+	CacheDirectory string
+	Framework      Framework
+	// Empty when the project has no tests
+	TestFramework TestFramework
+	// Policy of the tools which aren't blocking
+	Policies   map[tools.Tool]Policy
+	Tools      []tools.Tool
+	Outputs    []Output
+	PhpStan    PhpStanConfig
+	PhpCsFixer PhpCsFixerConfig
+	PhpCS      PhpCSConfig
+	PhpMD      PhpMDConfig
+	Psalm      PsalmConfig
+	Hooks      HooksConfig
+	Templates  TemplatesConfig
+	Scripts    ScriptsConfig
+	Composer   ComposerConfig
+	Report     ReportConfig
+	// Number of tools whose composer packages are installed at the same time, one by one below 2
+	Parallelism int
+	// Called when a generated file already exists with a different content, a FileConflict error is returned when nil
+	ResolveConflict func(destination string, diff string) Resolution
+	// Called when an installation fails after touching files, they are reverted when it returns true and kept for
+	// "phptooling restore" when it returns false or is nil
+	ConfirmRollback func() bool
+	// Called before a generated file is written or a block is appended to the justfile, with the diff of the change
+	// and the content to write. It returns the content, possibly edited, and false to skip the change. Templates
+	// already existing with a different content go through ResolveConflict instead. Everything is written when nil.
+	PreviewChange func(destination string, diff string, content string) (string, bool)
+	// Called each time an installation step changes of status, completed and retried steps are logged when nil
+	ReportStep func(event pipeline.Event)
+}
+
+type KubernetesConfig struct {
+	// Pod or resource owning pods, e.g. deploy/app
+	Target    string
+	Container string
+	Namespace string
+}
+
+type PhpStanConfig struct {
+	Level    string
+	Baseline bool
+}
+
+type PhpCsFixerConfig struct {
+	// A rule set such as @Symfony, or "custom" to only enable CustomRules
+	Ruleset     string
+	CustomRules string
+	Risky       bool
+}
+
+type PhpCSConfig struct {
+	Standard string
+}
+
+type PhpMDConfig struct {
+	Rulesets     []string
+	Complexity   string
+	MethodLength string
+}
+
+type PsalmConfig struct {
+	Baseline bool
+}
+
+type HooksConfig struct {
+	Manager   HookManager
+	PreCommit []tools.Tool
+	PrePush   []tools.Tool
+	CommitMsg bool
+	AutoFix   bool
+}
+
+// ScriptsConfig holds the shell commands run on the host around the installation, declared as hooks in FileName
+type ScriptsConfig struct {
+	BeforeInstall []string
+	// Run once the tool is installed and configured
+	AfterTool map[tools.Tool][]string
+	AfterAll  []string
+}
+
+// ComposerConfig tells where the tools are installed from, declared in FileName for corporate infrastructures
+type ComposerConfig struct {
+	// Added to the composer.json of each tool directory, e.g. Private Packagist, Artifactory or GitLab
+	Repositories []Repository
+	// Only the Repositories are used, e.g. when one of them mirrors packagist.org
+	DisablePackagist bool
+	// auth.json on the host holding the credentials of the repositories, mounted in docker compose run containers
+	// and given as COMPOSER_AUTH to exec ones. Local runs already read the auth.json of the composer home.
+	AuthFile string
+	// Seconds composer waits for the processes it runs (e.g. git clones of VCS repositories) as
+	// COMPOSER_PROCESS_TIMEOUT, the default of composer (300) when 0
+	ProcessTimeout int
+	// The composer.lock of the tool directories are ignored by git, install-php then updates the tools to their
+	// latest versions instead of installing the locked ones
+	IgnoreLocks bool
+}
+
+// Repository is a repository of composer.json, see https://getcomposer.org/doc/05-repositories.md
+type Repository struct {
+	// e.g. composer, vcs or artifact
+	Type string `yaml:"type" json:"type"`
+	Url  string `yaml:"url" json:"url"`
+}
+
+// ReportFormat tells how the report command writes the issues found by the tools
+type ReportFormat string
+
+const (
+	// One SARIF log holding a run per tool, e.g. for GitHub code scanning
+	SarifReport ReportFormat = "sarif"
+	// JUnit XML holding a test suite per tool, e.g. for the test reports of GitLab or Jenkins
+	JUnitReport ReportFormat = "junit"
+	// Self-contained page summarising the issues, to be shared with people not using the command line
+	HTMLReport ReportFormat = "html"
+	// Directory of shields.io endpoint files (issue counts, level of PHPStan) for the badges of the README
+	BadgesReport ReportFormat = "badges"
+)
+
+// File each format is written to when none is given, the directory of the badges
+var ReportFiles = map[ReportFormat]string{
+	SarifReport:  "phptooling.sarif",
+	JUnitReport:  "phptooling.junit.xml",
+	HTMLReport:   "phptooling.html",
+	BadgesReport: "build/badges",
+}
+
+// ReportConfig holds the options of the report command
+type ReportConfig struct {
+	Format ReportFormat
+	// Written relative to the project, see ReportFiles
+	File string
+	// Upload the SARIF log to GitHub code scanning
+	Upload bool
+	// Post a summary on the pull request (GitHub) or merge request (GitLab) being checked, whatever the format
+	Comment bool
+	// Webhooks receiving the results, see File
+	Notifications []Notification
+	// Fail when blocking tools found issues
+	FailOnIssues bool
+}
+
+// Services of the notification webhooks
+const (
+	SlackNotification = "slack"
+	TeamsNotification = "teams"
+)
+
+// Notification is a webhook of a chat channel receiving the results of the report command, e.g. of nightly runs
+type Notification struct {
+	// slack or teams
+	Type string `yaml:"type"`
+	// Variables such as ${SLACK_WEBHOOK_URL} are expanded when notifying, so that the URL doesn't need to be committed
+	Url string `yaml:"url"`
+}
+
+type TemplatesConfig struct {
+	// Git repository or .tar.gz URL containing config templates, a ref can be appended after #
+	Source  string
+	Refresh bool
+	// Expected SHA-256 of the archive, not checked when empty
+	Sha256 string
+	// Ids of the GPG keys allowed to sign the archive (<URL>.asc) or the fetched commit, not checked when empty
+	SigningKeys []string
+}
+
+/**
+ * Return the configuration proposed by default by the wizard
+ */
+func Default() *Config {
+	return &Config{
+		Environment:   Local,
+		DockerCommand: "exec",
+		// A volume avoids files of the host cache belonging to the user of the container
+		DockerComposerCache: "phptooling-composer-cache",
+		ToolsDirectory:      "./tools",
+		JustFile:            "justfile",
+		InstallMethod:       ComposerInstall,
+		Paths:               []string{"src", "tests"},
+		CacheDirectory:      "var/cache/tools",
+		Framework:           Symfony,
+		PhpCsFixer: PhpCsFixerConfig{
+			Ruleset: "@Symfony",
+		},
+		PhpCS: PhpCSConfig{
+			Standard: "Symfony",
+		},
+		PhpMD: PhpMDConfig{
+			Rulesets:     []string{"cleancode", "codesize", "design", "controversial", "unusedcode", "naming"},
+			Complexity:   "10",
+			MethodLength: "100",
+		},
+		Hooks: HooksConfig{
+			Manager: NativeHooks,
+		},
+	}
+}
+
+func (config *Config) IsToolSelected(tool tools.Tool) bool {
+	for _, selected := range config.Tools {
+		if selected == tool {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Whether the issues found by the tool are only reported, without failing the checks
+ */
+func (config *Config) IsAdvisory(tool tools.Tool) bool {
+	return config.Policies[tool] == Advisory
+}
+
+/**
+ * Return the packages of the tool as given to composer require, its main package (the first one of the registry)
+ * followed by the version constraint of the tool if any
+ */
+func (config *Config) Requirements(definition tools.Definition) []string {
+	requirements := definition.PackageNames(string(config.Framework), config.PhpCS.Standard)
+	constraint := config.Versions[definition.Id]
+
+	// The stability flag lets composer pick a pre-release of this package only, e.g. phpstan/phpstan:^2.0@RC
+	if stability := config.MinimumStability(definition.Id); stability != "" {
+		constraint += "@" + stability
+	}
+
+	for i, name := range requirements {
+		if constraint != "" && name == definition.Packages[0].Name {
+			requirements[i] = name + ":" + constraint
+		}
+	}
+
+	return requirements
+}
+
+/**
+ * Return the minimum stability of the tool when it accepts pre-releases, empty for stable releases only
+ */
+func (config *Config) MinimumStability(tool tools.Tool) string {
+	if stability := config.Stability[tool]; stability != "stable" {
+		return stability
+	}
+
+	return ""
+}
+
+/**
+ * Whether the tool is installed with composer global require
+ */
+func (config *Config) InstallsGlobally(tool tools.Tool) bool {
+	for _, global := range config.GlobalTools {
+		if global == tool {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Whether the tool is installed as a phar
+ */
+func (config *Config) UsesPhar(tool tools.Tool) bool {
+	definition, found := tools.Get(tool)
+
+	return found && !config.InstallsGlobally(tool) && config.MinimumStability(tool) == "" && config.InstallMethod == PharInstall && definition.SupportsPhar(string(config.Framework), config.PhpCS.Standard)
+}
+
+/**
+ * Whether the tool is installed with PHIVE
+ */
+func (config *Config) UsesPhive(tool tools.Tool) bool {
+	definition, found := tools.Get(tool)
+
+	return found && !config.InstallsGlobally(tool) && config.MinimumStability(tool) == "" && config.InstallMethod == PhiveInstall && definition.SupportsPhive(string(config.Framework), config.PhpCS.Standard)
+}
+
+/**
+ * Whether the tools are installed in namespaces of bamarni/composer-bin-plugin
+ */
+func (config *Config) UsesBinPlugin() bool {
+	return config.InstallMethod == BinPluginInstall
+}
+
+/**
+ * Whether the tools are required by the project itself, their binaries are then in vendor/bin
+ */
+func (config *Config) UsesRequireDev() bool {
+	return config.InstallMethod == RequireDevInstall
+}
+
+/**
+ * Return the binary of the tool relative to the tools directory, following how it is installed
+ */
+func (config *Config) Binary(tool tools.Tool) string {
+	definition, _ := tools.Get(tool)
+
+	if config.UsesPhar(tool) {
+		return definition.PharBinary()
+	}
+
+	if config.UsesPhive(tool) {
+		return definition.PhiveBinary()
+	}
+
+	return definition.Binary
+}
+
+func (config *Config) HasOutput(output Output) bool {
+	for _, selected := range config.Outputs {
+		if selected == output {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Whether a selected pre-commit check is able to fix the issues it reports
+ */
+func (hooks HooksConfig) HasPreCommitFixer() bool {
+	for _, tool := range hooks.PreCommit {
+		if definition, _ := tools.Get(tool); definition.Fix != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/tools"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Optional configuration committed at the root of the project
+const FileName = ".phptooling.yaml"
+
+// Prefix of the hooks run after the installation of a tool, followed by its id
+const afterToolHook = "after_tool:"
+
+// File is the content of FileName
+type File struct {
+	// Shell commands by hook: before_install, after_tool:<tool id> and after_all
+	Hooks map[string][]string `yaml:"hooks"`
+	// Version constraint by tool id, e.g. "phpstan: ^1.12"
+	Versions map[tools.Tool]string `yaml:"versions"`
+	// Minimum stability by tool id, e.g. "phpstan: RC"
+	Stability map[tools.Tool]string `yaml:"stability"`
+	// Blocking (the default) or advisory by tool id, e.g. "phpmd: advisory"
+	Policy   map[tools.Tool]Policy `yaml:"policy"`
+	Composer struct {
+		Repositories     []Repository `yaml:"repositories"`
+		DisablePackagist bool         `yaml:"disable_packagist"`
+		AuthFile         string       `yaml:"auth_file"`
+		ProcessTimeout   int          `yaml:"process_timeout"`
+		IgnoreLocks      bool         `yaml:"ignore_locks"`
+	} `yaml:"composer"`
+	// Webhooks notified by the report command
+	Notifications []Notification `yaml:"notifications"`
+}
+
+/**
+ * Read the configuration file of the project into the Config, nothing changes when there is none
+ */
+func (config *Config) LoadFile(projectDirectory string) error {
+	data, readErr := os.ReadFile(filepath.Join(projectDirectory, FileName))
+
+	if readErr != nil {
+		return nil
+	}
+
+	var file File
+	parseErr := yaml.UnmarshalStrict(data, &file)
+
+	if parseErr != nil {
+		return failure.Wrap(failure.Configuration, "parse "+FileName, parseErr)
+	}
+
+	for hook, commands := range file.Hooks {
+		switch {
+		case hook == "before_install":
+			config.Scripts.BeforeInstall = commands
+		case hook == "after_all":
+			config.Scripts.AfterAll = commands
+		case strings.HasPrefix(hook, afterToolHook):
+			tool := tools.Tool(strings.TrimPrefix(hook, afterToolHook))
+
+			if _, found := tools.Get(tool); !found {
+				return failure.New(failure.Configuration, "parse "+FileName, "unknown tool in the hook "+hook)
+			}
+
+			if config.Scripts.AfterTool == nil {
+				config.Scripts.AfterTool = make(map[tools.Tool][]string)
+			}
+
+			config.Scripts.AfterTool[tool] = commands
+		default:
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown hook "+hook+", expected before_install, after_tool:<tool> or after_all")
+		}
+	}
+
+	for tool, constraint := range file.Versions {
+		if _, found := tools.Get(tool); !found {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown tool "+string(tool)+" in versions")
+		}
+
+		if config.Versions == nil {
+			config.Versions = make(map[tools.Tool]string)
+		}
+
+		config.Versions[tool] = strings.TrimSpace(constraint)
+	}
+
+	for tool, stability := range file.Stability {
+		if _, found := tools.Get(tool); !found {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown tool "+string(tool)+" in stability")
+		}
+
+		index := slices.IndexFunc(Stabilities, func(known string) bool {
+			return strings.EqualFold(known, strings.TrimSpace(stability))
+		})
+
+		if index < 0 {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown stability "+stability+" for "+string(tool)+", expected one of "+strings.Join(Stabilities, ", "))
+		}
+
+		if config.Stability == nil {
+			config.Stability = make(map[tools.Tool]string)
+		}
+
+		config.Stability[tool] = Stabilities[index]
+	}
+
+	for tool, policy := range file.Policy {
+		if _, found := tools.Get(tool); !found {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown tool "+string(tool)+" in policy")
+		}
+
+		if policy != Blocking && policy != Advisory {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown policy "+string(policy)+" for "+string(tool)+", expected blocking or advisory")
+		}
+	}
+
+	config.Policies = file.Policy
+
+	for _, repository := range file.Composer.Repositories {
+		if repository.Type == "" || repository.Url == "" {
+			return failure.New(failure.Configuration, "parse "+FileName, "composer repositories need a type and an url")
+		}
+	}
+
+	config.Composer.Repositories = file.Composer.Repositories
+	config.Composer.DisablePackagist = file.Composer.DisablePackagist
+	config.Composer.AuthFile = file.Composer.AuthFile
+	// Set by the flag or the file, the wizard asks again
+	config.Composer.IgnoreLocks = config.Composer.IgnoreLocks || file.Composer.IgnoreLocks
+
+	if file.Composer.ProcessTimeout < 0 {
+		return failure.New(failure.Configuration, "parse "+FileName, "the composer process_timeout must be a number of seconds")
+	}
+
+	// The flag wins over the file
+	if config.Composer.ProcessTimeout == 0 {
+		config.Composer.ProcessTimeout = file.Composer.ProcessTimeout
+	}
+
+	if config.Composer.DisablePackagist && len(config.Composer.Repositories) == 0 {
+		return failure.New(failure.Configuration, "parse "+FileName, "packagist.org can only be disabled along with other composer repositories")
+	}
+
+	for _, notification := range file.Notifications {
+		if notification.Type != SlackNotification && notification.Type != TeamsNotification {
+			return failure.New(failure.Configuration, "parse "+FileName, "unknown notification type "+notification.Type+", expected slack or teams")
+		}
+
+		if notification.Url == "" {
+			return failure.New(failure.Configuration, "parse "+FileName, "the "+notification.Type+" notification needs an url")
+		}
+	}
+
+	config.Report.Notifications = file.Notifications
+
+	return nil
+}
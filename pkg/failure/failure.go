@@ -0,0 +1,131 @@
+package failure
+
+import (
+	"errors"
+)
+
+// Kind classifies errors so that the command line exits with a code telling what went wrong
+type Kind int
+
+const (
+	Unknown Kind = iota
+	// Docker, git, the working directory or a project file such as composer.json can't be used
+	Environment
+	// A composer command failed, e.g. because of conflicting requirements
+	Composer
+	// Another command run in the project failed
+	Command
+	// A generated file already exists with a different content and no resolution was given
+	FileConflict
+	// A file of the project can't be read or written
+	FileSystem
+	// The answers, the templates or the tool definitions are invalid
+	Configuration
+	// The user cancelled the run
+	Aborted
+	// A downloaded file doesn't match its expected checksum
+	Verification
+	// Blocking tools found issues in the project
+	Issues
+)
+
+// Exit codes of the command line by kind, 2 is kept for invalid flags as used by the flag package
+var exitCodes = map[Kind]int{
+	Unknown:       1,
+	Environment:   3,
+	Composer:      4,
+	Command:       5,
+	FileConflict:  6,
+	FileSystem:    7,
+	Configuration: 8,
+	Verification:  9,
+	Issues:        10,
+	Aborted:       130,
+}
+
+type Error struct {
+	Kind Kind
+	// What was being done when the error happened, e.g. "write phpstan.neon"
+	Operation string
+	Err       error
+	// Output of the failed command which wasn't shown on the console, e.g. behind a spinner
+	Output string
+}
+
+func (err *Error) Error() string {
+	return err.Operation + ": " + err.Err.Error()
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+/**
+ * Wrap the error with its kind and the operation which failed, nil is returned for a nil error
+ */
+func Wrap(kind Kind, operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Kind: kind, Operation: operation, Err: err}
+}
+
+/**
+ * Same as Wrap, errors already classified (e.g. by a command runner) are returned as is
+ */
+func Classify(kind Kind, operation string, err error) error {
+	if KindOf(err) != Unknown {
+		return err
+	}
+
+	return Wrap(kind, operation, err)
+}
+
+/**
+ * Create an error of the kind from a message
+ */
+func New(kind Kind, operation string, message string) error {
+	return &Error{Kind: kind, Operation: operation, Err: errors.New(message)}
+}
+
+/**
+ * Return the kind of the first classified error of the chain, Unknown if there is none
+ */
+func KindOf(err error) Kind {
+	var classified *Error
+
+	if errors.As(err, &classified) {
+		return classified.Kind
+	}
+
+	return Unknown
+}
+
+/**
+ * Return the hidden output of the first failed command of the chain, empty if there is none
+ */
+func OutputOf(err error) string {
+	var classified *Error
+
+	for errors.As(err, &classified) {
+		if classified.Output != "" {
+			return classified.Output
+		}
+
+		err = classified.Err
+	}
+
+	return ""
+}
+
+/**
+ * Return the exit code of the command line for the error, 0 for a nil error
+ */
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	return exitCodes[KindOf(err)]
+}
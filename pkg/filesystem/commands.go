@@ -0,0 +1,230 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/runner"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commands accesses the files with commands run in the environment of the project, for environments which don't
+// share the project directory with the host (e.g. a Kubernetes pod), see Mirror
+type Commands struct {
+	Runner  runner.CommandRunner
+	Context context.Context
+}
+
+// Exit code of the read scripts when the file is missing
+const missingExitCode = 3
+
+// Format of stat describing a file: size, mode in hexadecimal, modification time and name
+const statFormat = "%s %f %Y %n"
+
+func (commands Commands) ReadFile(name string) ([]byte, error) {
+	output, err := commands.read(name, "open", `cat "$1"`)
+
+	return []byte(output), err
+}
+
+/**
+ * List the directory with a single command, the entries are sorted by name like with os.ReadDir
+ */
+func (commands Commands) ReadDir(name string) ([]fs.DirEntry, error) {
+	output, err := commands.read(name, "readdir", `cd "$1" && for entry in * .[!.]* ..?*; do if [ -e "$entry" ]; then stat -L -c '`+statFormat+`' "$entry"; fi; done`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.DirEntry
+
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		info, parseErr := parseStat(line)
+
+		if parseErr != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: parseErr}
+		}
+
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (commands Commands) Stat(name string) (fs.FileInfo, error) {
+	output, err := commands.read(name, "stat", `stat -L -c '`+statFormat+`' "$1"`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, parseErr := parseStat(strings.TrimSuffix(output, "\n"))
+
+	if parseErr != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: parseErr}
+	}
+
+	return info, nil
+}
+
+/**
+ * Transfer the content to the environment with a single command, the path is given as argument so that nothing is
+ * interpreted by the shell. The content is written next to the file and renamed over it, an interrupted transfer
+ * leaves the file as it was.
+ */
+func (commands Commands) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return commands.transfer(name, data, `mkdir -p "$(dirname "$1")" && cat > "$1.phptooling-tmp" && chmod `+strconv.FormatUint(uint64(perm.Perm()), 8)+` "$1.phptooling-tmp" && mv -f "$1.phptooling-tmp" "$1"`)
+}
+
+/**
+ * Same as WriteFile, the existing content is copied with its permissions before the data
+ */
+func (commands Commands) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	return commands.transfer(name, data, `{ cp -p "$1" "$1.phptooling-tmp" 2>/dev/null || { : > "$1.phptooling-tmp" && chmod `+strconv.FormatUint(uint64(perm.Perm()), 8)+` "$1.phptooling-tmp"; }; } && cat >> "$1.phptooling-tmp" && mv -f "$1.phptooling-tmp" "$1"`)
+}
+
+func (commands Commands) MkdirAll(name string, _ fs.FileMode) error {
+	directory, err := commands.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	return commands.Runner.Run(commands.Context, []string{"mkdir", "-p", directory})
+}
+
+func (commands Commands) Chmod(name string, perm fs.FileMode) error {
+	file, err := commands.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	return commands.Runner.Run(commands.Context, []string{"chmod", strconv.FormatUint(uint64(perm.Perm()), 8), file})
+}
+
+func (commands Commands) Remove(name string) error {
+	file, err := commands.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	return commands.Runner.Run(commands.Context, []string{"rm", "-f", file})
+}
+
+func (commands Commands) RemoveAll(name string) error {
+	file, err := commands.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	return commands.Runner.Run(commands.Context, []string{"rm", "-rf", file})
+}
+
+/**
+ * Run the shell script with the path of the file as first argument and the data as input
+ */
+func (commands Commands) transfer(name string, data []byte, script string) error {
+	file, err := commands.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	return commands.Runner.RunWithInput(commands.Context, []string{"sh", "-c", script, "sh", file}, bytes.NewReader(data))
+}
+
+/**
+ * Run the shell script with the path of the file as first argument and return its output, a missing file is reported
+ * as fs.ErrNotExist like on the host
+ */
+func (commands Commands) read(name string, operation string, script string) (string, error) {
+	file, err := commands.path(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	captured, runErr := runner.Capture(commands.Context, commands.Runner, []string{"sh", "-c", `[ -e "$1" ] || exit ` + strconv.Itoa(missingExitCode) + "; " + script, "sh", file})
+
+	if runErr != nil {
+		return "", runErr
+	}
+
+	switch captured.ExitCode {
+	case 0:
+		return captured.Output, nil
+	case missingExitCode:
+		return "", &fs.PathError{Op: operation, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return "", &fs.PathError{Op: operation, Path: name, Err: errors.New(strings.TrimSpace(captured.ErrorOutput))}
+}
+
+/**
+ * Return the path of the file in the environment of the runner
+ */
+func (commands Commands) path(name string) (string, error) {
+	workingDir, err := commands.Runner.WorkingDirectory(commands.Context)
+
+	return path.Join(workingDir, name), err
+}
+
+// fileInfo describes a file of the environment as listed by stat
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (info fileInfo) Name() string       { return info.name }
+func (info fileInfo) Size() int64        { return info.size }
+func (info fileInfo) Mode() fs.FileMode  { return info.mode }
+func (info fileInfo) ModTime() time.Time { return info.modTime }
+func (info fileInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info fileInfo) Sys() interface{}   { return nil }
+
+/**
+ * Parse a line of stat in statFormat, only directories are told apart from regular files
+ */
+func parseStat(line string) (fileInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+
+	if len(fields) != 4 {
+		return fileInfo{}, errors.New("unexpected output of stat: " + line)
+	}
+
+	size, sizeErr := strconv.ParseInt(fields[0], 10, 64)
+	rawMode, modeErr := strconv.ParseUint(fields[1], 16, 32)
+	modTime, timeErr := strconv.ParseInt(fields[2], 10, 64)
+
+	if err := errors.Join(sizeErr, modeErr, timeErr); err != nil {
+		return fileInfo{}, err
+	}
+
+	mode := fs.FileMode(rawMode & 0777)
+
+	// S_IFDIR
+	if rawMode&0170000 == 0040000 {
+		mode |= fs.ModeDir
+	}
+
+	return fileInfo{name: path.Base(fields[3]), size: size, mode: mode, modTime: time.Unix(modTime, 0)}, nil
+}
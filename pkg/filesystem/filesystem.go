@@ -0,0 +1,20 @@
+package filesystem
+
+import (
+	"io/fs"
+)
+
+// FileSystem gives access to the files of the project, paths are relative to its root
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+	// Create or replace the file along with its missing parent directories
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// Append to the file, which is created if needed
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Chmod(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+}
@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Host accesses the files of the project directly on this machine
+type Host struct {
+	Root string
+}
+
+func (host Host) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(host.path(name))
+}
+
+func (host Host) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(host.path(name))
+}
+
+func (host Host) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(host.path(name))
+}
+
+func (host Host) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	mkdirErr := os.MkdirAll(filepath.Dir(host.path(name)), 0755)
+
+	if mkdirErr != nil {
+		return mkdirErr
+	}
+
+	return WriteAtomically(host.path(name), data, perm)
+}
+
+/**
+ * Write the existing content followed by the data, the file is replaced at once like with WriteFile
+ */
+func (host Host) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	existing, readErr := os.ReadFile(host.path(name))
+
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return readErr
+	}
+
+	return WriteAtomically(host.path(name), append(existing, data...), perm)
+}
+
+func (host Host) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(host.path(name), perm)
+}
+
+func (host Host) Chmod(name string, perm fs.FileMode) error {
+	return os.Chmod(host.path(name), perm)
+}
+
+func (host Host) Remove(name string) error {
+	return os.Remove(host.path(name))
+}
+
+func (host Host) RemoveAll(name string) error {
+	return os.RemoveAll(host.path(name))
+}
+
+/**
+ * Return the path of the file on this machine, the names use slashes whatever the OS
+ */
+func (host Host) path(name string) string {
+	return filepath.Join(host.Root, filepath.FromSlash(name))
+}
+
+/**
+ * Write the data to a temporary file next to the file and rename it over the file, so that an interrupted run never
+ * leaves it truncated. The permissions of an existing file are kept, and symbolic links are followed.
+ */
+func WriteAtomically(file string, data []byte, perm fs.FileMode) error {
+	if target, linkErr := filepath.EvalSymlinks(file); linkErr == nil {
+		file = target
+	}
+
+	if info, statErr := os.Stat(file); statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	temporary, createErr := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+
+	if createErr != nil {
+		return createErr
+	}
+
+	_, writeErr := temporary.Write(data)
+
+	if syncErr := temporary.Sync(); writeErr == nil {
+		writeErr = syncErr
+	}
+
+	if closeErr := temporary.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+
+	if writeErr == nil {
+		writeErr = os.Chmod(temporary.Name(), perm)
+	}
+
+	if writeErr == nil {
+		writeErr = os.Rename(temporary.Name(), file)
+	}
+
+	if writeErr != nil {
+		_ = os.Remove(temporary.Name())
+	}
+
+	return writeErr
+}
@@ -0,0 +1,119 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Mirror keeps the files of the project on the host, where they are committed and where just runs, and writes the
+// ones the tools need (their configuration files and directories) in the environment as well, for environments which
+// don't share the project directory with the host (e.g. a Kubernetes pod). Those are read in the environment, where
+// the tools update them (e.g. the composer.lock of a tool or a report).
+type Mirror struct {
+	Host
+	Environment FileSystem
+	// Files and directories written in both places, relative to the project
+	Paths []string
+}
+
+func (mirror Mirror) ReadFile(name string) ([]byte, error) {
+	return mirror.reader(name).ReadFile(name)
+}
+
+func (mirror Mirror) ReadDir(name string) ([]fs.DirEntry, error) {
+	return mirror.reader(name).ReadDir(name)
+}
+
+func (mirror Mirror) Stat(name string) (fs.FileInfo, error) {
+	return mirror.reader(name).Stat(name)
+}
+
+func (mirror Mirror) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return mirror.apply(name, func(files FileSystem) error {
+		return files.WriteFile(name, data, perm)
+	})
+}
+
+func (mirror Mirror) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	return mirror.apply(name, func(files FileSystem) error {
+		return files.AppendFile(name, data, perm)
+	})
+}
+
+func (mirror Mirror) MkdirAll(name string, perm fs.FileMode) error {
+	return mirror.apply(name, func(files FileSystem) error {
+		return files.MkdirAll(name, perm)
+	})
+}
+
+func (mirror Mirror) Chmod(name string, perm fs.FileMode) error {
+	return mirror.apply(name, func(files FileSystem) error {
+		return files.Chmod(name, perm)
+	})
+}
+
+/**
+ * Remove the file in both places, the files written by the tools (e.g. reports) only exist in the environment
+ */
+func (mirror Mirror) Remove(name string) error {
+	hostErr := mirror.Host.Remove(name)
+
+	if !mirror.Mirrors(name) {
+		return hostErr
+	}
+
+	if errors.Is(hostErr, fs.ErrNotExist) {
+		hostErr = nil
+	}
+
+	return errors.Join(hostErr, mirror.Environment.Remove(name))
+}
+
+func (mirror Mirror) RemoveAll(name string) error {
+	return mirror.apply(name, func(files FileSystem) error {
+		return files.RemoveAll(name)
+	})
+}
+
+/**
+ * Whether the file is also written in the environment: it is one of the Paths or is in one of them
+ */
+func (mirror Mirror) Mirrors(name string) bool {
+	name = path.Clean(name)
+
+	for _, mirrored := range mirror.Paths {
+		mirrored = path.Clean(mirrored)
+
+		if name == mirrored || strings.HasPrefix(name, mirrored+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Return where the file is read: in the environment when it is mirrored, on the host otherwise
+ */
+func (mirror Mirror) reader(name string) FileSystem {
+	if mirror.Mirrors(name) {
+		return mirror.Environment
+	}
+
+	return mirror.Host
+}
+
+/**
+ * Change the file on the host, then in the environment when it is mirrored
+ */
+func (mirror Mirror) apply(name string, change func(files FileSystem) error) error {
+	hostErr := change(mirror.Host)
+
+	if hostErr != nil || !mirror.Mirrors(name) {
+		return hostErr
+	}
+
+	return change(mirror.Environment)
+}
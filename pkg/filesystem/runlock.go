@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// File existing in the project while a run writes to it, relative to the project. It holds the id of the process.
+const RunLockFile = ".phptooling/run.lock"
+
+/**
+ * Create the lock file of the project so that a single run writes to it at a time, the returned function removes it.
+ * The lock left by a run which was killed is taken over once its process is gone.
+ */
+func LockRun(projectDirectory string) (func(), error) {
+	file := filepath.Join(projectDirectory, RunLockFile)
+	mkdirErr := os.MkdirAll(filepath.Dir(file), 0755)
+
+	if mkdirErr != nil {
+		return nil, failure.Wrap(failure.FileSystem, "create "+RunLockFile, mkdirErr)
+	}
+
+	for {
+		lockFile, createErr := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+		if createErr == nil {
+			_, writeErr := lockFile.WriteString(strconv.Itoa(os.Getpid()))
+
+			if closeErr := lockFile.Close(); writeErr == nil {
+				writeErr = closeErr
+			}
+
+			if writeErr != nil {
+				_ = os.Remove(file)
+				return nil, failure.Wrap(failure.FileSystem, "create "+RunLockFile, writeErr)
+			}
+
+			return func() { _ = os.Remove(file) }, nil
+		}
+
+		if !errors.Is(createErr, fs.ErrExist) {
+			return nil, failure.Wrap(failure.FileSystem, "create "+RunLockFile, createErr)
+		}
+
+		data, _ := os.ReadFile(file)
+		pid, pidErr := strconv.Atoi(strings.TrimSpace(string(data)))
+
+		// Empty while the run which created it writes its id
+		if pidErr != nil {
+			return nil, failure.New(failure.Environment, "lock the project", "another run of phptooling is writing to it, remove "+RunLockFile+" if it isn't")
+		}
+
+		if isRunning(pid) {
+			return nil, failure.New(failure.Environment, "lock the project", "another run of phptooling (process "+strconv.Itoa(pid)+") is writing to it, remove "+RunLockFile+" if it isn't")
+		}
+
+		// Left by a run which was killed
+		removeErr := os.Remove(file)
+
+		if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			return nil, failure.Wrap(failure.FileSystem, "remove the stale "+RunLockFile, removeErr)
+		}
+	}
+}
+
+/**
+ * Whether the process exists, processes of other users included
+ */
+func isRunning(pid int) bool {
+	process, findErr := os.FindProcess(pid)
+
+	// Only finds existing processes on Windows, where signals can't be sent
+	if findErr != nil || runtime.GOOS == "windows" {
+		return findErr == nil
+	}
+
+	signalErr := process.Signal(syscall.Signal(0))
+
+	return signalErr == nil || errors.Is(signalErr, os.ErrPermission)
+}
@@ -0,0 +1,480 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/filesystem"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/runner"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory holding one sub-directory of backups per run, relative to the project
+const backupsDirectory = ".phptooling/backups"
+
+// BackupManifest lists the files touched by a run, existing ones are copied next to the manifest
+type BackupManifest struct {
+	Modified []string `json:"modified"`
+	Created  []string `json:"created"`
+	// Directories created by the run, removed along with their content (e.g. the vendor/ directory of tools)
+	Directories []string `json:"directories"`
+	// Existing directories emptied by the run, moved next to the manifest
+	Wiped []string `json:"wiped,omitempty"`
+	// Blocks appended to files (justfile, .gitignore, hooks), stripped by undo so that later changes are kept
+	Appended []AppendedBlock `json:"appended,omitempty"`
+	// Pod the files the tools need were also written in (kubernetes environment), restore and undo revert them there
+	Kubernetes *config.KubernetesConfig `json:"kubernetes,omitempty"`
+	// Files and directories written in the pod as well, see filesystem.Mirror
+	Mirrored []string `json:"mirrored,omitempty"`
+}
+
+// ProjectFiles returns the files of the project the backups of the run described by the manifest are restored to
+type ProjectFiles func(manifest BackupManifest) filesystem.FileSystem
+
+// AppendedBlock is content appended to a file of the project by a run
+type AppendedBlock struct {
+	File    string `json:"file"`
+	Content string `json:"content"`
+}
+
+/**
+ * Save the file (relative to the project) before its first modification during this run
+ */
+func (generator *Generator) BackupFile(relativePath string) error {
+	relativePath = path.Clean(relativePath)
+	generator.initializeBackupDirectory()
+
+	for _, file := range append(generator.backupManifest.Modified, generator.backupManifest.Created...) {
+		if file == relativePath {
+			return nil
+		}
+	}
+
+	data, readErr := generator.Files.ReadFile(relativePath)
+
+	if readErr == nil {
+		destination := filepath.Join(generator.backupDirectory, relativePath)
+
+		mkdirErr := os.MkdirAll(filepath.Dir(destination), 0755)
+
+		if mkdirErr != nil {
+			return failure.Wrap(failure.FileSystem, "back up "+relativePath, mkdirErr)
+		}
+
+		writeErr := os.WriteFile(destination, data, 0644)
+
+		if writeErr != nil {
+			return failure.Wrap(failure.FileSystem, "back up "+relativePath, writeErr)
+		}
+
+		generator.backupManifest.Modified = append(generator.backupManifest.Modified, relativePath)
+	} else {
+		generator.backupManifest.Created = append(generator.backupManifest.Created, relativePath)
+	}
+
+	return generator.writeBackupManifest()
+}
+
+/**
+ * Record the topmost missing directory of the path (relative to the project) before it gets created
+ */
+func (generator *Generator) trackDirectory(relativePath string) error {
+	created := ""
+
+	for directory := path.Clean(relativePath); directory != "." && directory != "/" && !strings.HasPrefix(directory, ".."); directory = path.Dir(directory) {
+		_, err := generator.Files.Stat(directory)
+
+		if err == nil {
+			break
+		}
+
+		created = directory
+	}
+
+	if created == "" {
+		return nil
+	}
+
+	if generator.isInCreatedDirectory(created) {
+		return nil
+	}
+
+	generator.initializeBackupDirectory()
+	generator.backupManifest.Directories = append(generator.backupManifest.Directories, created)
+
+	return generator.writeBackupManifest()
+}
+
+/**
+ * Empty the existing directory (relative to the project) by moving it to the backups of the run, from where it is
+ * restored
+ */
+func (generator *Generator) WipeDirectory(relativePath string) error {
+	relativePath = path.Clean(relativePath)
+	generator.initializeBackupDirectory()
+	destination := filepath.Join(generator.backupDirectory, relativePath)
+
+	mkdirErr := os.MkdirAll(filepath.Dir(destination), 0755)
+
+	if mkdirErr != nil {
+		return failure.Wrap(failure.FileSystem, "wipe "+relativePath, mkdirErr)
+	}
+
+	// Backups are kept on the host, like the project files
+	renameErr := os.Rename(filepath.Join(runner.LocalWorkingDirectory(), relativePath), destination)
+
+	if renameErr != nil {
+		return failure.Wrap(failure.FileSystem, "wipe "+relativePath, renameErr)
+	}
+
+	// The copy of the environment (e.g. the pod) is removed as well, "just install-php" installs its tools again
+	removeErr := generator.Files.RemoveAll(relativePath)
+
+	if removeErr != nil {
+		return failure.Classify(failure.FileSystem, "wipe "+relativePath, removeErr)
+	}
+
+	generator.backupManifest.Wiped = append(generator.backupManifest.Wiped, relativePath)
+	logging.Event("directory", "path", relativePath, "action", "wiped")
+
+	return generator.writeBackupManifest()
+}
+
+/**
+ * Record the content appended to the file (relative to the project) after its backup
+ */
+func (generator *Generator) recordAppended(relativePath string, content string) error {
+	generator.backupManifest.Appended = append(generator.backupManifest.Appended, AppendedBlock{path.Clean(relativePath), content})
+
+	return generator.writeBackupManifest()
+}
+
+func (generator *Generator) initializeBackupDirectory() {
+	if generator.backupDirectory == "" {
+		generator.backupDirectory = filepath.Join(runner.LocalWorkingDirectory(), backupsDirectory, time.Now().Format("20060102-150405"))
+
+		if mirror, isMirror := generator.Files.(filesystem.Mirror); isMirror && generator.Config.Environment == config.Kubernetes {
+			generator.backupManifest.Kubernetes = &generator.Config.Kubernetes
+			generator.backupManifest.Mirrored = mirror.Paths
+		}
+	}
+}
+
+func (generator *Generator) writeBackupManifest() error {
+	data, _ := json.MarshalIndent(generator.backupManifest, "", "  ")
+
+	mkdirErr := os.MkdirAll(generator.backupDirectory, 0755)
+
+	if mkdirErr != nil {
+		return failure.Wrap(failure.FileSystem, "write the backup manifest", mkdirErr)
+	}
+
+	writeErr := os.WriteFile(filepath.Join(generator.backupDirectory, "manifest.json"), data, 0644)
+
+	return failure.Wrap(failure.FileSystem, "write the backup manifest", writeErr)
+}
+
+func (generator *Generator) HasTouchedFiles() bool {
+	manifest := generator.backupManifest
+
+	return len(manifest.Modified) > 0 || len(manifest.Created) > 0 || len(manifest.Directories) > 0 || len(manifest.Wiped) > 0
+}
+
+/**
+ * Tell which files and directories were touched by a failed run
+ */
+func (generator *Generator) ReportTouchedFiles() {
+	manifest := generator.backupManifest
+
+	for _, file := range manifest.Modified {
+		slog.Warn("Modified by the stopped run", "file", file)
+	}
+
+	for _, file := range manifest.Created {
+		// Files of created directories go away with them
+		if !generator.isInCreatedDirectory(file) {
+			slog.Warn("Created by the stopped run", "file", file)
+		}
+	}
+
+	for _, directory := range manifest.Directories {
+		slog.Warn("Created by the stopped run", "directory", directory)
+	}
+
+	for _, directory := range manifest.Wiped {
+		slog.Warn("Wiped by the stopped run", "directory", directory)
+	}
+}
+
+func (generator *Generator) isInCreatedDirectory(relativePath string) bool {
+	for _, directory := range generator.backupManifest.Directories {
+		if relativePath == directory || strings.HasPrefix(relativePath, directory+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Revert the files and directories touched during this run
+ */
+func (generator *Generator) Rollback() error {
+	if generator.backupDirectory == "" {
+		return nil
+	}
+
+	err := restoreRun(generator.Files, runner.LocalWorkingDirectory(), generator.backupDirectory)
+
+	if err == nil {
+		generator.backupDirectory = ""
+		generator.backupManifest = BackupManifest{}
+	}
+
+	return err
+}
+
+/**
+ * Revert the files touched by the last run: backed up files are restored, created files and directories are removed
+ */
+func Restore(files ProjectFiles, projectDirectory string) error {
+	runDirectory, runErr := getLastRun(projectDirectory, "restore")
+
+	if runErr != nil {
+		return runErr
+	}
+
+	manifest, manifestErr := readBackupManifest(runDirectory)
+
+	if manifestErr != nil {
+		return manifestErr
+	}
+
+	return restoreManifest(files(manifest), projectDirectory, runDirectory, manifest)
+}
+
+/**
+ * Undo the last run like Restore, except for the blocks it appended which are stripped from their files, keeping the
+ * changes made to them since. A file whose block was edited is restored from its backup.
+ */
+func Undo(files ProjectFiles, projectDirectory string) error {
+	runDirectory, runErr := getLastRun(projectDirectory, "undo")
+
+	if runErr != nil {
+		return runErr
+	}
+
+	manifest, manifestErr := readBackupManifest(runDirectory)
+
+	if manifestErr != nil {
+		return manifestErr
+	}
+
+	projectFiles := files(manifest)
+	stripped, stripErr := stripAppendedBlocks(projectFiles, manifest)
+
+	if stripErr != nil {
+		return stripErr
+	}
+
+	manifest.Modified = slices.DeleteFunc(manifest.Modified, func(file string) bool {
+		return slices.Contains(stripped, file)
+	})
+	manifest.Created = slices.DeleteFunc(manifest.Created, func(file string) bool {
+		return slices.Contains(stripped, file)
+	})
+
+	return restoreManifest(projectFiles, projectDirectory, runDirectory, manifest)
+}
+
+/**
+ * Return the backup directory of the last run, failing when there is none
+ */
+func getLastRun(projectDirectory string, operation string) (string, error) {
+	root := filepath.Join(projectDirectory, backupsDirectory)
+	// A missing backups directory is reported as having no run
+	entries, _ := os.ReadDir(root)
+
+	var runs []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			runs = append(runs, entry.Name())
+		}
+	}
+
+	if len(runs) == 0 {
+		return "", failure.New(failure.FileSystem, operation, "no backup to "+operation+" in "+backupsDirectory)
+	}
+
+	sort.Strings(runs)
+
+	return filepath.Join(root, runs[len(runs)-1]), nil
+}
+
+func readBackupManifest(runDirectory string) (BackupManifest, error) {
+	var manifest BackupManifest
+	data, readErr := os.ReadFile(filepath.Join(runDirectory, "manifest.json"))
+
+	if readErr != nil {
+		return manifest, failure.Wrap(failure.FileSystem, "read the backup manifest", readErr)
+	}
+
+	parseErr := json.Unmarshal(data, &manifest)
+
+	return manifest, failure.Wrap(failure.FileSystem, "parse the backup manifest", parseErr)
+}
+
+/**
+ * Remove the blocks appended by the run from their files and return the files done, a created file left empty is
+ * removed. The files whose blocks can't be found as appended are left to be restored from their backup.
+ */
+func stripAppendedBlocks(files filesystem.FileSystem, manifest BackupManifest) ([]string, error) {
+	contents := make(map[string]string)
+	var appendedFiles, changed []string
+
+	for _, block := range manifest.Appended {
+		content, found := contents[block.File]
+
+		if !found {
+			data, readErr := files.ReadFile(block.File)
+
+			if readErr != nil {
+				continue
+			}
+
+			content = string(data)
+			appendedFiles = append(appendedFiles, block.File)
+		}
+
+		index := strings.LastIndex(content, block.Content)
+
+		if index == -1 {
+			slog.Warn("The block appended by the run was changed, the file is restored from its backup", "file", block.File)
+			changed = append(changed, block.File)
+			continue
+		}
+
+		contents[block.File] = content[:index] + content[index+len(block.Content):]
+	}
+
+	var stripped []string
+
+	for _, file := range appendedFiles {
+		if slices.Contains(changed, file) {
+			continue
+		}
+
+		content := contents[file]
+
+		if slices.Contains(manifest.Created, file) && strings.TrimSpace(content) == "" {
+			removeErr := files.Remove(file)
+
+			if removeErr != nil {
+				return nil, failure.Wrap(failure.FileSystem, "remove "+file, removeErr)
+			}
+
+			slog.Info("Removed", "file", file)
+			logging.Event("file", "path", file, "action", "removed")
+		} else {
+			// The file exists, it keeps its permissions
+			writeErr := files.WriteFile(file, []byte(content), 0644)
+
+			if writeErr != nil {
+				return nil, failure.Classify(failure.FileSystem, "undo the changes of "+file, writeErr)
+			}
+
+			slog.Info("Removed the blocks appended by the run", "file", file)
+			logging.Event("file", "path", file, "action", "stripped")
+		}
+
+		stripped = append(stripped, file)
+	}
+
+	return stripped, nil
+}
+
+/**
+ * Revert the files of the run, whose backups are always kept on the host
+ */
+func restoreRun(files filesystem.FileSystem, projectDirectory string, runDirectory string) error {
+	manifest, manifestErr := readBackupManifest(runDirectory)
+
+	if manifestErr != nil {
+		return manifestErr
+	}
+
+	return restoreManifest(files, projectDirectory, runDirectory, manifest)
+}
+
+func restoreManifest(files filesystem.FileSystem, projectDirectory string, runDirectory string, manifest BackupManifest) error {
+	for _, file := range manifest.Modified {
+		content, backupErr := os.ReadFile(filepath.Join(runDirectory, file))
+
+		if backupErr != nil {
+			return failure.Wrap(failure.FileSystem, "read the backup of "+file, backupErr)
+		}
+
+		writeErr := files.WriteFile(file, content, 0644)
+
+		if writeErr != nil {
+			return failure.Classify(failure.FileSystem, "restore "+file, writeErr)
+		}
+
+		slog.Info("Restored", "file", file)
+		logging.Event("file", "path", file, "action", "restored")
+	}
+
+	for _, file := range manifest.Created {
+		removeErr := files.Remove(file)
+
+		if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			return failure.Wrap(failure.FileSystem, "remove "+file, removeErr)
+		}
+
+		slog.Info("Removed", "file", file)
+		logging.Event("file", "path", file, "action", "removed")
+	}
+
+	// Directories are removed last as they may contain created files
+	for i := len(manifest.Directories) - 1; i >= 0; i-- {
+		directory := manifest.Directories[i]
+		removeErr := files.RemoveAll(directory)
+
+		if removeErr != nil {
+			return failure.Wrap(failure.FileSystem, "remove "+directory, removeErr)
+		}
+
+		slog.Info("Removed", "directory", directory)
+		logging.Event("directory", "path", directory, "action", "removed")
+	}
+
+	for _, directory := range manifest.Wiped {
+		removeErr := files.RemoveAll(directory)
+
+		if removeErr != nil {
+			return failure.Wrap(failure.FileSystem, "remove "+directory, removeErr)
+		}
+
+		renameErr := os.Rename(filepath.Join(runDirectory, directory), filepath.Join(projectDirectory, directory))
+
+		if renameErr != nil {
+			return failure.Wrap(failure.FileSystem, "restore "+directory, renameErr)
+		}
+
+		slog.Info("Restored", "directory", directory)
+		logging.Event("directory", "path", directory, "action", "restored")
+	}
+
+	return failure.Wrap(failure.FileSystem, "remove the backup", os.RemoveAll(runDirectory))
+}
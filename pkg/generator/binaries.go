@@ -0,0 +1,87 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"path"
+	"strings"
+)
+
+// Directory of the binaries of the packages required by the project, relative to it
+const projectBinDirectory = "vendor/bin"
+
+/**
+ * Return the absolute path, where commands are run, of the binary given relative to the directory of the tool: in the
+ * global bin directory for the tools installed globally, in vendor/bin for the ones required by the project and in
+ * the tools directory otherwise
+ */
+func (generator *Generator) ToolBinary(tool tools.Tool, binary string) (string, error) {
+	if generator.Config.InstallsGlobally(tool) {
+		binDirectory, err := generator.GlobalBinDirectory()
+
+		return binDirectory + "/" + path.Base(binary), err
+	}
+
+	if generator.Config.UsesRequireDev() {
+		workingDir, err := generator.WorkingDirectory()
+
+		return path.Join(workingDir, projectBinDirectory, path.Base(binary)), err
+	}
+
+	toolsDir, err := generator.ToolsDirectory()
+
+	return toolsDir + "/" + binary, err
+}
+
+/**
+ * Return the vendor directory of the packages of the tool, as referenced from configuration files (e.g. the extensions
+ * of PHPStan): the one of the global composer for the tools installed globally, the one of the project with
+ * require-dev and the one of the tool in the tools directory otherwise
+ */
+func (generator *Generator) ToolVendorDirectory(tool tools.Tool) (string, error) {
+	if generator.Config.InstallsGlobally(tool) {
+		// The bin directory is vendor/bin of the global composer unless configured otherwise
+		binDirectory, err := generator.GlobalBinDirectory()
+
+		return path.Dir(binDirectory), err
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return "vendor", nil
+	}
+
+	return generator.RelativeToolsDirectory() + "/" + string(tool) + "/vendor", nil
+}
+
+/**
+ * Same as ToolBinary for the justfile, which reads the global bin directory when it is run. The binary is quoted for
+ * the shell running the recipes when its path needs it, e.g. when the project directory has spaces.
+ */
+func (generator *Generator) JustFileBinary(tool tools.Tool, binary string, toolsDir string) string {
+	if generator.Config.InstallsGlobally(tool) {
+		return runner.QuoteArgument("{{" + globalBinVariable + "}}/" + path.Base(binary))
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return projectBinDirectory + "/" + path.Base(binary)
+	}
+
+	return runner.QuoteArgument(toolsDir + "/" + binary)
+}
+
+/**
+ * Same as ToolBinary for CI, where the global bin directory is read by the step running the tool
+ */
+func (generator *Generator) ciBinary(tool tools.Tool, toolsDir string) string {
+	binary := generator.Config.Binary(tool)
+
+	if generator.Config.InstallsGlobally(tool) {
+		return `"$(` + strings.Join(globalBinCommand, " ") + `)"/` + path.Base(binary)
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return projectBinDirectory + "/" + path.Base(binary)
+	}
+
+	return runner.QuoteArgument(toolsDir + "/" + binary)
+}
@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"path"
+)
+
+/**
+ * Require bamarni/composer-bin-plugin in the project unless it already is, and make its target directory the tools
+ * directory. The composer files of the project are backed up first, its vendor/ directory keeps the plugin on
+ * rollback.
+ */
+func (generator *Generator) InstallBinPlugin() error {
+	operation := "install " + project.BinPluginPackage
+	composerJson, found, readErr := project.ReadComposerJson(runner.LocalWorkingDirectory())
+
+	if readErr != nil {
+		return readErr
+	}
+
+	if !found {
+		return failure.New(failure.Configuration, operation, "the project has no composer.json to require the plugin from, choose another install method")
+	}
+
+	for _, file := range []string{"composer.json", "composer.lock"} {
+		backupErr := generator.BackupFile(file)
+
+		if backupErr != nil {
+			return backupErr
+		}
+	}
+
+	var commands [][]string
+
+	if !composerJson.Requires(project.BinPluginPackage) {
+		commands = append(commands,
+			[]string{"composer", "config", "--no-plugins", "allow-plugins." + project.BinPluginPackage, "true"},
+			// Binaries are run from their namespace rather than linked in vendor/bin, next to the ones of the project
+			[]string{"composer", "config", "--no-plugins", "--json", "extra.bamarni-bin.bin-links", "false"},
+			[]string{"composer", "require", "--dev", project.BinPluginPackage},
+		)
+	}
+
+	targetDirectory := composerJson.Extra.BinPlugin.TargetDirectory
+
+	if targetDirectory == "" {
+		targetDirectory = project.DefaultBinPluginDirectory
+	}
+
+	if path.Clean(targetDirectory) != generator.RelativeToolsDirectory() {
+		commands = append(commands, []string{"composer", "config", "--no-plugins", "extra.bamarni-bin.target-directory", generator.RelativeToolsDirectory()})
+	}
+
+	for _, command := range commands {
+		runErr := generator.Run(command)
+
+		if runErr != nil {
+			return runErr
+		}
+	}
+
+	return nil
+}
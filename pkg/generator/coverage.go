@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"slices"
+	"strings"
+)
+
+// Directory of the coverage reports, relative to the project
+const CoverageDirectory = "build/coverage"
+
+// Settings enabling the coverage drivers, xdebug only collects coverage in the coverage mode
+var coverageSettings = map[string]string{
+	"pcov":   "-d pcov.enabled=1",
+	"xdebug": "-d xdebug.mode=coverage",
+}
+
+/**
+ * Add the coverage recipe running the tests with the coverage driver found where PHP runs
+ */
+func (generator *Generator) AddCoverageRecipe() error {
+	driver, err := generator.detectCoverageDriver()
+
+	if err != nil {
+		return err
+	}
+
+	// A missing driver was reported by CheckPhpExtensions
+	return generator.AddToJustFile("coverage", func(composerAlias string, phpAlias string, toolsDir string) (string, error) {
+		return `
+# Run the tests with code coverage, written as clover and cobertura reports to ` + CoverageDirectory + `
+coverage:
+    ` + strings.TrimSpace(phpAlias+" "+coverageSettings[driver]) + ` ` + generator.getCoverageArguments() + `
+`, nil
+	})
+}
+
+/**
+ * Return the arguments of the test framework writing the clover and cobertura reports
+ */
+func (generator *Generator) getCoverageArguments() string {
+	return generator.Config.TestFramework.Binary() + " --coverage-clover " + CoverageDirectory + "/clover.xml --coverage-cobertura " + CoverageDirectory + "/cobertura.xml"
+}
+
+/**
+ * Return the coverage driver loaded by PHP (pcov or xdebug, pcov first as it is faster), empty when there is none
+ */
+func (generator *Generator) detectCoverageDriver() (string, error) {
+	extensions, err := generator.PhpExtensions()
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, driver := range []string{"pcov", "xdebug"} {
+		if slices.Contains(extensions, driver) {
+			return driver, nil
+		}
+	}
+
+	return "", nil
+}
+
+/**
+ * Return the steps of the composite action running the tests with coverage and keeping the reports as an artifact,
+ * setup-php enables pcov for them
+ */
+func (generator *Generator) getCICoverageSteps() string {
+	if !generator.Config.HasOutput(config.Coverage) {
+		return ""
+	}
+
+	return `
+    - name: Run the tests with coverage
+      shell: bash
+      run: php ` + generator.getCoverageArguments() + `
+
+    - name: Keep the coverage reports
+      if: ${{ !cancelled() }}
+      uses: actions/upload-artifact@v4
+      with:
+        name: coverage-reports
+        path: ` + CoverageDirectory + `/
+        if-no-files-found: ignore
+`
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"strings"
+)
+
+type DiffOperation int
+
+const (
+	DiffEqual DiffOperation = iota
+	DiffDelete
+	DiffInsert
+)
+
+type DiffLine struct {
+	Operation DiffOperation
+	Text      string
+}
+
+/**
+ * Compute a line based diff turning before into after, using the longest common subsequence
+ */
+func diffLines(before string, after string) []DiffLine {
+	a := strings.Split(strings.TrimRight(before, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(after, "\n"), "\n")
+
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			lines = append(lines, DiffLine{DiffEqual, a[i]})
+			i++
+			j++
+		} else if i < len(a) && (j == len(b) || lengths[i+1][j] >= lengths[i][j+1]) {
+			lines = append(lines, DiffLine{DiffDelete, a[i]})
+			i++
+		} else {
+			lines = append(lines, DiffLine{DiffInsert, b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
+
+func hasDifferences(lines []DiffLine) bool {
+	for _, line := range lines {
+		if line.Operation != DiffEqual {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Format the diff for the terminal, only keeping a few unchanged lines around the changes
+ */
+func formatDiff(lines []DiffLine) string {
+	const context = 3
+
+	deleted := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	inserted := lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	var output strings.Builder
+
+	for index, line := range lines {
+		switch line.Operation {
+		case DiffDelete:
+			output.WriteString(deleted.Render("- "+line.Text) + "\n")
+		case DiffInsert:
+			output.WriteString(inserted.Render("+ "+line.Text) + "\n")
+		default:
+			if isNearChange(lines, index, context) {
+				output.WriteString("  " + line.Text + "\n")
+			} else if isNearChange(lines, index, context+1) {
+				output.WriteString("  ...\n")
+			}
+		}
+	}
+
+	return output.String()
+}
+
+func isNearChange(lines []DiffLine, index int, distance int) bool {
+	for i := max(0, index-distance); i <= min(len(lines)-1, index+distance); i++ {
+		if lines[i].Operation != DiffEqual {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Merge both versions, changed blocks are surrounded by git-like conflict markers to be resolved manually
+ */
+func mergeWithConflictMarkers(lines []DiffLine) string {
+	var output strings.Builder
+	var current, proposed []string
+
+	flush := func() {
+		if len(current) == 0 && len(proposed) == 0 {
+			return
+		}
+
+		output.WriteString("<<<<<<< current\n")
+
+		for _, text := range current {
+			output.WriteString(text + "\n")
+		}
+
+		output.WriteString("=======\n")
+
+		for _, text := range proposed {
+			output.WriteString(text + "\n")
+		}
+
+		output.WriteString(">>>>>>> phptooling\n")
+		current, proposed = nil, nil
+	}
+
+	for _, line := range lines {
+		switch line.Operation {
+		case DiffDelete:
+			current = append(current, line.Text)
+		case DiffInsert:
+			proposed = append(proposed, line.Text)
+		default:
+			flush()
+			output.WriteString(line.Text + "\n")
+		}
+	}
+
+	flush()
+
+	return output.String()
+}
@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"log/slog"
+	"strings"
+)
+
+/**
+ * Return the indentation of PHP files required by the coding standard
+ */
+func (generator *Generator) getPhpIndentation() (string, int) {
+	switch generator.Config.PhpCS.Standard {
+	case "WordPress":
+		return "tab", 4
+	case "Drupal":
+		return "space", 2
+	}
+
+	return "space", 4
+}
+
+/**
+ * Return the indentation of YAML files following the framework conventions
+ */
+func (generator *Generator) getYamlIndentSize() int {
+	if generator.Config.Framework == config.Symfony {
+		return 4
+	}
+
+	return 2
+}
+
+/**
+ * Generate the .editorconfig, an existing one is kept and only completed with the sections it doesn't define
+ */
+func (generator *Generator) GenerateEditorConfig() error {
+	content, renderErr := generator.RenderTemplate("config-files/editorconfig/.editorconfig.tmpl")
+
+	if renderErr != nil {
+		return renderErr
+	}
+
+	existing, err := generator.readText(".editorconfig")
+
+	if err == nil {
+		missingSections := getMissingEditorConfigSections(existing, content)
+
+		if missingSections == "" {
+			slog.Info(".editorconfig already defines every section, skipping")
+			return nil
+		}
+
+		content = strings.TrimRight(existing, "\n") + "\n\n# Added by phptooling\n" + missingSections
+	}
+
+	return generator.WriteProjectFile(".editorconfig", content)
+}
+
+/**
+ * Return the sections of proposed (e.g. "[*.php]" and its properties) whose header doesn't exist in existing
+ */
+func getMissingEditorConfigSections(existing string, proposed string) string {
+	existingHeaders := make(map[string]bool)
+
+	for _, line := range strings.Split(existing, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "[") {
+			existingHeaders[line] = true
+		}
+	}
+
+	var missing strings.Builder
+	keep := false
+
+	for _, line := range strings.Split(proposed, "\n") {
+		if strings.HasPrefix(line, "[") {
+			keep = !existingHeaders[strings.TrimSpace(line)]
+		}
+
+		if keep {
+			missing.WriteString(line + "\n")
+		}
+	}
+
+	if missing.Len() == 0 {
+		return ""
+	}
+
+	return strings.TrimRight(missing.String(), "\n") + "\n"
+}
@@ -0,0 +1,503 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/filesystem"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Generator writes the configuration files, recipes and hooks of the project following its Config
+type Generator struct {
+	Config *config.Config
+	Runner runner.CommandRunner
+	// Files of the project, accessed on the host by default as the environments of the runners mount it
+	Files filesystem.FileSystem
+	// Commands run to write files are cancelled along with this context
+	ctx context.Context
+	// Embedded templates, rooted at the directory containing config-files/
+	templates                fs.FS
+	remoteTemplatesDirectory string
+	backupDirectory          string
+	backupManifest           BackupManifest
+	projectLock              *lock.Lock
+	// Tools directory of the previous runs, as read from the lock
+	lockedToolsDirectory string
+	// Looked up on first use, see GlobalBinDirectory
+	globalBinDirectory string
+	// Looked up on first use, see RuntimePhpVersion
+	runtimePhpVersion string
+	// Looked up on first use, see PhpExtensions
+	phpExtensions []string
+	// Recipes appended to the justfile by the run, see Summary
+	addedRecipes []string
+}
+
+func New(ctx context.Context, cfg *config.Config, commandRunner runner.CommandRunner, templates fs.FS) *Generator {
+	generator := &Generator{Config: cfg, Files: filesystem.Host{Root: runner.LocalWorkingDirectory()}, ctx: ctx, templates: templates}
+	generator.SetRunner(commandRunner)
+
+	return generator
+}
+
+/**
+ * Run the next commands with the runner, its working directory is looked up once
+ */
+func (generator *Generator) SetRunner(commandRunner runner.CommandRunner) {
+	generator.Runner = runner.WithCachedWorkingDirectory(commandRunner)
+}
+
+/**
+ * Stop what the runner keeps open between commands, e.g. the shells of a SessionRunner
+ */
+func (generator *Generator) Close() error {
+	if closer, ok := generator.Runner.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+/**
+ * Run the command in the project, through docker when it is used
+ */
+func (generator *Generator) Run(command []string) error {
+	return generator.Runner.Run(generator.ctx, command)
+}
+
+/**
+ * Run the command in the project and return what it wrote instead of showing it, see runner.Capture
+ */
+func (generator *Generator) Capture(command []string) (runner.Captured, error) {
+	return runner.Capture(generator.ctx, generator.Runner, command)
+}
+
+/**
+ * Run the shell script on the host, e.g. the hooks declared by the project
+ */
+func (generator *Generator) RunScript(script string, env []string) error {
+	return runner.RunShell(generator.ctx, script, env)
+}
+
+func (generator *Generator) WorkingDirectory() (string, error) {
+	return generator.Runner.WorkingDirectory(generator.ctx)
+}
+
+func (generator *Generator) ToolsDirectory() (string, error) {
+	workingDir, err := generator.WorkingDirectory()
+
+	return path.Join(workingDir, generator.Config.ToolsDirectory), err
+}
+
+/**
+ * Return the tools directory relative to the project, as referenced from configuration files
+ */
+func (generator *Generator) RelativeToolsDirectory() string {
+	return path.Clean(generator.Config.ToolsDirectory)
+}
+
+/**
+ * Return the directory of the result caches of the tools relative to the project, as referenced from their
+ * configuration
+ */
+func (generator *Generator) RelativeCacheDirectory() string {
+	return path.Clean(generator.Config.CacheDirectory)
+}
+
+/**
+ * Return the files and directories of the project read by the tools where they run, relative to the project: the
+ * tools and cache directories, the pins of PHIVE, and the configuration files and baselines of the tools
+ */
+func (generator *Generator) ToolPaths() []string {
+	paths := []string{generator.RelativeToolsDirectory(), generator.RelativeCacheDirectory(), path.Dir(phiveConfigFile)}
+
+	for _, tool := range tools.Available {
+		definition, _ := tools.Get(tool)
+
+		for _, file := range definition.ConfigFiles(string(generator.Config.Framework)) {
+			paths = append(paths, file.Destination)
+		}
+
+		if definition.Baseline != nil {
+			paths = append(paths, definition.Baseline.File)
+		}
+	}
+
+	return paths
+}
+
+/**
+ * Create the directory in the project and return its full path
+ */
+func (generator *Generator) CreateDirectory(relativePath string) (string, error) {
+	workingDir, err := generator.WorkingDirectory()
+
+	if err != nil {
+		return "", err
+	}
+
+	trackErr := generator.trackDirectory(relativePath)
+
+	if trackErr != nil {
+		return "", trackErr
+	}
+
+	mkdirErr := generator.Files.MkdirAll(relativePath, 0755)
+
+	return path.Join(workingDir, relativePath), failure.Classify(failure.FileSystem, "create "+relativePath, mkdirErr)
+}
+
+/**
+ * Create the directory of the tool in the tools directory and return its full path
+ */
+func (generator *Generator) CreateToolDirectory(name string) (string, error) {
+	return generator.CreateDirectory(path.Join(generator.Config.ToolsDirectory, name))
+}
+
+func (generator *Generator) GenerateOutputs() error {
+	for _, output := range generator.Config.Outputs {
+		var err error
+
+		switch output {
+		case config.GitHubCompositeAction:
+			err = generator.GenerateGitHubCompositeAction()
+		case config.GitHubDiffWorkflow:
+			err = generator.GenerateGitHubDiffWorkflow()
+
+			if err == nil {
+				err = generator.AddQaDiffRecipe()
+			}
+		case config.GitHooks:
+			err = generator.GenerateHooks()
+		case config.EditorConfig:
+			err = generator.GenerateEditorConfig()
+		case config.PhpStorm:
+			err = generator.GeneratePhpStormConfig()
+		case config.VsCode:
+			err = generator.GenerateVsCodeConfig()
+		case config.Neovim:
+			err = generator.GenerateNeovimConfig()
+		case config.LanguageServer:
+			err = generator.GenerateLanguageServerConfig()
+		case config.Coverage:
+			err = generator.AddCoverageRecipe()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Markers of the block of the .gitignore
+const (
+	gitIgnoreStartMarker = "###> php-tooling ###"
+	gitIgnoreEndMarker   = "###< php-tooling ###"
+)
+
+/**
+ * Write the entries ignored by git between markers in the .gitignore, replacing the block of the previous runs. The
+ * entries the .gitignore already has outside of it are left out, the duplicates of previous versions are removed.
+ */
+func (generator *Generator) UpdateGitIgnore() error {
+	entries, entriesErr := generator.getGitIgnoreEntries()
+
+	if entriesErr != nil {
+		return entriesErr
+	}
+
+	existing, _ := generator.readText(".gitignore")
+	start, end, found := findBlock(existing, gitIgnoreStartMarker, gitIgnoreEndMarker)
+	outside := existing
+
+	if found {
+		outside = existing[:start] + existing[end:]
+
+		// Each run used to append the block again
+		for {
+			duplicateStart, duplicateEnd, duplicate := findBlock(outside[start:], gitIgnoreStartMarker, gitIgnoreEndMarker)
+
+			if !duplicate {
+				break
+			}
+
+			// Along with the empty line separating it
+			if strings.HasSuffix(outside[:start+duplicateStart], "\n\n") {
+				duplicateStart--
+			}
+
+			outside = outside[:start+duplicateStart] + outside[start+duplicateEnd:]
+		}
+	}
+
+	var ignored []string
+
+	for _, line := range strings.Split(outside, "\n") {
+		ignored = append(ignored, strings.Trim(strings.TrimSpace(line), "/"))
+	}
+
+	entries = slices.DeleteFunc(entries, func(entry string) bool {
+		return slices.Contains(ignored, strings.Trim(entry, "/"))
+	})
+
+	if !found && len(entries) == 0 {
+		slog.Debug("The .gitignore already ignores the files of the tools")
+		return nil
+	}
+
+	block := gitIgnoreStartMarker + "\n" + strings.Join(entries, "\n") + "\n" + gitIgnoreEndMarker + "\n"
+
+	if len(entries) == 0 {
+		block = ""
+	}
+
+	blockErr := generator.recordBlock(".gitignore", "php-tooling", "")
+
+	if blockErr != nil {
+		return blockErr
+	}
+
+	if !found {
+		separator := "\n"
+
+		if existing == "" {
+			separator = ""
+		} else if !strings.HasSuffix(existing, "\n") {
+			separator = "\n\n"
+		}
+
+		return generator.appendToFile(".gitignore", separator+block)
+	}
+
+	updated := outside[:start] + block + outside[start:]
+
+	if updated == existing {
+		return nil
+	}
+
+	previewed, write := generator.previewChange(".gitignore", updated, false)
+
+	if !write {
+		return nil
+	}
+
+	return generator.WriteFile(".gitignore", previewed)
+}
+
+/**
+ * Return the entries of the .gitignore following the setup of the project: its vendor directory, what the tools
+ * install in the tools directory (vendor directories, composer.lock files unless they are committed and phars, for
+ * the tools of previous runs too), the caches and the files written by the runs of phptooling and the tools, e.g. the
+ * reports. The PhpStorm and VS Code settings generated for the team are kept out of the ignored .idea and .vscode directories.
+ */
+func (generator *Generator) getGitIgnoreEntries() ([]string, error) {
+	entries := []string{".DS_Store", ".idea/", ".vscode/"}
+
+	if generator.Config.HasOutput(config.PhpStorm) {
+		entries = []string{".DS_Store", "/.idea/*", "!/" + phpStormPhpFile, "!/" + path.Dir(phpStormProfileFile) + "/", "!/" + phpStormRunDirectory + "/", ".vscode/"}
+	}
+
+	if generator.Config.HasOutput(config.VsCode) {
+		entries = slices.DeleteFunc(entries, func(entry string) bool { return entry == ".vscode/" })
+		entries = append(entries, "/.vscode/*", "!/"+vsCodeSettingsFile, "!/"+vsCodeTasksFile, "!/"+vsCodeExtensionsFile)
+	} else if generator.Config.HasOutput(config.LanguageServer) && !generator.usesPhpactor() {
+		// intelephense is configured in the settings of VS Code
+		entries = slices.DeleteFunc(entries, func(entry string) bool { return entry == ".vscode/" })
+		entries = append(entries, "/.vscode/*", "!/"+vsCodeSettingsFile)
+	}
+
+	if _, found, _ := project.ReadComposerJson(runner.LocalWorkingDirectory()); found || generator.Config.UsesRequireDev() {
+		entries = append(entries, "/vendor/")
+	}
+
+	projectLock, lockErr := generator.Lock()
+
+	if lockErr != nil {
+		return nil, lockErr
+	}
+
+	installed, installedErr := generator.getProjectTools()
+
+	if installedErr != nil {
+		return nil, installedErr
+	}
+
+	toolsDir := "/" + generator.RelativeToolsDirectory() + "/"
+
+	for _, tool := range installed {
+		definition, _ := tools.Get(tool)
+		// The tools of previous runs are ignored as the lock records their installation
+		locked, wasInstalled := projectLock.Tools[tool]
+		selected := slices.Contains(generator.Config.Tools, tool)
+
+		switch {
+		case selected && (generator.Config.InstallsGlobally(tool) || generator.Config.UsesRequireDev()):
+		case !selected && wasInstalled && (locked.Global || locked.RequireDev):
+			// Nothing is installed in the tools directory
+		case selected && generator.Config.UsesPhive(tool), !selected && locked.Phive != nil:
+			// phive.phar is committed, install-php runs it after a clone
+			entries = append(entries, toolsDir+definition.PhiveBinary())
+		case selected && generator.Config.UsesPhar(tool), !selected && locked.Phar != nil:
+			entries = append(entries, toolsDir+definition.PharBinary())
+		case generator.Config.Composer.IgnoreLocks:
+			entries = append(entries, toolsDir+string(tool)+"/vendor/", toolsDir+string(tool)+"/composer.lock")
+		default:
+			entries = append(entries, toolsDir+string(tool)+"/vendor/")
+		}
+	}
+
+	// Caches the tools write next to their configuration unless it gives them the cache directory
+	if slices.Contains(installed, tools.PhpCsFixer) {
+		entries = append(entries, ".php-cs-fixer.cache")
+	}
+
+	if slices.Contains(installed, tools.PhpCS) {
+		entries = append(entries, ".phpcs.cache")
+	}
+
+	entries = append(entries, "/"+generator.RelativeCacheDirectory()+"/")
+
+	for _, format := range []config.ReportFormat{config.SarifReport, config.JUnitReport, config.HTMLReport} {
+		entries = append(entries, "/"+config.ReportFiles[format])
+	}
+
+	if generator.Config.HasOutput(config.Coverage) {
+		entries = append(entries, "/"+CoverageDirectory+"/")
+	}
+
+	if generator.Config.HasOutput(config.GitHubCompositeAction) || generator.Config.HasOutput(config.GitHubDiffWorkflow) {
+		entries = append(entries, "/"+ciReportsDirectory+"/")
+	}
+
+	entries = append(entries, "/.phptooling/backups/", "/.phptooling/timings.json", "/"+filesystem.RunLockFile)
+
+	var unique []string
+
+	for _, entry := range entries {
+		if !slices.Contains(unique, entry) {
+			unique = append(unique, entry)
+		}
+	}
+
+	return unique, nil
+}
+
+/**
+ * Append content to the file (relative to the project), which is created if needed
+ */
+func (generator *Generator) appendToFile(relativePath string, content string) error {
+	backupErr := generator.BackupFile(relativePath)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	content = generator.matchLineEndings(relativePath, content)
+	appendErr := generator.Files.AppendFile(relativePath, []byte(content), 0644)
+
+	if appendErr != nil {
+		return failure.Classify(failure.FileSystem, "append to "+relativePath, appendErr)
+	}
+
+	logging.Event("file", "path", path.Clean(relativePath), "action", "appended")
+
+	return generator.recordAppended(relativePath, content)
+}
+
+/**
+ * Write content to the file (relative to the project), creating parent directories if needed, the file always ends
+ * with a new line
+ */
+func (generator *Generator) WriteFile(relativePath string, data string) error {
+	trackErr := generator.trackDirectory(path.Dir(relativePath))
+
+	if trackErr != nil {
+		return trackErr
+	}
+
+	backupErr := generator.BackupFile(relativePath)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	if !strings.HasSuffix(data, "\n") {
+		data += "\n"
+	}
+
+	data = generator.matchLineEndings(relativePath, data)
+
+	// 644 permissions avoid issues with other tools or IDE
+	writeErr := generator.Files.WriteFile(relativePath, []byte(data), 0644)
+
+	if writeErr != nil {
+		return failure.Classify(failure.FileSystem, "write "+relativePath, writeErr)
+	}
+
+	logging.Event("file", "path", path.Clean(relativePath), "action", "written")
+
+	return nil
+}
+
+/**
+ * Read the text file of the project (relative path) with \n line endings, the files checked out with CRLF line endings
+ * on Windows are merged like the others
+ */
+func (generator *Generator) readText(relativePath string) (string, error) {
+	data, err := generator.Files.ReadFile(relativePath)
+
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), err
+}
+
+/**
+ * Return the content with CRLF line endings when the existing file uses them, so that changes don't rewrite every line
+ */
+func (generator *Generator) matchLineEndings(relativePath string, content string) string {
+	existing, err := generator.Files.ReadFile(relativePath)
+
+	if err != nil || !bytes.Contains(existing, []byte("\r\n")) {
+		return content
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+}
+
+/**
+ * Same as WriteFile, the generated file is recorded in the lock, unless the change is skipped when previewed
+ */
+func (generator *Generator) WriteProjectFile(relativePath string, data string) error {
+	previewed, write := generator.previewChange(relativePath, data, false)
+
+	if !write {
+		return nil
+	}
+
+	return generator.writeGeneratedFile(relativePath, "", previewed)
+}
+
+/**
+ * Write the file and record it in the lock along with the template it was rendered from
+ */
+func (generator *Generator) writeGeneratedFile(relativePath string, template string, data string) error {
+	writeErr := generator.WriteFile(relativePath, data)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return generator.recordFile(relativePath, template)
+}
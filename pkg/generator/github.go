@@ -0,0 +1,299 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/tools"
+	"slices"
+	"strings"
+)
+
+// Directory of the checkstyle reports written in CI, relative to the project
+const ciReportsDirectory = "build/reports"
+
+/**
+ * Return the PHP version used in CI, the one of the project when detected
+ */
+func (generator *Generator) getCIPhpVersion() string {
+	if generator.Config.PhpVersion != "" {
+		return generator.Config.PhpVersion
+	}
+
+	return "8.3"
+}
+
+/**
+ * Return the steps restoring the result caches of the tools from the previous runs, indented for the file. A new
+ * cache is saved after each run as the key changes, restore-keys pick the latest one so that only the files changed
+ * since are analysed again.
+ */
+func (generator *Generator) getCICacheSteps(cacheDir string, indent string) string {
+	steps := `
+- name: Restore the result caches of the tools
+  uses: actions/cache@v4
+  with:
+    path: ` + cacheDir + `
+    key: php-quality-${{ runner.os }}-${{ github.sha }}
+    restore-keys: php-quality-${{ runner.os }}-
+
+- name: Create the cache directory
+  shell: bash
+  run: mkdir -p ` + cacheDir + `
+`
+
+	return indentLines(steps, indent)
+}
+
+/**
+ * Return the steps running the tool in CI, indented for the file. Tools printing a checkstyle report write it to the
+ * reports directory, from which cs2pr annotates the code with the issues even when the tool failed, tools with the
+ * github format annotate it themselves. The job goes on when an advisory tool fails.
+ */
+func (generator *Generator) getCIRunSteps(tool tools.Tool, command string, format string, condition string, shell string, indent string) string {
+	var options string
+
+	if shell != "" {
+		options += "\n  shell: " + shell
+	}
+
+	runOptions := options
+
+	if generator.Config.IsAdvisory(tool) {
+		runOptions += "\n  continue-on-error: true"
+	}
+
+	if format != tools.CheckstyleFormat {
+		return indentLines(`
+- name: Run `+tools.Name(tool)+getCICondition(condition)+runOptions+`
+  run: `+command+`
+`, indent)
+	}
+
+	reportFile := ciReportsDirectory + "/" + string(tool) + ".checkstyle.xml"
+
+	return indentLines(`
+- name: Run `+tools.Name(tool)+getCICondition(condition)+runOptions+`
+  run: mkdir -p `+ciReportsDirectory+` && `+command+` > `+reportFile+`
+
+- name: Annotate the issues of `+tools.Name(tool)+getCICondition("!cancelled() && hashFiles('"+reportFile+"') != ''")+options+`
+  run: cs2pr --graceful-warnings `+reportFile+`
+`, indent)
+}
+
+/**
+ * Return the if line of a step, indented as its other keys
+ */
+func getCICondition(condition string) string {
+	if condition == "" {
+		return ""
+	}
+
+	return "\n  if: ${{ " + condition + " }}"
+}
+
+/**
+ * Return the step keeping the checkstyle reports of the run as an artifact, when some tools write one
+ */
+func getCIArtifactStep(formats []string, indent string) string {
+	if !slices.Contains(formats, tools.CheckstyleFormat) {
+		return ""
+	}
+
+	return indentLines(`
+- name: Keep the checkstyle reports
+  if: ${{ !cancelled() }}
+  uses: actions/upload-artifact@v4
+  with:
+    name: php-quality-reports
+    path: `+ciReportsDirectory+`/
+    if-no-files-found: ignore
+`, indent)
+}
+
+/**
+ * Return the tools installed by setup-php, cs2pr converts the checkstyle reports to annotations
+ */
+func getCISetupTools(formats []string) string {
+	if slices.Contains(formats, tools.CheckstyleFormat) {
+		return "composer, cs2pr"
+	}
+
+	return "composer"
+}
+
+/**
+ * Return what the CI step installing the tool installs, PHIVE installs all its tools in one step
+ */
+func (generator *Generator) getInstallStepName(tool tools.Tool) string {
+	if generator.Config.UsesPhive(tool) {
+		return "the tools of PHIVE"
+	}
+
+	return tools.Name(tool)
+}
+
+func indentLines(text string, indent string) string {
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (generator *Generator) GenerateGitHubCompositeAction() error {
+	toolsDir := generator.RelativeToolsDirectory()
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return err
+	}
+
+	var steps strings.Builder
+
+	var commands []string
+
+	for _, tool := range projectTools {
+		command, commandErr := generator.getToolInstallCommand(tool, "composer install --no-interaction --no-progress", "php", "${{ inputs.tools-directory }}")
+
+		if commandErr != nil {
+			return commandErr
+		}
+
+		// Tools required by the project are installed with its dependencies, the ones of PHIVE share a step
+		if command != "" && !slices.Contains(commands, command) {
+			commands = append(commands, command)
+			steps.WriteString(`
+    - name: Install ` + generator.getInstallStepName(tool) + `
+      shell: bash
+      run: ` + command + `
+`)
+		}
+	}
+
+	steps.WriteString(generator.getCICacheSteps("${{ inputs.cache-directory }}", "    "))
+
+	var formats []string
+
+	for _, tool := range projectTools {
+		arguments, format, err := tools.CIArguments(tool, generator.Config.Paths)
+
+		if err != nil {
+			return err
+		}
+
+		formats = append(formats, format)
+		steps.WriteString(generator.getCIRunSteps(tool, "php "+generator.ciBinary(tool, "${{ inputs.tools-directory }}")+" "+arguments, format, "", "bash", "    "))
+	}
+
+	steps.WriteString(getCIArtifactStep(formats, "    "))
+	steps.WriteString(generator.getCICoverageSteps())
+
+	var coverage string
+
+	if generator.Config.HasOutput(config.Coverage) {
+		coverage = "\n        coverage: pcov"
+	}
+
+	return generator.WriteProjectFile(".github/actions/php-quality/action.yml", `# Generated by phptooling, reusable with "uses: ./.github/actions/php-quality"
+name: PHP quality
+description: Install and run the PHP quality tools
+
+inputs:
+  php-version:
+    description: PHP version used to run the tools
+    default: '`+generator.getCIPhpVersion()+`'
+  tools-directory:
+    description: Directory where the tools are installed
+    default: '`+toolsDir+`'
+  cache-directory:
+    description: Directory of the result caches of the tools, as set in their configuration
+    default: '`+generator.RelativeCacheDirectory()+`'
+
+runs:
+  using: composite
+  steps:
+    - name: Setup PHP
+      uses: shivammathur/setup-php@v2
+      with:
+        php-version: ${{ inputs.php-version }}
+        tools: `+getCISetupTools(formats)+coverage+`
+
+    - name: Install project dependencies
+      shell: bash
+      run: composer install --no-interaction --no-progress
+`+steps.String())
+}
+
+func (generator *Generator) GenerateGitHubDiffWorkflow() error {
+	toolsDir := generator.RelativeToolsDirectory()
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return err
+	}
+
+	var steps strings.Builder
+
+	var commands []string
+
+	for _, tool := range tools.DiffTools(projectTools) {
+		command, commandErr := generator.getToolInstallCommand(tool, "composer install --no-interaction --no-progress", "php", toolsDir)
+
+		if commandErr != nil {
+			return commandErr
+		}
+
+		if command != "" && !slices.Contains(commands, command) {
+			commands = append(commands, command)
+			steps.WriteString(`
+      - name: Install ` + generator.getInstallStepName(tool) + `
+        run: ` + command + `
+`)
+		}
+	}
+
+	steps.WriteString(generator.getCICacheSteps(generator.RelativeCacheDirectory(), "      "))
+	steps.WriteString(`
+      - name: Compute changed PHP files
+        id: changed
+        run: echo "files=$(git diff --name-only --diff-filter=ACMR "origin/${{ github.base_ref }}...HEAD" -- '*.php' | tr '\n' ' ')" >> "$GITHUB_OUTPUT"
+`)
+
+	var formats []string
+
+	for _, tool := range tools.DiffTools(projectTools) {
+		arguments, format := tools.CIDiffArguments(tool)
+		formats = append(formats, format)
+		steps.WriteString(generator.getCIRunSteps(tool, "php "+generator.ciBinary(tool, toolsDir)+" "+arguments+" ${{ steps.changed.outputs.files }}", format, "steps.changed.outputs.files != ''", "", "      "))
+	}
+
+	steps.WriteString(getCIArtifactStep(formats, "      "))
+
+	return generator.WriteProjectFile(".github/workflows/php-quality-diff.yml", `# Generated by phptooling, only checks the PHP files changed by the pull request
+name: PHP quality (changed files)
+
+on:
+  pull_request:
+
+jobs:
+  php-quality-diff:
+    runs-on: ubuntu-latest
+    steps:
+      - name: Checkout
+        uses: actions/checkout@v4
+        with:
+          fetch-depth: 0
+
+      - name: Setup PHP
+        uses: shivammathur/setup-php@v2
+        with:
+          php-version: '`+generator.getCIPhpVersion()+`'
+          tools: `+getCISetupTools(formats)+`
+
+      - name: Install project dependencies
+        run: composer install --no-interaction --no-progress
+`+steps.String())
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"path"
+	"strings"
+)
+
+// Variable of the justfile holding the bin directory of the global composer, read by just where composer runs
+const globalBinVariable = "composer_global_bin"
+
+// Command printing the bin directory of the global composer
+var globalBinCommand = []string{"composer", "global", "config", "bin-dir", "--absolute", "--quiet"}
+
+/**
+ * Return the bin directory of the global composer where commands are run, looked up once
+ */
+func (generator *Generator) GlobalBinDirectory() (string, error) {
+	if generator.globalBinDirectory != "" {
+		return generator.globalBinDirectory, nil
+	}
+
+	binDirectory, err := runner.Output(generator.ctx, generator.Runner, globalBinCommand, "find the bin directory of the global composer")
+
+	if err != nil {
+		return "", err
+	}
+
+	generator.globalBinDirectory = binDirectory
+
+	return binDirectory, nil
+}
+
+/**
+ * Install the packages of the tool with composer global require, one tool at a time since they share the global
+ * composer.json
+ */
+func (generator *Generator) InstallGlobally(definition tools.Definition) error {
+	return generator.Run(append(append([]string{"composer", "global", "require"}, generator.Config.Requirements(definition)...), "--with-all-dependencies"))
+}
+
+/**
+ * Record the tool as installed globally, with the versions of the global composer.lock when it is on the host
+ */
+func (generator *Generator) RecordGlobal(tool tools.Tool, packages []string) error {
+	binDirectory, binErr := generator.GlobalBinDirectory()
+
+	if binErr != nil {
+		return binErr
+	}
+
+	// The bin directory is vendor/bin of the global composer unless configured otherwise
+	return generator.recordInstall(tool, lock.Tool{
+		Global:     true,
+		Packages:   lock.ReadComposerVersions(path.Dir(path.Dir(binDirectory)), packages),
+		Constraint: generator.Config.Versions[tool],
+	})
+}
+
+/**
+ * Return the justfile variable holding the global bin directory, empty when no tool is installed globally
+ */
+func (generator *Generator) getGlobalBinVariable() string {
+	if len(generator.Config.GlobalTools) == 0 {
+		return ""
+	}
+
+	command := strings.TrimSpace(generator.Runner.NonInteractivePrefix() + " " + runner.QuoteCommand(globalBinCommand))
+
+	return `
+# Bin directory of the global composer, holding the tools shared with your other projects
+` + globalBinVariable + ` := ` + "`" + command + "`" + `
+`
+}
@@ -0,0 +1,459 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Directory holding the versioned hooks, relative to the project
+const VersionedHooksDirectory = ".githooks"
+
+// Configuration file of the commit-msg hook, relative to the project
+const conventionalCommitsConfigFile = ".conventional-commits"
+
+/**
+ * Write the git hooks selected in the Config with the chosen hook manager
+ */
+func (generator *Generator) GenerateHooks() error {
+	hooks := generator.Config.Hooks
+
+	if hooks.CommitMsg {
+		configErr := generator.generateConventionalCommitsConfiguration()
+
+		if configErr != nil {
+			return configErr
+		}
+	}
+
+	if hooks.Manager == config.Lefthook {
+		return generator.generateLefthookConfiguration()
+	}
+
+	if hooks.Manager == config.Husky {
+		return generator.updateHuskyHooks()
+	}
+
+	if len(hooks.PreCommit) > 0 {
+		script, err := generator.getPreCommitScript()
+
+		if err == nil {
+			err = generator.writeHook("pre-commit", script)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(hooks.PrePush) > 0 {
+		script, err := generator.getPrePushScript()
+
+		if err == nil {
+			err = generator.writeHook("pre-push", script)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if hooks.CommitMsg {
+		hookErr := generator.writeHook("commit-msg", getCommitMsgScript())
+
+		if hookErr != nil {
+			return hookErr
+		}
+	}
+
+	if hooks.Manager == config.VersionedHooks {
+		bootstrapErr := generator.generateHooksBootstrapScript()
+
+		if bootstrapErr != nil {
+			return bootstrapErr
+		}
+
+		return configureHooksPath()
+	}
+
+	return nil
+}
+
+/**
+ * Return the directory where git looks for hooks relative to the project, taking core.hooksPath into account
+ */
+func getGitHooksDirectory() (string, error) {
+	output, gitErr := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+
+	if gitErr != nil {
+		return "", failure.Wrap(failure.Environment, "find the git hooks directory", gitErr)
+	}
+
+	hooksDir := strings.TrimSpace(string(output))
+
+	if !filepath.IsAbs(hooksDir) {
+		return hooksDir, nil
+	}
+
+	// e.g. the hooks of the main repository for a worktree
+	relativeDir, relErr := filepath.Rel(runner.LocalWorkingDirectory(), hooksDir)
+
+	return filepath.ToSlash(relativeDir), failure.Wrap(failure.Environment, "find the git hooks directory", relErr)
+}
+
+/**
+ * Return the directory where hooks are written, relative to the project
+ */
+func (generator *Generator) getHooksDirectory() (string, error) {
+	if generator.Config.Hooks.Manager == config.VersionedHooks {
+		return VersionedHooksDirectory, nil
+	}
+
+	return getGitHooksDirectory()
+}
+
+/**
+ * Generate the script each team member runs once to enable the versioned hooks
+ */
+func (generator *Generator) generateHooksBootstrapScript() error {
+	hooksDir, err := generator.getHooksDirectory()
+
+	if err != nil {
+		return err
+	}
+
+	bootstrapPath := path.Join(hooksDir, "bootstrap.sh")
+
+	writeErr := generator.WriteProjectFile(bootstrapPath, `#!/bin/sh
+# Generated by phptooling: enable the hooks versioned in this directory
+git config core.hooksPath `+VersionedHooksDirectory+`
+echo "Git hooks from `+VersionedHooksDirectory+`/ are now enabled"`)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return failure.Classify(failure.FileSystem, "make "+bootstrapPath+" executable", generator.Files.Chmod(bootstrapPath, 0755))
+}
+
+func configureHooksPath() error {
+	err := exec.Command("git", "config", "core.hooksPath", VersionedHooksDirectory).Run()
+
+	if err != nil {
+		return failure.Wrap(failure.Environment, "set core.hooksPath", err)
+	}
+
+	slog.Info("Git hooks are now read from " + VersionedHooksDirectory + "/, commit it and ask your team to run " + VersionedHooksDirectory + "/bootstrap.sh")
+
+	return nil
+}
+
+/**
+ * Return the prefix used by git hooks to run commands, empty when running them on the host
+ */
+func (generator *Generator) getHookRunPrefix() string {
+	return generator.Runner.NonInteractivePrefix()
+}
+
+/**
+ * Same as ToolBinary for the hooks, which are shared with the team: the tools directory is relative to the project on
+ * the host (hooks are run from its root) and the global bin directory is read when the hook runs. The binary is
+ * quoted for the shell.
+ */
+func (generator *Generator) getHookBinary(tool tools.Tool, binary string) (string, error) {
+	prefix := generator.getHookRunPrefix()
+
+	if generator.Config.InstallsGlobally(tool) {
+		command := strings.TrimSpace(prefix + " " + runner.QuoteCommand(globalBinCommand))
+
+		return `"$(` + command + `)"/` + path.Base(binary), nil
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return projectBinDirectory + "/" + path.Base(binary), nil
+	}
+
+	// The path of the container is the same for everyone using it
+	if prefix != "" {
+		toolsDir, err := generator.ToolsDirectory()
+
+		return runner.QuoteArgument(toolsDir + "/" + binary), err
+	}
+
+	return runner.QuoteArgument(generator.RelativeToolsDirectory() + "/" + binary), nil
+}
+
+/**
+ * Return the command checking the given files before a commit, or fixing them in auto-fix mode
+ */
+func (generator *Generator) getPreCommitCommand(tool tools.Tool, files string) (string, error) {
+	if tool == tools.PhpLint {
+		return strings.TrimSpace(generator.getHookRunPrefix() + ` sh -c 'for file in "$@"; do php -l "$file" > /dev/null; done' php-lint ` + files), nil
+	}
+
+	if definition, _ := tools.Get(tool); generator.Config.Hooks.AutoFix && definition.Fix != nil {
+		binary := definition.Fix.Binary
+
+		if binary == "" {
+			binary = generator.Config.Binary(tool)
+		}
+
+		binaryPath, err := generator.getHookBinary(tool, binary)
+
+		if err != nil {
+			return "", err
+		}
+
+		command := strings.TrimSpace(generator.getHookRunPrefix() + ` php ` + binaryPath + ` ` + definition.Fix.Arguments + ` ` + files)
+
+		if definition.Fix.FixedExitCode != 0 {
+			command += ` || [ $? -eq ` + strconv.Itoa(definition.Fix.FixedExitCode) + ` ]`
+		}
+
+		return command, nil
+	}
+
+	binaryPath, err := generator.getHookBinary(tool, generator.Config.Binary(tool))
+
+	return strings.TrimSpace(generator.getHookRunPrefix() + ` php ` + binaryPath + ` ` + tools.DiffArguments(tool) + ` ` + files), err
+}
+
+/**
+ * Return the command checking the whole project before a push
+ */
+func (generator *Generator) getPrePushCommand(tool tools.Tool) (string, error) {
+	if tool == tools.PhpUnit {
+		return strings.TrimSpace(generator.getHookRunPrefix() + ` php vendor/bin/phpunit`), nil
+	}
+
+	binaryPath, err := generator.getHookBinary(tool, generator.Config.Binary(tool))
+
+	if err != nil {
+		return "", err
+	}
+
+	arguments, err := tools.CheckArguments(tool, generator.Config.Paths)
+
+	return strings.TrimSpace(generator.getHookRunPrefix() + ` php ` + binaryPath + ` ` + arguments), err
+}
+
+func (generator *Generator) getPreCommitScript() (string, error) {
+	script := `#!/bin/sh
+# Generated by phptooling: run fast checks on staged PHP files
+set -e
+
+# One staged file per argument, names with spaces or glob characters are kept as they are
+set -f
+IFS='
+'
+set -- $(git -c core.quotePath=false diff --cached --name-only --diff-filter=ACMR -- '*.php')
+unset IFS
+set +f
+
+if [ $# -eq 0 ]; then
+    exit 0
+fi
+`
+
+	for _, tool := range generator.Config.Hooks.PreCommit {
+		command, err := generator.getPreCommitCommand(tool, `"$@"`)
+
+		if err != nil {
+			return "", err
+		}
+
+		script += `
+echo "Running ` + tools.Name(tool) + `"
+` + command + `
+`
+	}
+
+	if generator.Config.Hooks.AutoFix && generator.Config.Hooks.HasPreCommitFixer() {
+		// Unstaged changes of the fixed files are staged too
+		script += `
+git add -- "$@"
+`
+	}
+
+	return script, nil
+}
+
+func (generator *Generator) getPrePushScript() (string, error) {
+	script := `#!/bin/sh
+# Generated by phptooling: run the heavy checks on the whole project before pushing
+fail() {
+    echo "$1 failed, fix the reported issues or use \"git push --no-verify\" to skip these checks"
+    exit 1
+}
+`
+
+	for _, tool := range generator.Config.Hooks.PrePush {
+		command, err := generator.getPrePushCommand(tool)
+
+		if err != nil {
+			return "", err
+		}
+
+		script += `
+echo "Running ` + tools.Name(tool) + `"
+` + command + ` || fail "` + tools.Name(tool) + `"
+`
+	}
+
+	return script, nil
+}
+
+/**
+ * Generate a lefthook.yml running the same checks as the native hooks, pre-commit ones in parallel. It is committed,
+ * the commands refer to the tools like the versioned hooks. They are written as YAML strings, quoted paths may hold
+ * characters such as " #" which would otherwise start a comment.
+ */
+func (generator *Generator) generateLefthookConfiguration() error {
+	hooks := generator.Config.Hooks
+	lefthookConfig := `# Generated by phptooling, install the hooks with "lefthook install"
+`
+
+	if len(hooks.PreCommit) > 0 {
+		// Fixers would otherwise write the same files concurrently
+		lefthookConfig += `pre-commit:
+  parallel: ` + strconv.FormatBool(!hooks.AutoFix) + `
+  commands:
+`
+
+		for _, tool := range generator.Config.Hooks.PreCommit {
+			command, err := generator.getPreCommitCommand(tool, "{staged_files}")
+
+			if err != nil {
+				return err
+			}
+
+			lefthookConfig += `    ` + string(tool) + `:
+      glob: "*.php"
+      run: ` + strconv.Quote(command) + `
+`
+
+			if hooks.AutoFix && tool != tools.PhpLint {
+				lefthookConfig += `      stage_fixed: true
+`
+			}
+		}
+	}
+
+	if len(hooks.PrePush) > 0 {
+		lefthookConfig += `# Use "git push --no-verify" to skip these checks
+pre-push:
+  commands:
+`
+
+		for _, tool := range generator.Config.Hooks.PrePush {
+			command, err := generator.getPrePushCommand(tool)
+
+			if err != nil {
+				return err
+			}
+
+			lefthookConfig += `    ` + string(tool) + `:
+      run: ` + strconv.Quote(command) + `
+`
+		}
+	}
+
+	if hooks.CommitMsg {
+		lefthookConfig += `commit-msg:
+  scripts:
+    "conventional-commits.sh":
+      runner: sh
+`
+
+		scriptErr := generator.WriteProjectFile(".lefthook/commit-msg/conventional-commits.sh", getCommitMsgScript())
+
+		if scriptErr != nil {
+			return scriptErr
+		}
+	}
+
+	return generator.WriteProjectFile("lefthook.yml", lefthookConfig)
+}
+
+/**
+ * Generate the configuration read by the commit-msg hook, kept if it already exists
+ */
+func (generator *Generator) generateConventionalCommitsConfiguration() error {
+	_, err := generator.Files.Stat(conventionalCommitsConfigFile)
+
+	if err == nil {
+		return nil
+	}
+
+	return generator.WriteProjectFile(conventionalCommitsConfigFile, `# Configuration of the commit-msg hook generated by phptooling
+# Allowed commit types, separated by |
+TYPES="feat|fix|docs|style|refactor|perf|test|build|ci|chore|revert"
+# Maximum length of the first line of the commit message
+MAX_LENGTH=72
+# Set to 1 to require a scope, e.g. "feat(api): ..."
+REQUIRE_SCOPE=0`)
+}
+
+/**
+ * Return the script validating the commit message file given as first argument
+ */
+func getCommitMsgScript() string {
+	return `#!/bin/sh
+# Generated by phptooling: validate commit messages against conventional commits (see https://www.conventionalcommits.org/)
+TYPES="feat|fix|docs|style|refactor|perf|test|build|ci|chore|revert"
+MAX_LENGTH=72
+REQUIRE_SCOPE=0
+
+if [ -f ` + conventionalCommitsConfigFile + ` ]; then
+    . ./` + conventionalCommitsConfigFile + `
+fi
+
+subject=$(head -n 1 "$1")
+
+case "$subject" in
+    Merge\ *|fixup!\ *|squash!\ *)
+        exit 0
+        ;;
+esac
+
+scope='(\([a-z0-9._/-]+\))?'
+
+if [ "$REQUIRE_SCOPE" = "1" ]; then
+    scope='\([a-z0-9._/-]+\)'
+fi
+
+if ! echo "$subject" | grep -Eq "^($TYPES)$scope!?: .+"; then
+    echo "Invalid commit message: \"$subject\""
+    echo "Expected \"<type>(<scope>): <description>\" with type one of: $TYPES"
+    exit 1
+fi
+
+if [ ${#subject} -gt "$MAX_LENGTH" ]; then
+    echo "The first line of the commit message is longer than $MAX_LENGTH characters"
+    exit 1
+fi`
+}
+
+func (generator *Generator) writeHook(name string, script string) error {
+	hooksDir, err := generator.getHooksDirectory()
+
+	if err != nil {
+		return err
+	}
+
+	hookPath := path.Join(hooksDir, name)
+	writeErr := generator.WriteProjectFile(hookPath, script)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return failure.Classify(failure.FileSystem, "make "+hookPath+" executable", generator.Files.Chmod(hookPath, 0755))
+}
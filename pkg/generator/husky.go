@@ -0,0 +1,172 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"encoding/json"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// Markers surrounding the block appended to husky hooks, used to avoid appending it twice
+const (
+	huskyBlockStart = "# >>> phptooling >>>"
+	huskyBlockEnd   = "# <<< phptooling <<<"
+)
+
+func (generator *Generator) updateHuskyHooks() error {
+	hooks := generator.Config.Hooks
+	nodePackage, packageErr := project.ReadNodePackage(runner.LocalWorkingDirectory())
+
+	if packageErr != nil {
+		return packageErr
+	}
+
+	if len(hooks.PreCommit) > 0 {
+		var err error
+
+		if nodePackage.UsesLintStaged() {
+			err = generator.updateLintStagedConfiguration(nodePackage)
+		} else {
+			var script string
+			script, err = generator.getPreCommitScript()
+
+			if err == nil {
+				err = generator.appendToHuskyHook("pre-commit", script)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(hooks.PrePush) > 0 {
+		script, err := generator.getPrePushScript()
+
+		if err == nil {
+			err = generator.appendToHuskyHook("pre-push", script)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if hooks.CommitMsg {
+		return generator.appendToHuskyHook("commit-msg", getCommitMsgScript())
+	}
+
+	return nil
+}
+
+/**
+ * Append the script to the husky hook, in a subshell so that its exit calls don't stop the existing commands
+ */
+func (generator *Generator) appendToHuskyHook(name string, script string) error {
+	hookPath := path.Join(".husky", name)
+	content, _ := generator.Files.ReadFile(hookPath)
+
+	blockErr := generator.recordBlock(hookPath, "phptooling", "")
+
+	if blockErr != nil {
+		return blockErr
+	}
+
+	if strings.Contains(string(content), huskyBlockStart) {
+		slog.Info("The hook already runs the PHP checks, skipping", "file", hookPath)
+		return nil
+	}
+
+	// The shebang is only needed for standalone hooks
+	script = strings.TrimPrefix(script, "#!/bin/sh\n")
+
+	backupErr := generator.BackupFile(hookPath)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	block := generator.matchLineEndings(hookPath, "\n"+huskyBlockStart+"\n(\n"+script+"\n) || exit 1\n"+huskyBlockEnd+"\n")
+	appendErr := generator.Files.AppendFile(hookPath, []byte(block), 0755)
+
+	if appendErr != nil {
+		return failure.Classify(failure.FileSystem, "append to "+hookPath, appendErr)
+	}
+
+	logging.Event("file", "path", hookPath, "action", "appended")
+
+	return generator.recordAppended(hookPath, block)
+}
+
+/**
+ * Whether lint-staged is configured in a file format which can't be safely edited
+ */
+func (generator *Generator) hasUnsupportedLintStagedConfiguration() bool {
+	for _, file := range []string{".lintstagedrc", ".lintstagedrc.yaml", ".lintstagedrc.yml", ".lintstagedrc.mjs", ".lintstagedrc.cjs", "lint-staged.config.js", "lint-staged.config.mjs", "lint-staged.config.cjs"} {
+		_, err := generator.Files.Stat(file)
+
+		if err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Register the pre-commit checks for PHP files in lint-staged, which appends the staged files to each command
+ * and re-stages the files modified by fixers
+ */
+func (generator *Generator) updateLintStagedConfiguration(nodePackage project.NodePackage) error {
+	var commands []string
+
+	for _, tool := range generator.Config.Hooks.PreCommit {
+		command, err := generator.getPreCommitCommand(tool, "")
+
+		if err != nil {
+			return err
+		}
+
+		// lint-staged doesn't run commands through a shell
+		if strings.Contains(command, "||") {
+			command = `sh -c '` + strings.Replace(command, " || ", ` "$@" || `, 1) + `' ` + string(tool)
+		}
+
+		commands = append(commands, command)
+	}
+
+	if len(nodePackage.LintStaged) > 0 || generator.hasUnsupportedLintStagedConfiguration() {
+		snippet, _ := json.MarshalIndent(map[string][]string{"*.php": commands}, "", "  ")
+		slog.Warn("lint-staged configuration can't be updated automatically, add the following entry to it:\n" + string(snippet))
+		return nil
+	}
+
+	configuration := make(map[string]interface{})
+	configPath := ".lintstagedrc.json"
+	file, fileErr := generator.Files.ReadFile(configPath)
+
+	if fileErr == nil {
+		parseErr := json.Unmarshal(file, &configuration)
+
+		if parseErr != nil {
+			return failure.Wrap(failure.Configuration, "parse .lintstagedrc.json", parseErr)
+		}
+	} else if hook, _ := generator.Files.ReadFile(path.Join(".husky", "pre-commit")); !strings.Contains(string(hook), "lint-staged") {
+		// A new lint-staged configuration isn't run by husky yet
+		hookErr := generator.appendToHuskyHook("pre-commit", "npx lint-staged")
+
+		if hookErr != nil {
+			return hookErr
+		}
+	}
+
+	configuration["*.php"] = commands
+	data, _ := json.MarshalIndent(configuration, "", "  ")
+
+	// The final new line is added when writing
+	return generator.WriteFile(configPath, string(data))
+}
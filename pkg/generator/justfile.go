@@ -0,0 +1,462 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Settings of a justfile choosing the shell of the recipes on Windows
+var windowsShellPattern = regexp.MustCompile(`(?m)^set\s+windows-(shell|powershell)\b`)
+
+type JustFileCallback func(composerAlias string, phpAlias string, toolsDir string) (string, error)
+
+/**
+ * Append the recipes returned by the callback to the justfile between markers named after the block, which is
+ * recorded in the lock unless it is skipped when previewed. The block of a previous run is replaced in place. Blocks
+ * defining a recipe which already exists are left out, just refuses duplicates.
+ */
+func (generator *Generator) AddToJustFile(name string, callback JustFileCallback) error {
+	toolsDir, err := generator.ToolsDirectory()
+
+	if err != nil {
+		return err
+	}
+
+	content, err := callback(runner.ComposerAlias(generator.Runner), runner.PhpAlias(generator.Runner), toolsDir)
+
+	if err != nil {
+		return err
+	}
+
+	content = strings.Trim(content, "\n")
+
+	startMarker, endMarker := getBlockMarkers(name)
+
+	// The block may be in the other file when the Config changed since
+	for _, file := range []string{"justfile", config.ImportedJustFile} {
+		existing, readErr := generator.readText(file)
+
+		if start, end, found := findBlock(existing, startMarker, endMarker); readErr == nil && found {
+			return generator.replaceJustFileBlock(file, name, existing, start, end, content)
+		}
+	}
+
+	justFile := generator.getJustFile()
+
+	if duplicates := generator.getDuplicateRecipes(content); len(duplicates) > 0 {
+		// Blocks of the previous versions have no markers
+		if generator.hasBlock(justFile, name) {
+			slog.Info("Keeping the recipes added by a previous run", "block", name)
+			return nil
+		}
+
+		slog.Warn("Leaving out recipes already defined by the justfile, rename the existing ones to add them", "block", name, "recipes", strings.Join(duplicates, ", "))
+		return nil
+	}
+
+	block, write := generator.previewChange(justFile, "\n"+wrapBlock(name, content), true)
+
+	if !write {
+		return nil
+	}
+
+	importErr := generator.importJustFile(justFile)
+
+	if importErr != nil {
+		return importErr
+	}
+
+	blockErr := generator.recordBlock(justFile, name, getBlockContent(block, name))
+
+	if blockErr != nil {
+		return blockErr
+	}
+
+	appendErr := generator.appendToFile(justFile, block)
+
+	if appendErr != nil {
+		return appendErr
+	}
+
+	generator.trackRecipes(block)
+
+	return nil
+}
+
+/**
+ * Replace the content of the block written by a previous run, between start and end in the existing file. A block
+ * edited since goes through the conflict resolution of the Config, like the templates.
+ */
+func (generator *Generator) replaceJustFileBlock(file string, name string, existing string, start int, end int, content string) error {
+	current := getBlockContent(existing[start:end], name)
+
+	if current == content {
+		return generator.recordBlock(file, name, content)
+	}
+
+	block, _ := generator.getBlock(file, name)
+
+	if block.Hash != lock.Hash(current) {
+		lines := diffLines(current+"\n", content+"\n")
+
+		if generator.Config.ResolveConflict == nil {
+			return failure.New(failure.FileConflict, "update the "+name+" recipes of "+file, "the recipes were edited since they were added")
+		}
+
+		switch generator.Config.ResolveConflict(file+" ("+name+" recipes)", formatDiff(lines)) {
+		case config.Skip:
+			slog.Info("Keeping the edited recipes", "file", file, "block", name)
+			return nil
+		case config.Merge:
+			content = strings.TrimSuffix(mergeWithConflictMarkers(lines), "\n")
+			slog.Warn("Resolve the conflict markers written in the recipes", "file", file, "block", name)
+		}
+	}
+
+	updated, write := generator.previewChange(file, existing[:start]+wrapBlock(name, content)+existing[end:], false)
+
+	if !write {
+		return nil
+	}
+
+	writeErr := generator.WriteFile(file, updated)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	slog.Info("Updated the recipes added by a previous run", "file", file, "block", name)
+	generator.trackRecipes(content)
+
+	return generator.recordBlock(file, name, content)
+}
+
+/**
+ * Return the markers surrounding the block of the justfile
+ */
+func getBlockMarkers(name string) (string, string) {
+	return "###> phptooling:" + name + " ###", "###< phptooling:" + name + " ###"
+}
+
+func wrapBlock(name string, content string) string {
+	startMarker, endMarker := getBlockMarkers(name)
+
+	return startMarker + "\n" + content + "\n" + endMarker + "\n"
+}
+
+/**
+ * Return where the first block between the markers starts and ends in the text, its end line included
+ */
+func findBlock(text string, startMarker string, endMarker string) (int, int, bool) {
+	start := strings.Index(text, startMarker+"\n")
+
+	if start == -1 {
+		return 0, 0, false
+	}
+
+	end := strings.Index(text[start:], endMarker)
+
+	if end == -1 {
+		return 0, 0, false
+	}
+
+	end += start + len(endMarker)
+
+	if end < len(text) && text[end] == '\n' {
+		end++
+	}
+
+	return start, end, true
+}
+
+/**
+ * Return the content of the block between its markers, as recorded in the lock
+ */
+func getBlockContent(block string, name string) string {
+	startMarker, endMarker := getBlockMarkers(name)
+	content := strings.TrimSpace(block)
+	content = strings.TrimPrefix(content, startMarker)
+	content = strings.TrimSuffix(content, endMarker)
+
+	return strings.Trim(content, "\n")
+}
+
+/**
+ * Return the file the recipes are appended to, the justfile unless the Config tells otherwise
+ */
+func (generator *Generator) getJustFile() string {
+	if generator.Config.JustFile == "" {
+		return "justfile"
+	}
+
+	return generator.Config.JustFile
+}
+
+/**
+ * Return the recipes of the content which the justfile or the imported file already define
+ */
+func (generator *Generator) getDuplicateRecipes(content string) []string {
+	var existing []string
+
+	for _, file := range []string{"justfile", config.ImportedJustFile} {
+		if data, readErr := generator.readText(file); readErr == nil {
+			existing = append(existing, RecipeNames(data)...)
+		}
+	}
+
+	var duplicates []string
+
+	for _, recipe := range RecipeNames(content) {
+		if slices.Contains(existing, recipe) {
+			duplicates = append(duplicates, recipe)
+		}
+	}
+
+	return duplicates
+}
+
+/**
+ * Import the file of the recipes at the end of the justfile when it isn't the justfile itself, once
+ */
+func (generator *Generator) importJustFile(justFile string) error {
+	if justFile == "justfile" {
+		return nil
+	}
+
+	statement := "import '" + justFile + "'"
+	existing, _ := generator.readText("justfile")
+
+	if slices.Contains(strings.Split(existing, "\n"), statement) {
+		return nil
+	}
+
+	blockErr := generator.recordBlock("justfile", "import", "")
+
+	if blockErr != nil {
+		return blockErr
+	}
+
+	return generator.appendToFile("justfile", "\n# Recipes of phptooling\n"+statement+"\n")
+}
+
+func (generator *Generator) AddQaDiffRecipe() error {
+	return generator.AddToJustFile("qa-diff", func(composerAlias string, phpAlias string, toolsDir string) (string, error) {
+		recipe := `
+# Launch quality tools on PHP files changed against a branch
+qa-diff branch='origin/main':
+    #!/usr/bin/env bash
+    set -euo pipefail
+    files=$(git diff --name-only --diff-filter=ACMR {{branch}}...HEAD -- '*.php')
+    if [ -z "$files" ]; then echo "No changed PHP files"; exit 0; fi
+`
+
+		projectTools, err := generator.getProjectTools()
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, tool := range tools.DiffTools(projectTools) {
+			command := phpAlias + ` ` + generator.JustFileBinary(tool, generator.Config.Binary(tool), toolsDir) + ` ` + tools.DiffArguments(tool) + ` $files`
+
+			// The issues of advisory tools are shown without failing the recipe
+			if generator.Config.IsAdvisory(tool) {
+				command += ` || echo "` + tools.Name(tool) + ` is advisory, its issues don't fail qa-diff"`
+			}
+
+			recipe += `    ` + command + `
+`
+		}
+
+		return recipe, nil
+	})
+}
+
+func (generator *Generator) InitializeJustFile() error {
+	return generator.AddToJustFile("install-php", func(composerAlias string, phpAlias string, toolsDir string) (string, error) {
+		cacheDir := generator.RelativeCacheDirectory()
+		recipe := generator.getWindowsShellSetting() + generator.getGlobalBinVariable() + generator.getComposerAuthVariable() + `
+# Install php dependencies
+install-php:
+    ` + composerAlias + ` install
+    ` + getPhpScript(phpAlias, `is_dir($argv[1]) || mkdir($argv[1], 0777, true);`, cacheDir) + `
+`
+
+		installed, err := generator.getProjectTools()
+
+		if err != nil {
+			return "", err
+		}
+
+		var commands []string
+
+		for _, tool := range installed {
+			command, commandErr := generator.getToolInstallCommand(tool, composerAlias+" install", phpAlias, toolsDir)
+
+			if commandErr != nil {
+				return "", commandErr
+			}
+
+			// The tools installed with PHIVE share their command
+			if command != "" && !slices.Contains(commands, command) {
+				commands = append(commands, command)
+				recipe += `    ` + command + `
+`
+			}
+		}
+
+		return recipe, nil
+	})
+}
+
+/**
+ * Return the tools of the project once the run completes, in registry order: the selected ones and the ones installed
+ * by previous runs in the same tools directory unless it is wiped. The recipes, CI files and .gitignore entries
+ * covering every tool keep the tools of previous runs this way, a run only adds the tools it selects.
+ */
+func (generator *Generator) getProjectTools() ([]tools.Tool, error) {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []tools.Tool
+	keepsLocked := !generator.Config.WipeToolsDirectory && generator.lockedToolsDirectory == generator.RelativeToolsDirectory()
+
+	for _, tool := range tools.Available {
+		_, locked := projectLock.Tools[tool]
+
+		if slices.Contains(generator.Config.Tools, tool) || (locked && keepsLocked) {
+			installed = append(installed, tool)
+		}
+	}
+
+	return installed, nil
+}
+
+// projectRecipe is a recipe of the blocks written by phptooling in the justfile, described by the comment above it
+type projectRecipe struct {
+	name        string
+	description string
+}
+
+/**
+ * Return the recipes of the blocks recorded in the lock, the ones of previous runs included. The lock sorts its blocks
+ * by name, the recipes follow the order of the blocks in the justfile and then in the imported file.
+ */
+func (generator *Generator) getProjectRecipes() ([]projectRecipe, error) {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Content of a block along with its position in the file
+	type fileBlock struct {
+		start   int
+		content string
+	}
+
+	var blocks []fileBlock
+
+	for _, file := range []string{"justfile", config.ImportedJustFile} {
+		data, readErr := generator.readText(file)
+
+		if readErr != nil {
+			continue
+		}
+
+		var fileBlocks []fileBlock
+
+		for _, block := range projectLock.Blocks {
+			if block.File != file {
+				continue
+			}
+
+			startMarker, endMarker := getBlockMarkers(block.Name)
+
+			if start, end, found := findBlock(data, startMarker, endMarker); found {
+				fileBlocks = append(fileBlocks, fileBlock{start: start, content: data[start:end]})
+			}
+		}
+
+		slices.SortFunc(fileBlocks, func(a fileBlock, b fileBlock) int { return a.start - b.start })
+		blocks = append(blocks, fileBlocks...)
+	}
+
+	var recipes []projectRecipe
+
+	for _, block := range blocks {
+		comment := ""
+
+		for _, line := range strings.Split(block.content, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				comment = strings.TrimPrefix(line, "# ")
+				continue
+			}
+
+			if names := RecipeNames(line); len(names) > 0 {
+				recipes = append(recipes, projectRecipe{name: names[0], description: comment})
+			}
+
+			comment = ""
+		}
+	}
+
+	return recipes, nil
+}
+
+/**
+ * Return the setting running the recipes with PowerShell on Windows, which has no sh, unless the justfile already
+ * chooses the shell of Windows. The recipes only use commands that PowerShell runs too.
+ */
+func (generator *Generator) getWindowsShellSetting() string {
+	startMarker, endMarker := getBlockMarkers("install-php")
+
+	for _, file := range []string{"justfile", config.ImportedJustFile} {
+		existing, _ := generator.readText(file)
+
+		// The setting written by a previous run is replaced along with its block
+		if start, end, found := findBlock(existing, startMarker, endMarker); found {
+			existing = existing[:start] + existing[end:]
+		}
+
+		if windowsShellPattern.MatchString(existing) {
+			return ""
+		}
+	}
+
+	return `
+# Recipes are run by PowerShell on Windows
+set windows-shell := ["powershell.exe", "-NoLogo", "-NoProfile", "-Command"]
+`
+}
+
+/**
+ * Return the justfile variable giving the credentials of composer to exec containers, empty when they don't need it
+ */
+func (generator *Generator) getComposerAuthVariable() string {
+	cfg := generator.Config
+
+	if cfg.Composer.AuthFile == "" || cfg.Environment != config.DockerCompose || cfg.DockerCommand != "exec" {
+		return ""
+	}
+
+	authFile := strconv.Quote(cfg.Composer.AuthFile)
+
+	// just evaluates the variable before any recipe, the file is only read when it exists so that the recipes work
+	// without it (e.g. for the teammates who don't use the private repositories)
+	return `
+# Credentials of the composer repositories, read by composer in the container. A COMPOSER_AUTH already set is kept.
+export COMPOSER_AUTH := if env_var_or_default("COMPOSER_AUTH", "") != "" { env_var("COMPOSER_AUTH") } else if path_exists(` + authFile + `) == "true" { ` + "`cat " + runner.QuotePath(cfg.Composer.AuthFile) + "`" + ` } else { "" }
+`
+}
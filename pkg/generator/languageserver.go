@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"slices"
+	"strings"
+)
+
+// Configuration file of phpactor, relative to the project
+const phpactorFile = ".phpactor.json"
+
+// Files intelephense excludes by default, the setting replaces them
+var intelephenseExcludes = []string{
+	"**/.git/**", "**/.svn/**", "**/.hg/**", "**/CVS/**", "**/.DS_Store/**", "**/node_modules/**",
+	"**/bower_components/**", "**/vendor/**/{Tests,tests}/**", "**/.history/**", "**/vendor/**/vendor/**",
+}
+
+// Stubs intelephense loads by default, the setting replaces them
+var intelephenseStubs = []string{
+	"apache", "bcmath", "bz2", "calendar", "com_dotnet", "Core", "ctype", "curl", "date", "dba", "dom", "enchant",
+	"exif", "FFI", "fileinfo", "filter", "fpm", "ftp", "gd", "gettext", "gmp", "hash", "iconv", "imap", "intl", "json",
+	"ldap", "libxml", "mbstring", "meta", "mysqli", "oci8", "odbc", "openssl", "pcntl", "pcre", "PDO", "pdo_ibm",
+	"pdo_mysql", "pdo_pgsql", "pdo_sqlite", "pgsql", "Phar", "posix", "pspell", "readline", "Reflection", "session",
+	"shmop", "SimpleXML", "snmp", "soap", "sockets", "sodium", "SPL", "sqlite3", "standard", "superglobals", "sysvmsg",
+	"sysvsem", "sysvshm", "tidy", "tokenizer", "xml", "xmlreader", "xmlrpc", "xmlwriter", "xsl", "Zend OPcache", "zip",
+	"zlib",
+}
+
+// Stubs of intelephense for extensions it doesn't load by default, added when the PHP of the environment loads them
+var intelephenseExtensionStubs = []string{"amqp", "apcu", "igbinary", "imagick", "memcached", "mongodb", "redis", "swoole", "xdebug", "yaml"}
+
+// Files phpactor excludes from its index by default, the setting replaces them
+var phpactorExcludes = []string{"/vendor/**/Tests/**/*", "/vendor/**/tests/**/*", "/var/cache/**/*", "/vendor/composer/**/*"}
+
+/**
+ * Configure the language server of the project like the analysers: the PHP version of the project, the stubs of the
+ * framework and of the extensions of the environment, and the tools directory and caches left out of the index so
+ * that the dependencies of the tools aren't suggested. Phpactor is configured in .phpactor.json when the project uses
+ * it, intelephense in .vscode/settings.json otherwise.
+ */
+func (generator *Generator) GenerateLanguageServerConfig() error {
+	excluded := []string{generator.RelativeToolsDirectory(), generator.RelativeCacheDirectory(), ".phptooling"}
+	phpVersion := generator.Config.PhpVersion
+
+	if generator.usesPhpactor() {
+		return generator.mergeJsonFile(phpactorFile, func(document map[string]interface{}) {
+			patterns := slices.Clone(phpactorExcludes)
+
+			for _, directory := range excluded {
+				patterns = append(patterns, "/"+directory+"/**/*")
+			}
+
+			document["indexer.exclude_patterns"] = patterns
+
+			if phpVersion != "" {
+				document["php.version"] = phpVersion
+			}
+
+			if stubs := generator.getPhpactorStubs(); len(stubs) > 0 {
+				document["indexer.stub_paths"] = stubs
+			}
+		})
+	}
+
+	stubs := generator.getIntelephenseStubs()
+
+	return generator.mergeJsonFile(vsCodeSettingsFile, func(document map[string]interface{}) {
+		patterns := slices.Clone(intelephenseExcludes)
+
+		for _, directory := range excluded {
+			patterns = append(patterns, "**/"+directory+"/**")
+		}
+
+		document["intelephense.files.exclude"] = patterns
+
+		// intelephense expects a full version, e.g. 8.1.0
+		if phpVersion != "" {
+			document["intelephense.environment.phpVersion"] = phpVersion + strings.Repeat(".0", 2-strings.Count(phpVersion, "."))
+		}
+
+		if len(stubs) > len(intelephenseStubs) {
+			document["intelephense.stubs"] = stubs
+		}
+	})
+}
+
+/**
+ * Whether the project uses phpactor as language server rather than intelephense: it requires it or configures it
+ */
+func (generator *Generator) usesPhpactor() bool {
+	composerJson, _, _ := project.ReadComposerJson(runner.LocalWorkingDirectory())
+	_, phpactorJsonErr := generator.Files.Stat(phpactorFile)
+	_, phpactorYamlErr := generator.Files.Stat(".phpactor.yml")
+
+	return composerJson.Requires("phpactor/phpactor") || phpactorJsonErr == nil || phpactorYamlErr == nil
+}
+
+/**
+ * Return the stubs of intelephense: the default ones, the ones of WordPress and the ones of the extensions loaded by
+ * the PHP of the environment. Extensions which can't be listed only leave their stubs out.
+ */
+func (generator *Generator) getIntelephenseStubs() []string {
+	stubs := slices.Clone(intelephenseStubs)
+
+	if generator.Config.Framework == config.WordPress {
+		stubs = append(stubs, "wordpress")
+	}
+
+	loaded, _ := generator.PhpExtensions()
+
+	for _, extension := range intelephenseExtensionStubs {
+		if slices.Contains(loaded, extension) {
+			stubs = append(stubs, extension)
+		}
+	}
+
+	return stubs
+}
+
+/**
+ * Return the stubs phpactor indexes besides the ones it bundles, the WordPress ones when the project requires them
+ */
+func (generator *Generator) getPhpactorStubs() []string {
+	composerJson, _, _ := project.ReadComposerJson(runner.LocalWorkingDirectory())
+
+	if generator.Config.Framework == config.WordPress && composerJson.Requires("php-stubs/wordpress-stubs") {
+		return []string{"%project_root%/vendor/php-stubs/wordpress-stubs"}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/**
+ * Return the lock of the project, read on first use and completed as things get installed. The tools of the tools
+ * directory are dropped when it is wiped or when the run uses another one, they aren't installed anymore.
+ */
+func (generator *Generator) Lock() (*lock.Lock, error) {
+	if generator.projectLock != nil {
+		return generator.projectLock, nil
+	}
+
+	projectLock, _, err := lock.Read(runner.LocalWorkingDirectory())
+
+	if err != nil {
+		return nil, err
+	}
+
+	// saveLock records the directory of the run, the one of the previous runs is kept for getProjectTools
+	generator.lockedToolsDirectory = projectLock.ToolsDirectory
+
+	if generator.Config.WipeToolsDirectory || projectLock.ToolsDirectory != generator.RelativeToolsDirectory() {
+		for tool, installed := range projectLock.Tools {
+			if !installed.Global {
+				delete(projectLock.Tools, tool)
+			}
+		}
+	}
+
+	generator.projectLock = projectLock
+
+	return projectLock, nil
+}
+
+/**
+ * Record the tool with the versions of its packages installed in its directory
+ */
+func (generator *Generator) RecordTool(tool tools.Tool, packages []string) error {
+	directory := filepath.Join(runner.LocalWorkingDirectory(), generator.Config.ToolsDirectory, string(tool))
+
+	return generator.recordInstall(tool, lock.Tool{Packages: lock.ReadComposerVersions(directory, packages), Constraint: generator.Config.Versions[tool]})
+}
+
+/**
+ * Record the release of the phar installed for the tool
+ */
+func (generator *Generator) RecordPhar(tool tools.Tool, url string, sha256 string) error {
+	return generator.recordInstall(tool, lock.Tool{Phar: &lock.Phar{Url: url, Sha256: sha256}, Constraint: generator.Config.Versions[tool]})
+}
+
+/**
+ * Record how the tool was installed, which is also an event of the run
+ */
+func (generator *Generator) recordInstall(tool tools.Tool, installed lock.Tool) error {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return err
+	}
+
+	projectLock.Tools[tool] = installed
+	logging.Event("tool", "tool", tool, "install", installed)
+
+	return generator.saveLock()
+}
+
+/**
+ * Record the hash of a file generated in the project (relative path), as written on the disk, ignored for paths
+ * outside the project
+ */
+func (generator *Generator) recordFile(relativePath string, template string) error {
+	relativePath = path.Clean(relativePath)
+
+	if path.IsAbs(relativePath) || strings.HasPrefix(relativePath, "..") {
+		return nil
+	}
+
+	content, readErr := generator.Files.ReadFile(relativePath)
+
+	if readErr != nil {
+		return failure.Wrap(failure.FileSystem, "read "+relativePath, readErr)
+	}
+
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return err
+	}
+
+	projectLock.AddFile(relativePath, template, string(content))
+
+	return generator.saveLock()
+}
+
+func (generator *Generator) recordBlock(file string, name string, content string) error {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return err
+	}
+
+	projectLock.AddBlock(file, name, content)
+
+	return generator.saveLock()
+}
+
+/**
+ * Tell whether a previous run appended the block to the file
+ */
+func (generator *Generator) hasBlock(file string, name string) bool {
+	_, found := generator.getBlock(file, name)
+
+	return found
+}
+
+/**
+ * Return the block appended to the file by a previous run, as recorded in the lock
+ */
+func (generator *Generator) getBlock(file string, name string) (lock.Block, bool) {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return lock.Block{}, false
+	}
+
+	index := slices.IndexFunc(projectLock.Blocks, func(block lock.Block) bool {
+		return block.File == file && block.Name == name
+	})
+
+	if index == -1 {
+		return lock.Block{}, false
+	}
+
+	return projectLock.Blocks[index], true
+}
+
+/**
+ * Write the lock after each change, so that it is restored along with the other files on rollback
+ */
+func (generator *Generator) saveLock() error {
+	backupErr := generator.BackupFile(lock.FileName)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	generator.projectLock.ToolsDirectory = path.Clean(generator.Config.ToolsDirectory)
+
+	// Written on the host like the backups, it is rewritten after each change
+	return generator.projectLock.Write(runner.LocalWorkingDirectory())
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Lua snippet configuring the Neovim plugins running the tools, relative to the project. It is loaded by the
+// configuration of the users, see GenerateNeovimConfig.
+const neovimFile = ".phptooling/nvim.lua"
+
+// Sources of none-ls (formerly null-ls) running the tools, by kind
+var noneLsSources = map[tools.Tool][]string{
+	tools.PhpStan:    {"diagnostics.phpstan"},
+	tools.PhpCsFixer: {"formatting.phpcsfixer"},
+	tools.PhpCS:      {"diagnostics.phpcs", "formatting.phpcbf"},
+	tools.PhpMD:      {"diagnostics.phpmd"},
+	tools.Psalm:      {"diagnostics.psalm"},
+}
+
+// Linters of nvim-lint running the tools, PHP CS Fixer only fixes files
+var nvimLintLinters = map[tools.Tool]string{
+	tools.PhpStan: "phpstan",
+	tools.PhpCS:   "phpcs",
+	tools.PhpMD:   "phpmd",
+	tools.Psalm:   "psalm",
+}
+
+/**
+ * Generate the lua snippet registering the tools of the project in none-ls and nvim-lint, whichever is installed, with
+ * their binaries and configuration files. It is only written, users load it from their configuration.
+ */
+func (generator *Generator) GenerateNeovimConfig() error {
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	var linters []string
+	var linterNames []string
+
+	for _, tool := range projectTools {
+		definition, _ := tools.Get(tool)
+		hostBinary, binaryErr := generator.getHostBinary(tool, generator.Config.Binary(tool))
+
+		if binaryErr != nil {
+			return binaryErr
+		}
+
+		binary := getLuaPath(hostBinary)
+		configFile := ""
+
+		if files := definition.ConfigFiles(string(generator.Config.Framework)); len(files) > 0 {
+			configFile = getLuaPath(files[0].Destination)
+		}
+
+		for _, source := range noneLsSources[tool] {
+			command := binary
+			var arguments []string
+
+			switch source {
+			case "diagnostics.phpstan":
+				arguments = []string{`"-c"`, configFile}
+			case "formatting.phpcsfixer", "diagnostics.psalm":
+				arguments = []string{`"--config=" .. ` + configFile}
+			case "diagnostics.phpcs", "formatting.phpcbf":
+				arguments = []string{`"--standard=" .. ` + configFile}
+			case "diagnostics.phpmd":
+				arguments = []string{configFile}
+			}
+
+			if source == "formatting.phpcbf" {
+				beautifier, beautifierErr := generator.getHostBinary(tool, definition.Fix.Binary)
+
+				if beautifierErr != nil {
+					return beautifierErr
+				}
+
+				command = getLuaPath(beautifier)
+			}
+
+			sources = append(sources, `    null_ls.builtins.`+source+`.with({ command = `+command+`, extra_args = { `+strings.Join(arguments, ", ")+` } }),`)
+		}
+
+		if linter, found := nvimLintLinters[tool]; found {
+			linters = append(linters, `  lint.linters.`+linter+`.cmd = `+binary)
+			linterNames = append(linterNames, strconv.Quote(linter))
+
+			// The rules of PHPMD are an argument, the other tools read their configuration in the project
+			if tool == tools.PhpMD {
+				linters = append(linters, `  lint.linters.phpmd.args = { "-", "json", `+configFile+` }`)
+			}
+		}
+	}
+
+	if len(sources) == 0 {
+		slog.Info("None of the tools has a Neovim plugin source, skipping " + neovimFile)
+		return nil
+	}
+
+	if generator.Config.Environment != config.Local {
+		slog.Warn("Neovim plugins run the tools with the PHP of the host, not the one of " + string(generator.Config.Environment))
+	}
+
+	content := `-- Diagnostics and formatting of the quality tools of the project, generated by phptooling.
+-- Load it from the .nvim.lua of the project (with the exrc option) or from the configuration of Neovim:
+--   dofile(vim.fn.getcwd() .. "/` + neovimFile + `")
+local root = vim.fn.getcwd()
+
+local has_null_ls, null_ls = pcall(require, "null-ls")
+
+if has_null_ls then
+  null_ls.register({
+` + strings.Join(sources, "\n") + `
+  })
+end
+`
+
+	if len(linters) > 0 {
+		content += `
+local has_lint, lint = pcall(require, "lint")
+
+if has_lint then
+` + strings.Join(linters, "\n") + `
+  lint.linters_by_ft.php = { ` + strings.Join(linterNames, ", ") + ` }
+end
+`
+	}
+
+	writeErr := generator.WriteProjectFile(neovimFile, content)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	slog.Info("Load "+neovimFile+" from the configuration of Neovim", "snippet", `dofile(vim.fn.getcwd() .. "/`+neovimFile+`")`)
+
+	return nil
+}
+
+/**
+ * Return the lua expression of the path, relative paths start from the directory Neovim is opened in
+ */
+func getLuaPath(file string) string {
+	if path.IsAbs(file) {
+		return strconv.Quote(file)
+	}
+
+	return `root .. ` + strconv.Quote("/"+file)
+}
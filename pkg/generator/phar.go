@@ -0,0 +1,243 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Versions a phar can be pinned to, the v prefix of tags is optional
+var exactVersion = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)$`)
+
+/**
+ * Return where the phar of the tool is downloaded from along with its expected SHA-256: the release recorded in the
+ * lock when it was installed with the same version constraint and isn't updated, otherwise the pinned or latest one
+ * without expected checksum
+ */
+func (generator *Generator) PharSource(definition tools.Definition) (string, string, error) {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	constraint := generator.Config.Versions[definition.Id]
+
+	locked := projectLock.Tools[definition.Id]
+
+	if locked.Phar != nil && locked.Constraint == constraint && generator.Config.ToolActions[definition.Id] != config.UpdateTool {
+		return locked.Phar.Url, locked.Phar.Sha256, nil
+	}
+
+	if constraint == "" {
+		return definition.Phar.Url, "", nil
+	}
+
+	version := exactVersion.FindStringSubmatch(constraint)
+
+	if version == nil || definition.Phar.VersionedUrl == "" {
+		return "", "", failure.New(failure.Configuration, "pin the phar of "+definition.Name, "only an exact version (e.g. 1.2.3) of a phar with a versioned URL can be installed, not "+constraint)
+	}
+
+	url, renderErr := tools.Render(definition.Phar.VersionedUrl, struct{ Version string }{version[1]})
+
+	return url, "", renderErr
+}
+
+/**
+ * Download the phar and check its checksum when one is expected, along with its signature when the tool has signing
+ * keys. The URL of the release it was downloaded from is returned with the content, nothing is written so that
+ * downloads can run concurrently.
+ */
+func (generator *Generator) DownloadPhar(url string, expectedSha256 string, signingKeys []string) ([]byte, string, error) {
+	operation := "download " + url
+	data, releaseUrl, err := generator.download(url)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	shaErr := verifySha256(operation, data, expectedSha256)
+
+	if shaErr != nil {
+		return nil, "", failure.New(failure.Verification, operation, "the SHA-256 of the phar differs from the one recorded in "+lock.FileName)
+	}
+
+	if len(signingKeys) == 0 {
+		return data, releaseUrl, nil
+	}
+
+	signature, _, signatureErr := generator.download(releaseUrl + ".asc")
+
+	if signatureErr != nil {
+		return nil, "", signatureErr
+	}
+
+	return data, releaseUrl, verifySignature(generator.ctx, "check the signature of "+releaseUrl, data, signature, signingKeys)
+}
+
+/**
+ * Download the content at the URL, the returned URL is the last one reached on the same host: latest release URLs
+ * redirect to the URL of the release, which redirects to a temporary URL of the storage
+ */
+func (generator *Generator) download(url string) ([]byte, string, error) {
+	operation := "download " + url
+	request, requestErr := http.NewRequestWithContext(generator.ctx, http.MethodGet, url, nil)
+
+	if requestErr != nil {
+		return nil, "", failure.Wrap(failure.Configuration, operation, requestErr)
+	}
+
+	releaseUrl := url
+	client := &http.Client{CheckRedirect: func(next *http.Request, via []*http.Request) error {
+		if next.URL.Host == request.URL.Host {
+			releaseUrl = next.URL.String()
+		}
+
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+
+		return nil
+	}}
+
+	response, err := client.Do(request)
+
+	if err != nil && generator.ctx.Err() != nil {
+		return nil, "", failure.Wrap(failure.Aborted, operation, generator.ctx.Err())
+	}
+
+	if err != nil {
+		return nil, "", failure.Wrap(failure.Environment, operation, err)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", failure.New(failure.Environment, operation, response.Status)
+	}
+
+	data, readErr := io.ReadAll(response.Body)
+
+	if readErr != nil {
+		return nil, "", failure.Wrap(failure.Environment, operation, readErr)
+	}
+
+	return data, releaseUrl, nil
+}
+
+/**
+ * Write the downloaded phar in the directory of the tool and record its release in the lock
+ */
+func (generator *Generator) WritePhar(definition tools.Definition, data []byte, url string) error {
+	writeErr := generator.writeExecutable(path.Join(generator.Config.ToolsDirectory, definition.PharBinary()), data)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return generator.RecordPhar(definition.Id, url, lock.Hash(string(data)))
+}
+
+/**
+ * Write the downloaded file (relative to the project) with the permission to run it, after backing it up
+ */
+func (generator *Generator) writeExecutable(relativePath string, data []byte) error {
+	trackErr := generator.trackDirectory(path.Dir(relativePath))
+
+	if trackErr != nil {
+		return trackErr
+	}
+
+	backupErr := generator.BackupFile(relativePath)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	writeErr := generator.Files.WriteFile(relativePath, data, 0755)
+
+	if writeErr != nil {
+		return failure.Classify(failure.FileSystem, "write "+relativePath, writeErr)
+	}
+
+	logging.Event("file", "path", path.Clean(relativePath), "action", "written")
+
+	return nil
+}
+
+/**
+ * Return the command installing the tool in its directory, e.g. from the justfile or CI: composer install with the
+ * given command, or the download of the recorded release of its phar with php. Empty for the tools required by the
+ * project, installed along with its dependencies. PHIVE installs all its tools at once, the same command is returned
+ * for each of them and is run once.
+ */
+func (generator *Generator) getToolInstallCommand(tool tools.Tool, composerInstall string, phpAlias string, toolsDir string) (string, error) {
+	definition, _ := tools.Get(tool)
+
+	if generator.Config.InstallsGlobally(tool) {
+		return strings.Replace(composerInstall, "composer install", "composer global require "+strings.Join(generator.Config.Requirements(definition), " "), 1), nil
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return "", nil
+	}
+
+	// Without their composer.lock, the tools are updated to their latest versions
+	composerCommand := "install"
+
+	if generator.Config.Composer.IgnoreLocks {
+		composerCommand = "update"
+	}
+
+	if generator.Config.UsesBinPlugin() {
+		// The plugin finds the namespace from the composer.json of the project, installed before
+		return strings.Replace(composerInstall, "composer install", "composer bin "+string(tool)+" "+composerCommand, 1), nil
+	}
+
+	if generator.Config.UsesPhive(tool) {
+		command, err := generator.getPhiveRestoreCommand()
+
+		if err != nil {
+			return "", err
+		}
+
+		return phpAlias + " " + runner.QuoteCommand(command[1:]), nil
+	}
+
+	if !generator.Config.UsesPhar(tool) {
+		return strings.Replace(composerInstall, "composer install", "composer "+composerCommand, 1) + " " + runner.QuoteArgument("--working-dir="+toolsDir+"/"+string(tool)), nil
+	}
+
+	file := generator.RelativeToolsDirectory() + "/" + definition.PharBinary()
+	url, sha256, _ := generator.PharSource(definition)
+	arguments := []string{url, file}
+	check := ""
+
+	// Downloaded by PHP rather than curl and sha256sum, so that the recipe runs in PowerShell and in containers too
+	if sha256 != "" {
+		arguments = append(arguments, "sha256", sha256)
+		check = ` && hash_file($argv[3], $argv[2]) === $argv[4]`
+	}
+
+	// The directory of the phar isn't versioned when the phar is ignored, e.g. on a fresh clone
+	return getPhpScript(phpAlias, `is_dir(dirname($argv[2])) || mkdir(dirname($argv[2]), 0777, true); copy($argv[1], $argv[2])`+check+` || exit(1);`, arguments...), nil
+}
+
+/**
+ * Return the command running the PHP code with the arguments, which it reads from $argv. The values are quoted for the
+ * shell instead of being written in the code, where quotes or $ would break it. The code must not hold quotes, sh and
+ * PowerShell both pass it as it is between single quotes.
+ */
+func getPhpScript(phpAlias string, code string, arguments ...string) string {
+	return phpAlias + ` -r '` + code + `' -- ` + runner.QuoteCommand(arguments)
+}
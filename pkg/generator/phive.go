@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/xml"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Latest release of PHIVE, downloaded in the tools directory when it isn't there yet
+const phiveUrl = "https://phar.io/releases/phive.phar"
+
+// Key signing the releases of PHIVE (see https://phar.io/#Install)
+var phiveSigningKeys = []string{"9D8A98B29B2D5D79"}
+
+// Configuration where PHIVE pins the installed tools, relative to the project
+const phiveConfigFile = ".phive/phars.xml"
+
+// phiveConfig is the part of phiveConfigFile telling the installed versions
+type phiveConfig struct {
+	Phars []struct {
+		Name      string `xml:"name,attr"`
+		Installed string `xml:"installed,attr"`
+	} `xml:"phar"`
+}
+
+/**
+ * Return the phar of PHIVE relative to the project
+ */
+func (generator *Generator) phiveBinary() string {
+	return path.Join(generator.RelativeToolsDirectory(), "phive.phar")
+}
+
+/**
+ * Download PHIVE in the tools directory unless it is already there, it is then run with the php of the environment
+ */
+func (generator *Generator) InstallPhive() error {
+	if _, err := generator.Files.Stat(generator.phiveBinary()); err == nil {
+		return nil
+	}
+
+	data, _, downloadErr := generator.DownloadPhar(phiveUrl, "", phiveSigningKeys)
+
+	if downloadErr != nil {
+		return downloadErr
+	}
+
+	return generator.writeExecutable(generator.phiveBinary(), data)
+}
+
+/**
+ * Install the tool with PHIVE, which checks the GPG signature of the release against the keys of the registry
+ */
+func (generator *Generator) InstallWithPhive(definition tools.Definition) error {
+	trackErr := generator.trackDirectory(path.Dir(phiveConfigFile))
+
+	if trackErr == nil {
+		trackErr = generator.trackDirectory(path.Join(generator.RelativeToolsDirectory(), tools.PhiveDirectory))
+	}
+
+	if trackErr != nil {
+		return trackErr
+	}
+
+	backupErr := generator.BackupFile(phiveConfigFile)
+
+	if backupErr != nil {
+		return backupErr
+	}
+
+	return generator.Run(generator.getPhiveInstallCommand(definition))
+}
+
+/**
+ * Return the command installing the tool with PHIVE from the project, phars are copied since links to the home of
+ * PHIVE would break in containers
+ */
+func (generator *Generator) getPhiveInstallCommand(definition tools.Definition) []string {
+	command := []string{"php", generator.phiveBinary(), "install", "--copy", "--target", path.Join(generator.RelativeToolsDirectory(), tools.PhiveDirectory)}
+
+	if len(definition.SigningKeys) > 0 {
+		command = append(command, "--trust-gpg-keys", strings.Join(definition.SigningKeys, ","))
+	}
+
+	if constraint := generator.Config.Versions[definition.Id]; constraint != "" {
+		return append(command, definition.Phive.Alias+"@"+constraint)
+	}
+
+	return append(command, definition.Phive.Alias)
+}
+
+/**
+ * Return the command installing every tool pinned in .phive/phars.xml from the project, e.g. in install-php after a
+ * clone, trusting the keys signing the tools of the project installed with PHIVE
+ */
+func (generator *Generator) getPhiveRestoreCommand() ([]string, error) {
+	projectLock, err := generator.Lock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	for _, tool := range projectTools {
+		definition, _ := tools.Get(tool)
+		locked, wasInstalled := projectLock.Tools[tool]
+
+		if !generator.Config.UsesPhive(tool) && (!wasInstalled || locked.Phive == nil) {
+			continue
+		}
+
+		for _, key := range definition.SigningKeys {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	command := []string{"php", generator.phiveBinary(), "install", "--copy", "--target", path.Join(generator.RelativeToolsDirectory(), tools.PhiveDirectory)}
+
+	if len(keys) > 0 {
+		command = append(command, "--trust-gpg-keys", strings.Join(keys, ","))
+	}
+
+	return command, nil
+}
+
+/**
+ * Record the tool with the version PHIVE installed
+ */
+func (generator *Generator) RecordPhive(definition tools.Definition) error {
+	return generator.recordInstall(definition.Id, lock.Tool{
+		Phive:      &lock.Phive{Alias: definition.Phive.Alias, Version: generator.getPhiveVersion(definition.Phive.Alias)},
+		Constraint: generator.Config.Versions[definition.Id],
+	})
+}
+
+/**
+ * Return the version of the tool installed by PHIVE, empty when its configuration can't be read
+ */
+func (generator *Generator) getPhiveVersion(alias string) string {
+	data, readErr := generator.Files.ReadFile(phiveConfigFile)
+
+	if readErr != nil {
+		return ""
+	}
+
+	var config phiveConfig
+
+	if xml.Unmarshal(data, &config) != nil {
+		return ""
+	}
+
+	for _, phar := range config.Phars {
+		if phar.Name == alias {
+			return phar.Installed
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,201 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Command printing the major.minor version of the PHP the tools run with
+var phpVersionCommand = []string{"php", "-r", "echo PHP_MAJOR_VERSION.'.'.PHP_MINOR_VERSION;"}
+
+// Extensions installed with PECL, the others come with PHP
+var peclExtensions = []string{"ast", "pcov", "xdebug"}
+
+// Packages of Debian and Ubuntu (php-<package>) providing the extensions which aren't named after them
+var extensionPackages = map[string]string{
+	"ctype":     "common",
+	"dom":       "xml",
+	"libxml":    "xml",
+	"phar":      "common",
+	"simplexml": "xml",
+	"tokenizer": "common",
+	"xmlwriter": "xml",
+}
+
+/**
+ * Return the major.minor version of the PHP where commands are run, looked up once
+ */
+func (generator *Generator) RuntimePhpVersion() (string, error) {
+	if generator.runtimePhpVersion != "" {
+		return generator.runtimePhpVersion, nil
+	}
+
+	version, err := runner.Output(generator.ctx, generator.Runner, phpVersionCommand, "find the PHP version")
+
+	if err != nil {
+		return "", err
+	}
+
+	generator.runtimePhpVersion = version
+
+	return version, nil
+}
+
+/**
+ * Check that the tools to install run on the PHP of the environment, or on the version of composer.json when it can't
+ * be run. The incompatible tools are reported with a warning, an error is returned when one of them can't be
+ * installed at all.
+ */
+func (generator *Generator) CheckPhpSupport() error {
+	phpVersion, versionErr := generator.RuntimePhpVersion()
+
+	if versionErr != nil {
+		slog.Warn("Could not find the PHP version of the environment, the tools are checked against composer.json", "error", versionErr)
+		phpVersion = generator.Config.PhpVersion
+	}
+
+	installed := generator.getInstalledTools()
+	toolVersions := make(map[tools.Tool]string)
+
+	for _, tool := range installed {
+		toolVersions[tool] = project.MinimumPhpVersion(generator.Config.Versions[tool])
+	}
+
+	var blocking []string
+
+	for _, incompatibility := range tools.FindPhpIncompatibilities(installed, phpVersion, toolVersions) {
+		if incompatibility.Blocking {
+			blocking = append(blocking, incompatibility.Reason+" ("+incompatibility.Guidance+")")
+			continue
+		}
+
+		slog.Warn(incompatibility.Reason+", PHP "+phpVersion+" is used", "tool", incompatibility.Tool, "guidance", incompatibility.Guidance)
+	}
+
+	if len(blocking) > 0 {
+		return failure.New(failure.Configuration, "check the PHP version", "PHP "+phpVersion+" is used but "+strings.Join(blocking, ", "))
+	}
+
+	return nil
+}
+
+/**
+ * Return the extensions loaded by the PHP where commands are run, lowercased, looked up once
+ */
+func (generator *Generator) PhpExtensions() ([]string, error) {
+	if generator.phpExtensions != nil {
+		return generator.phpExtensions, nil
+	}
+
+	captured, err := generator.Capture([]string{"php", "-m"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Headers like [PHP Modules] are kept, no extension is named like them
+	generator.phpExtensions = strings.Fields(strings.ToLower(captured.Output))
+
+	return generator.phpExtensions, nil
+}
+
+/**
+ * Check that the PHP of the environment loads the extensions needed by the tools to install, before composer takes
+ * its time to refuse them. The missing extensions are returned in an error telling how to install them, a missing
+ * coverage driver is only a warning as the coverage recipe can be written without it.
+ */
+func (generator *Generator) CheckPhpExtensions() error {
+	loaded, extensionsErr := generator.PhpExtensions()
+
+	if extensionsErr != nil {
+		slog.Warn("Could not list the PHP extensions of the environment, they aren't checked", "error", extensionsErr)
+		return nil
+	}
+
+	var missing []string
+	neededBy := make(map[string][]string)
+
+	for _, tool := range generator.getInstalledTools() {
+		definition, _ := tools.Get(tool)
+
+		for _, extension := range definition.Extensions {
+			extension = strings.ToLower(extension)
+
+			if slices.Contains(loaded, extension) {
+				continue
+			}
+
+			if !slices.Contains(missing, extension) {
+				missing = append(missing, extension)
+			}
+
+			neededBy[extension] = append(neededBy[extension], definition.Name)
+		}
+	}
+
+	if generator.Config.HasOutput(config.Coverage) && !slices.Contains(loaded, "pcov") && !slices.Contains(loaded, "xdebug") {
+		slog.Warn("Neither pcov nor xdebug is enabled where PHP runs, install one of them to run just coverage", "hint", generator.getExtensionHint("pcov"))
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	problems := make([]string, len(missing))
+
+	for i, extension := range missing {
+		problems[i] = extension + " (needed by " + strings.Join(neededBy[extension], ", ") + ", " + generator.getExtensionHint(extension) + ")"
+	}
+
+	return failure.New(failure.Environment, "check the PHP extensions", "missing extensions: "+strings.Join(problems, "; "))
+}
+
+/**
+ * Tell how to install the extension where PHP runs
+ */
+func (generator *Generator) getExtensionHint(extension string) string {
+	isPecl := slices.Contains(peclExtensions, extension)
+	debianPackage := extensionPackages[extension]
+
+	if debianPackage == "" {
+		debianPackage = extension
+	}
+
+	switch generator.Config.Environment {
+	case config.Ddev:
+		return "add php${DDEV_PHP_VERSION}-" + debianPackage + " to webimage_extra_packages in .ddev/config.yaml"
+	case config.DockerCompose, config.Kubernetes:
+		if isPecl {
+			return "add pecl install " + extension + " && docker-php-ext-enable " + extension + " to the Dockerfile of the image"
+		}
+
+		return "add docker-php-ext-install " + extension + " to the Dockerfile of the image"
+	}
+
+	if isPecl {
+		return "install it with pecl install " + extension + " or the php-" + debianPackage + " package of the system"
+	}
+
+	return "install the php-" + debianPackage + " package of the system or enable it in php.ini"
+}
+
+/**
+ * Return the selected tools which are installed by the run, leaving out the ones already installed and skipped
+ */
+func (generator *Generator) getInstalledTools() []tools.Tool {
+	var installed []tools.Tool
+
+	for _, tool := range generator.Config.Tools {
+		if generator.Config.ToolActions[tool] != config.SkipTool {
+			installed = append(installed, tool)
+		}
+	}
+
+	return installed
+}
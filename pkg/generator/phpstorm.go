@@ -0,0 +1,364 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/hex"
+	"html"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Files of the PhpStorm project shared with the team, relative to the project
+const (
+	phpStormPhpFile     = ".idea/php.xml"
+	phpStormProfileFile = ".idea/inspectionProfiles/Project_Default.xml"
+	// Directory of the run configurations, one file by configuration
+	phpStormRunDirectory = ".idea/runConfigurations"
+)
+
+// Variable of PhpStorm replaced by the directory of the project
+const phpStormProjectDir = "$PROJECT_DIR$"
+
+// Inspections of PhpStorm running the tools on the opened files
+var phpStormInspections = map[tools.Tool]string{
+	tools.PhpStan:    "PhpStanGlobal",
+	tools.PhpCsFixer: "PhpCSFixerValidationInspection",
+	tools.PhpCS:      "PhpCSValidationInspection",
+	tools.PhpMD:      "MessDetectorValidationInspection",
+	tools.Psalm:      "PsalmGlobal",
+}
+
+/**
+ * Point the quality tools integrations of PhpStorm at the tools of the project and their configuration files in
+ * .idea/php.xml, with the docker compose interpreter running them when PHP runs in docker, enable their inspections in
+ * the project profile and add a run configuration by recipe. The other settings of existing files are kept.
+ */
+func (generator *Generator) GeneratePhpStormConfig() error {
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return err
+	}
+
+	interpreterId := ""
+	var components []string
+
+	if interpreter, id, interpreterErr := generator.getPhpStormInterpreter(); interpreterErr != nil {
+		return interpreterErr
+	} else if interpreter != "" {
+		interpreterId = id
+		components = append(components, interpreter)
+	}
+
+	if generator.Config.Environment == config.Kubernetes {
+		slog.Warn("PhpStorm can't run the tools in a pod, their paths in .idea/php.xml are the ones of the pod for a remote interpreter to be set up")
+	}
+
+	if generator.Config.PhpVersion != "" {
+		components = append(components, `  <component name="PhpProjectSharedConfiguration" php_language_level="`+xmlAttribute(generator.Config.PhpVersion)+`" />`)
+	}
+
+	var inspections []string
+
+	for _, tool := range projectTools {
+		inspection, integrated := phpStormInspections[tool]
+
+		if !integrated {
+			continue
+		}
+
+		toolComponents, toolErr := generator.getPhpStormToolComponents(tool, interpreterId)
+
+		if toolErr != nil {
+			return toolErr
+		}
+
+		components = append(components, toolComponents...)
+		inspections = append(inspections, `    <inspection_tool class="`+inspection+`" enabled="true" level="WEAK WARNING" enabled_by_default="true" />`)
+	}
+
+	runErr := generator.generatePhpStormRunConfigurations()
+
+	if runErr != nil {
+		return runErr
+	}
+
+	phpErr := generator.mergePhpStormFile(phpStormPhpFile, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<project version=\"4\">\n</project>\n", "</project>", "component", "name", components)
+
+	if phpErr != nil {
+		return phpErr
+	}
+
+	if len(inspections) == 0 {
+		return nil
+	}
+
+	profile := `<component name="InspectionProjectProfileManager">
+  <profile version="1.0">
+    <option name="myName" value="Project Default" />
+  </profile>
+</component>
+`
+
+	return generator.mergePhpStormFile(phpStormProfileFile, profile, "</profile>", "inspection_tool", "class", inspections)
+}
+
+/**
+ * Write a shell run configuration running just for each recipe of phptooling, so that the recipes are in the run menu
+ * of the IDE. The recipes run the tools with the prefix of the environment, e.g. docker compose exec.
+ */
+func (generator *Generator) generatePhpStormRunConfigurations() error {
+	recipes, err := generator.getProjectRecipes()
+
+	if err != nil {
+		return err
+	}
+
+	for _, recipe := range recipes {
+		file := phpStormRunDirectory + "/just_" + strings.ReplaceAll(recipe.name, "-", "_") + ".xml"
+		content := `<component name="ProjectRunConfigurationManager">
+  <configuration default="false" name="just ` + xmlAttribute(recipe.name) + `" type="ShConfigurationType" folderName="phptooling">
+    <option name="SCRIPT_TEXT" value="just ` + xmlAttribute(recipe.name) + `" />
+    <option name="INDEPENDENT_SCRIPT_PATH" value="true" />
+    <option name="SCRIPT_PATH" value="" />
+    <option name="SCRIPT_OPTIONS" value="" />
+    <option name="INDEPENDENT_SCRIPT_WORKING_DIRECTORY" value="true" />
+    <option name="SCRIPT_WORKING_DIRECTORY" value="` + phpStormProjectDir + `" />
+    <option name="INDEPENDENT_INTERPRETER_PATH" value="true" />
+    <option name="INTERPRETER_PATH" value="" />
+    <option name="INTERPRETER_OPTIONS" value="" />
+    <option name="EXECUTE_IN_TERMINAL" value="true" />
+    <option name="EXECUTE_SCRIPT_FILE" value="false" />
+    <envs />
+    <method v="2" />
+  </configuration>
+</component>
+`
+
+		if existing, readErr := generator.readText(file); readErr == nil && existing == content {
+			continue
+		}
+
+		writeErr := generator.WriteProjectFile(file, content)
+
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Return the components of .idea/php.xml configuring the integration of the tool: the path of its binary, run by the
+ * interpreter when given, and its configuration file
+ */
+func (generator *Generator) getPhpStormToolComponents(tool tools.Tool, interpreterId string) ([]string, error) {
+	definition, _ := tools.Get(tool)
+	binary, err := generator.getPhpStormBinary(tool, generator.Config.Binary(tool))
+
+	if err != nil {
+		return nil, err
+	}
+
+	toolPath := `tool_path="` + xmlAttribute(binary) + `"`
+
+	if interpreterId != "" {
+		toolPath = `interpreter_id="` + interpreterId + `" ` + toolPath
+	}
+
+	configFile := ""
+
+	if files := definition.ConfigFiles(string(generator.Config.Framework)); len(files) > 0 {
+		configFile = xmlAttribute(phpStormProjectDir + "/" + files[0].Destination)
+	}
+
+	switch tool {
+	case tools.PhpStan:
+		return []string{`  <component name="PhpStan">
+    <PhpStan_settings>
+      <PhpStanConfiguration ` + toolPath + ` />
+    </PhpStan_settings>
+  </component>`, `  <component name="PhpStanOptionsConfiguration">
+    <option name="config" value="` + configFile + `" />
+    <option name="transferred" value="true" />
+  </component>`}, nil
+	case tools.PhpCsFixer:
+		return []string{`  <component name="PhpCSFixer">
+    <phpcsfixer_settings>
+      <PhpCSFixerConfiguration ` + toolPath + ` />
+    </phpcsfixer_settings>
+  </component>`, `  <component name="PHPCSFixerOptionsConfiguration">
+    <option name="codingStandard" value="Custom" />
+    <option name="rulesetPath" value="` + configFile + `" />
+    <option name="transferred" value="true" />
+  </component>`}, nil
+	case tools.PhpCS:
+		beautifier, beautifierErr := generator.getPhpStormBinary(tool, definition.Fix.Binary)
+
+		if beautifierErr != nil {
+			return nil, beautifierErr
+		}
+
+		return []string{`  <component name="PhpCodeSniffer">
+    <phpcs_settings>
+      <PhpCSConfiguration ` + toolPath + ` beautifier_path="` + xmlAttribute(beautifier) + `" />
+    </phpcs_settings>
+  </component>`, `  <component name="PHPCodeSnifferOptionsConfiguration">
+    <option name="codingStandard" value="Custom" />
+    <option name="customRuleset" value="` + configFile + `" />
+    <option name="transferred" value="true" />
+  </component>`}, nil
+	case tools.PhpMD:
+		return []string{`  <component name="MessDetector">
+    <phpmd_settings>
+      <MessDetectorConfiguration ` + toolPath + ` />
+    </phpmd_settings>
+  </component>`, `  <component name="MessDetectorOptionsConfiguration">
+    <option name="customRulesets">
+      <list>
+        <RulesetDescriptor>
+          <option name="path" value="` + configFile + `" />
+        </RulesetDescriptor>
+      </list>
+    </option>
+    <option name="transferred" value="true" />
+  </component>`}, nil
+	case tools.Psalm:
+		return []string{`  <component name="Psalm">
+    <Psalm_settings>
+      <PsalmConfiguration ` + toolPath + ` />
+    </Psalm_settings>
+  </component>`, `  <component name="PsalmOptionsConfiguration">
+    <option name="config" value="` + configFile + `" />
+    <option name="transferred" value="true" />
+  </component>`}, nil
+	}
+
+	return nil, nil
+}
+
+/**
+ * Return the path of the binary for PhpStorm: relative to the project directory on the host, as it is where PHP runs
+ * otherwise (e.g. in the container of the interpreter)
+ */
+func (generator *Generator) getPhpStormBinary(tool tools.Tool, binary string) (string, error) {
+	binaryPath, err := generator.ToolBinary(tool, binary)
+
+	if err != nil || generator.Config.Environment != config.Local {
+		return binaryPath, err
+	}
+
+	workingDir, err := generator.WorkingDirectory()
+
+	if relative, found := strings.CutPrefix(binaryPath, workingDir+"/"); found {
+		return phpStormProjectDir + "/" + relative, err
+	}
+
+	return binaryPath, err
+}
+
+/**
+ * Return the component of .idea/php.xml declaring the docker compose interpreter running PHP, along with its id.
+ * Empty when PHP doesn't run in docker compose or ddev.
+ */
+func (generator *Generator) getPhpStormInterpreter() (string, string, error) {
+	composeFile := runner.DetectComposeFile(runner.LocalWorkingDirectory())
+	service := generator.Config.DockerService
+
+	switch generator.Config.Environment {
+	case config.Ddev:
+		composeFile = ".ddev/.ddev-docker-compose-full.yaml"
+		service = "web"
+	case config.DockerCompose:
+		if composeFile == "" {
+			return "", "", nil
+		}
+	default:
+		return "", "", nil
+	}
+
+	remoteDir, err := generator.WorkingDirectory()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	// Derived from the service so that the next runs update the same interpreter
+	hash := sha256.Sum256([]byte("phptooling:" + service))
+	id := hex.EncodeToString(hash[:4]) + "-" + hex.EncodeToString(hash[4:6]) + "-" + hex.EncodeToString(hash[6:8]) + "-" + hex.EncodeToString(hash[8:10]) + "-" + hex.EncodeToString(hash[10:16])
+	composePath := xmlAttribute(phpStormProjectDir + "/" + composeFile)
+
+	return `  <component name="PhpInterpreters">
+    <interpreters>
+      <interpreter id="` + id + `" name="` + xmlAttribute(service) + ` (phptooling)" home="docker-compose://[` + composePath + `]:` + xmlAttribute(service) + `/php" debugger_id="php.debugger.XDebug">
+        <remote_data INTERPRETER_PATH="php" HELPERS_PATH="/opt/.phpstorm_helpers" INITIALIZED="false" VALID="true" RUN_AS_ROOT_VIA_SUDO="false" DOCKER_ACCOUNT_NAME="Docker" DOCKER_COMPOSE_SERVICE_NAME="` + xmlAttribute(service) + `" DOCKER_REMOTE_PROJECT_PATH="` + xmlAttribute(remoteDir) + `">
+          <dockerComposeConfigurationPaths>
+            <item value="` + composePath + `" />
+          </dockerComposeConfigurationPaths>
+        </remote_data>
+      </interpreter>
+    </interpreters>
+  </component>`, id, nil
+}
+
+/**
+ * Write the elements to the PhpStorm file, replacing the ones with the same key attribute (e.g. the component names)
+ * and adding the others before the closing tag of their parent. An existing interpreters component only receives the
+ * interpreter of phptooling, the ones of the user are kept.
+ */
+func (generator *Generator) mergePhpStormFile(relativePath string, skeleton string, parentClose string, tag string, key string, elements []string) error {
+	existing, readErr := generator.readText(relativePath)
+	content := existing
+
+	if readErr != nil {
+		content = skeleton
+	}
+
+	if !strings.Contains(content, parentClose) {
+		slog.Warn("Leaving "+relativePath+" as it is, it isn't a PhpStorm file", "expected", parentClose)
+		return nil
+	}
+
+	for _, element := range elements {
+		if interpreter, isInterpreters := strings.CutPrefix(element, `  <component name="PhpInterpreters">`); isInterpreters && strings.Contains(content, `<component name="PhpInterpreters">`) && strings.Contains(content, "</interpreters>") {
+			interpreter = interpreter[strings.Index(interpreter, "      <interpreter ") : strings.Index(interpreter, "</interpreter>")+len("</interpreter>")]
+			content = mergeXmlElement(content, "</interpreters>", "interpreter", "id", interpreter)
+			continue
+		}
+
+		content = mergeXmlElement(content, parentClose, tag, key, element)
+	}
+
+	if content == existing {
+		return nil
+	}
+
+	return generator.WriteProjectFile(relativePath, content)
+}
+
+/**
+ * Replace the element having the same key attribute as the given one in the XML document, or add it as the last
+ * child of the parent closing with parentClose
+ */
+func mergeXmlElement(document string, parentClose string, tag string, key string, element string) string {
+	value := regexp.MustCompile(key + `="([^"]*)"`).FindStringSubmatch(element)[1]
+	pattern := regexp.MustCompile(`(?s)[ \t]*<` + tag + `\s[^>]*?` + key + `="` + regexp.QuoteMeta(value) + `"[^>]*?(?:/>|>.*?</` + tag + `>)`)
+
+	if location := pattern.FindStringIndex(document); location != nil {
+		return document[:location[0]] + element + document[location[1]:]
+	}
+
+	end := strings.LastIndex(document, parentClose)
+	lineStart := strings.LastIndex(document[:end], "\n") + 1
+
+	return document[:lineStart] + element + "\n" + document[lineStart:]
+}
+
+func xmlAttribute(value string) string {
+	return html.EscapeString(value)
+}
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"log/slog"
+	"strings"
+)
+
+/**
+ * Show the change about to be made to the file (the content replacing it, or appended to it) to the Config, return the
+ * content to write, possibly edited, and false when the change is skipped
+ */
+func (generator *Generator) previewChange(destination string, content string, appended bool) (string, bool) {
+	if generator.Config.PreviewChange == nil {
+		return content, true
+	}
+
+	before, readErr := generator.readText(destination)
+	after := content
+
+	if appended {
+		after = before + content
+	}
+
+	var lines []DiffLine
+
+	if readErr != nil || before == "" {
+		// Every line of a new file is inserted
+		for _, text := range strings.Split(strings.TrimRight(after, "\n"), "\n") {
+			lines = append(lines, DiffLine{DiffInsert, text})
+		}
+	} else {
+		lines = diffLines(before, after)
+	}
+
+	if !hasDifferences(lines) {
+		return content, true
+	}
+
+	previewed, write := generator.Config.PreviewChange(destination, formatDiff(lines), content)
+
+	if !write {
+		slog.Info("Skipping the change", "file", destination)
+	}
+
+	return previewed, write
+}
@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/logging"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * Fetch the templates of the Config source into the user cache, reusing a previous fetch of the same source and ref
+ */
+func (generator *Generator) FetchRemoteTemplates() error {
+	templatesSource := generator.Config.Templates.Source
+	source, ref, _ := strings.Cut(templatesSource, "#")
+
+	cacheDir, err := os.UserCacheDir()
+
+	if err != nil {
+		return failure.Wrap(failure.Environment, "find the user cache directory", err)
+	}
+
+	// Templates fetched without the checks of this run mustn't be reused
+	hash := sha256.Sum256([]byte(templatesSource + "#" + generator.Config.Templates.Sha256 + "#" + strings.Join(generator.Config.Templates.SigningKeys, ",")))
+	generator.remoteTemplatesDirectory = filepath.Join(cacheDir, "phptooling", "templates", hex.EncodeToString(hash[:])[:16])
+
+	_, statErr := os.Stat(generator.remoteTemplatesDirectory)
+
+	if statErr == nil && !generator.Config.Templates.Refresh {
+		slog.Info("Using cached templates", "source", templatesSource)
+		return nil
+	}
+
+	removeErr := os.RemoveAll(generator.remoteTemplatesDirectory)
+
+	if removeErr != nil {
+		return failure.Wrap(failure.FileSystem, "clear the templates cache", removeErr)
+	}
+
+	slog.Info("Fetching templates", "source", templatesSource)
+
+	var fetchErr error
+
+	if strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz") {
+		fetchErr = generator.downloadTemplatesArchive(source, generator.remoteTemplatesDirectory)
+	} else if generator.Config.Templates.Sha256 != "" {
+		fetchErr = failure.New(failure.Configuration, "fetch the templates", "a SHA-256 can only be checked for .tar.gz archives, use signing keys for git repositories")
+	} else {
+		fetchErr = generator.cloneTemplatesRepository(source, ref, generator.remoteTemplatesDirectory)
+	}
+
+	if fetchErr != nil {
+		// A partial fetch would otherwise be reused as cache by the next run
+		os.RemoveAll(generator.remoteTemplatesDirectory)
+	}
+
+	return fetchErr
+}
+
+/**
+ * Clone the repository at the ref, the fetched commit must be signed by one of the signing keys when there are some
+ */
+func (generator *Generator) cloneTemplatesRepository(repository string, ref string, destination string) error {
+	args := []string{"clone", "--depth", "1"}
+
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+
+	cmd := exec.Command("git", append(args, repository, destination)...)
+	cmd.Stdout = logging.Console()
+	cmd.Stderr = os.Stderr
+
+	cloneErr := cmd.Run()
+
+	if cloneErr != nil || len(generator.Config.Templates.SigningKeys) == 0 {
+		return failure.Wrap(failure.Environment, "clone the templates from "+repository, cloneErr)
+	}
+
+	operation := "check the signature of the templates from " + repository
+
+	return withKeyring(generator.ctx, operation, generator.Config.Templates.SigningKeys, func(keyring string) error {
+		var output bytes.Buffer
+		verify := exec.CommandContext(generator.ctx, "git", "-C", destination, "verify-commit", "HEAD")
+		verify.Env = append(os.Environ(), "GNUPGHOME="+keyring)
+		verify.Stdout = &output
+		verify.Stderr = &output
+		verifyErr := verify.Run()
+
+		if verifyErr != nil {
+			return &failure.Error{Kind: failure.Verification, Operation: operation, Err: errors.New("the commit isn't signed by one of the signing keys"), Output: strings.TrimSpace(output.String())}
+		}
+
+		return nil
+	})
+}
+
+/**
+ * Download and extract a .tar.gz archive after checking its SHA-256 and signature when expected, the top-level
+ * directory of archives generated by forges is stripped
+ */
+func (generator *Generator) downloadTemplatesArchive(url string, destinationDirectory string) error {
+	operation := "download the templates from " + url
+	archive, _, err := generator.download(url)
+
+	if err != nil {
+		return err
+	}
+
+	shaErr := verifySha256(operation, archive, generator.Config.Templates.Sha256)
+
+	if shaErr != nil {
+		return shaErr
+	}
+
+	if keys := generator.Config.Templates.SigningKeys; len(keys) > 0 {
+		signature, _, signatureErr := generator.download(url + ".asc")
+
+		if signatureErr != nil {
+			return signatureErr
+		}
+
+		verifyErr := verifySignature(generator.ctx, "check the signature of "+url, archive, signature, keys)
+
+		if verifyErr != nil {
+			return verifyErr
+		}
+	}
+
+	gzipReader, gzipErr := gzip.NewReader(bytes.NewReader(archive))
+
+	if gzipErr != nil {
+		return failure.Wrap(failure.Environment, operation, gzipErr)
+	}
+
+	var entries []*tar.Header
+	contents := make(map[string][]byte)
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, readErr := tarReader.Next()
+
+		if readErr == io.EOF {
+			break
+		}
+
+		if readErr != nil {
+			return failure.Wrap(failure.Environment, operation, readErr)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, dataErr := io.ReadAll(tarReader)
+
+		if dataErr != nil {
+			return failure.Wrap(failure.Environment, operation, dataErr)
+		}
+
+		entries = append(entries, header)
+		contents[header.Name] = data
+	}
+
+	prefix := getCommonDirectoryPrefix(entries)
+
+	for _, header := range entries {
+		name := path.Clean(strings.TrimPrefix(header.Name, prefix))
+
+		// Never write outside of the cache directory
+		if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			continue
+		}
+
+		destination := filepath.Join(destinationDirectory, name)
+
+		mkdirErr := os.MkdirAll(filepath.Dir(destination), 0755)
+
+		if mkdirErr != nil {
+			return failure.Wrap(failure.FileSystem, "extract "+name, mkdirErr)
+		}
+
+		writeErr := os.WriteFile(destination, contents[header.Name], 0644)
+
+		if writeErr != nil {
+			return failure.Wrap(failure.FileSystem, "extract "+name, writeErr)
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Return the top-level directory shared by every entry (e.g. "templates-1.2.0/"), or an empty string
+ */
+func getCommonDirectoryPrefix(entries []*tar.Header) string {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	prefix, _, found := strings.Cut(entries[0].Name, "/")
+
+	if !found {
+		return ""
+	}
+
+	for _, header := range entries {
+		if !strings.HasPrefix(header.Name, prefix+"/") {
+			return ""
+		}
+	}
+
+	return prefix + "/"
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/tools"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+/**
+ * Require the packages of the tool in the require-dev of the project. Packages it already requires keep their
+ * constraint unless the Config gives one, and its locked dependencies are never updated for the others: composer then
+ * fails on the conflict, leaving composer.json unchanged.
+ */
+func (generator *Generator) RequireInProject(definition tools.Definition) error {
+	composerJson, _, readErr := project.ReadComposerJson(runner.LocalWorkingDirectory())
+
+	if readErr != nil {
+		return readErr
+	}
+
+	var missing []string
+
+	// Requirements followed by a constraint never match a package name, so the constraint is applied
+	for _, requirement := range generator.Config.Requirements(definition) {
+		if composerJson.Requires(requirement) {
+			slog.Info(requirement+" is already required by the project, its constraint is kept", "tool", definition.Name)
+		} else {
+			missing = append(missing, requirement)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, file := range []string{"composer.json", "composer.lock"} {
+		backupErr := generator.BackupFile(file)
+
+		if backupErr != nil {
+			return backupErr
+		}
+	}
+
+	requireErr := generator.Run(append([]string{"composer", "require", "--dev"}, missing...))
+
+	if failure.KindOf(requireErr) == failure.Composer {
+		return &failure.Error{
+			Kind:      failure.Composer,
+			Operation: "require " + definition.Name + " in the project",
+			Err:       errors.New(strings.Join(missing, ", ") + " can't be installed along with the dependencies of the project, choose another install method to isolate it"),
+			Output:    failure.OutputOf(requireErr),
+		}
+	}
+
+	return requireErr
+}
+
+/**
+ * Record the tool as required by the project, with the versions of its composer.lock
+ */
+func (generator *Generator) RecordRequireDev(tool tools.Tool, packages []string) error {
+	return generator.recordInstall(tool, lock.Tool{RequireDev: true, Packages: lock.ReadComposerVersions(runner.LocalWorkingDirectory(), packages)})
+}
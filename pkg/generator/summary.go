@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// Header of a justfile recipe, a name followed by its parameters, variables are assigned with :=. Justfiles checked
+// out on Windows may end their lines with CRLF.
+var recipeHeaderPattern = regexp.MustCompile(`(?m)^([A-Za-z][\w-]*)(?: [^\n]*)?:\r?$`)
+
+// Summary lists what a run changed in the project, relative to it
+type Summary struct {
+	// Files and directories (ending with /), the content of created directories isn't listed
+	Created  []string
+	Modified []string
+	// Names of the justfile recipes added by the run
+	Recipes []string
+}
+
+/**
+ * Return what the run changed in the project so far, the modified files are compared with their backups
+ */
+func (generator *Generator) Summary() Summary {
+	manifest := generator.backupManifest
+	summary := Summary{Recipes: slices.Clone(generator.addedRecipes)}
+
+	for _, file := range manifest.Modified {
+		// Files written again with the same content are left out
+		backup, backupErr := os.ReadFile(filepath.Join(generator.backupDirectory, file))
+		content, readErr := generator.Files.ReadFile(file)
+
+		if backupErr != nil || readErr != nil || !bytes.Equal(backup, content) {
+			summary.Modified = append(summary.Modified, file)
+		}
+	}
+
+	for _, directory := range manifest.Directories {
+		summary.Created = append(summary.Created, directory+"/")
+	}
+
+	for _, file := range manifest.Created {
+		if !generator.isInCreatedDirectory(file) {
+			summary.Created = append(summary.Created, file)
+		}
+	}
+
+	slices.Sort(summary.Created)
+	slices.Sort(summary.Modified)
+
+	return summary
+}
+
+/**
+ * Remember the recipes of the content appended to the justfile for the summary
+ */
+func (generator *Generator) trackRecipes(content string) {
+	generator.addedRecipes = append(generator.addedRecipes, RecipeNames(content)...)
+}
+
+/**
+ * Return the names of the recipes defined by the justfile content, in their order
+ */
+func RecipeNames(content string) []string {
+	var names []string
+
+	for _, match := range recipeHeaderPattern.FindAllStringSubmatch(content, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
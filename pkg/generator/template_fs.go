@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/runner"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Directory of the templates in every layer, override directories hold its content directly
+const templatesRoot = "config-files"
+
+// TemplateLayer is a source of templates, rooted at the directory containing config-files/
+type TemplateLayer struct {
+	// project, remote, user or embedded
+	Name  string
+	Files fs.FS
+}
+
+// LayeredFS reads each template from the first layer holding it, directories list the files of every layer
+type LayeredFS struct {
+	Layers []TemplateLayer
+}
+
+func (layered LayeredFS) Open(name string) (fs.File, error) {
+	for _, layer := range layered.Layers {
+		file, err := layer.Files.Open(name)
+
+		if err == nil {
+			return file, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (layered LayeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+	found := false
+
+	// Entries of the layers of higher precedence win
+	for i := len(layered.Layers) - 1; i >= 0; i-- {
+		layerEntries, err := fs.ReadDir(layered.Layers[i].Files, name)
+
+		if err != nil {
+			continue
+		}
+
+		found = true
+
+		for _, entry := range layerEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var sorted []fs.DirEntry
+
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+
+	return sorted, nil
+}
+
+/**
+ * Return the name of the layer the file is read from, the second value is false when no layer holds it
+ */
+func (layered LayeredFS) Layer(name string) (string, bool) {
+	for _, layer := range layered.Layers {
+		if _, err := fs.Stat(layer.Files, name); err == nil {
+			return layer.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// overrideFS serves a directory of overrides, e.g. phpstan/phpstan.neon.tmpl, as config-files/phpstan/phpstan.neon.tmpl
+type overrideFS struct {
+	files fs.FS
+}
+
+func (override overrideFS) Open(name string) (fs.File, error) {
+	if name == templatesRoot {
+		return override.files.Open(".")
+	}
+
+	relativeName, found := strings.CutPrefix(name, templatesRoot+"/")
+
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return override.files.Open(relativeName)
+}
+
+/**
+ * Return the templates of the run: the overrides of the project, the remote templates and the overrides of the user
+ * by order of precedence, then the embedded ones
+ */
+func (generator *Generator) Templates() LayeredFS {
+	layers := []TemplateLayer{{Name: "project", Files: overrideFS{files: os.DirFS(filepath.Join(runner.LocalWorkingDirectory(), ".phptooling", "templates"))}}}
+
+	if generator.remoteTemplatesDirectory != "" {
+		layers = append(layers, TemplateLayer{Name: "remote", Files: overrideFS{files: os.DirFS(generator.remoteTemplatesDirectory)}})
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		layers = append(layers, TemplateLayer{Name: "user", Files: overrideFS{files: os.DirFS(filepath.Join(homeDir, ".config", "phptooling", "templates"))}})
+	}
+
+	return LayeredFS{Layers: append(layers, TemplateLayer{Name: "embedded", Files: generator.templates})}
+}
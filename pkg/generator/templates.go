@@ -0,0 +1,274 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/project"
+	"ecohead/phptooling/pkg/tools"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateData holds every answer of the wizard which config templates can embed
+type TemplateData struct {
+	Paths          []string
+	PhpVersion     string
+	PhpVersionId   string
+	ToolsDirectory string
+	// Vendor directories of the packages of the tools, following how they are installed
+	PhpStanVendorDirectory string
+	PhpCSVendorDirectory   string
+	PsalmVendorDirectory   string
+	CacheDirectory         string
+	Docker                 bool
+	DockerService          string
+	PhpStanLevel           string
+	PhpStanBaseline        bool
+	PhpCsFixerRules        []string
+	PhpCsFixerRisky        bool
+	PhpCSStandard          string
+	PhpCSInstalledPaths    []string
+	PhpMDRulesets          []string
+	PhpMDComplexity        string
+	PhpMDMethodLength      string
+	PhpIndentStyle         string
+	PhpIndentSize          int
+	YamlIndentSize         int
+}
+
+func (generator *Generator) getTemplateData() (TemplateData, error) {
+	cfg := generator.Config
+	phpIndentStyle, phpIndentSize := generator.getPhpIndentation()
+	vendorDirectories := make(map[tools.Tool]string)
+
+	for _, tool := range []tools.Tool{tools.PhpStan, tools.PhpCS, tools.Psalm} {
+		vendorDirectory, err := generator.ToolVendorDirectory(tool)
+
+		if err != nil {
+			return TemplateData{}, err
+		}
+
+		vendorDirectories[tool] = vendorDirectory
+	}
+
+	return TemplateData{
+		Paths:                  cfg.Paths,
+		PhpVersion:             cfg.PhpVersion,
+		PhpVersionId:           project.PhpVersionId(cfg.PhpVersion),
+		ToolsDirectory:         generator.RelativeToolsDirectory(),
+		PhpStanVendorDirectory: vendorDirectories[tools.PhpStan],
+		PhpCSVendorDirectory:   vendorDirectories[tools.PhpCS],
+		PsalmVendorDirectory:   vendorDirectories[tools.Psalm],
+		CacheDirectory:         generator.RelativeCacheDirectory(),
+		Docker:                 cfg.Environment == config.DockerCompose,
+		DockerService:          cfg.DockerService,
+		PhpStanLevel:           cfg.PhpStan.Level,
+		PhpStanBaseline:        cfg.PhpStan.Baseline,
+		PhpCsFixerRules:        generator.getPhpCsFixerRules(),
+		PhpCsFixerRisky:        cfg.PhpCsFixer.Risky,
+		PhpCSStandard:          cfg.PhpCS.Standard,
+		PhpCSInstalledPaths:    generator.getPhpCSInstalledPaths(vendorDirectories[tools.PhpCS]),
+		PhpMDRulesets:          cfg.PhpMD.Rulesets,
+		PhpMDComplexity:        cfg.PhpMD.Complexity,
+		PhpMDMethodLength:      cfg.PhpMD.MethodLength,
+		PhpIndentStyle:         phpIndentStyle,
+		PhpIndentSize:          phpIndentSize,
+		YamlIndentSize:         generator.getYamlIndentSize(),
+	}, nil
+}
+
+/**
+ * Return the rule sets and rules enabled in the PHP CS Fixer configuration
+ */
+func (generator *Generator) getPhpCsFixerRules() []string {
+	cfg := generator.Config.PhpCsFixer
+	var rules []string
+
+	if cfg.Ruleset == "custom" {
+		for _, rule := range strings.Split(cfg.CustomRules, ",") {
+			if strings.TrimSpace(rule) != "" {
+				rules = append(rules, strings.TrimSpace(rule))
+			}
+		}
+
+		return rules
+	}
+
+	rules = append(rules, cfg.Ruleset)
+
+	if cfg.Risky {
+		rules = append(rules, cfg.Ruleset+":risky")
+	}
+
+	if migrationRuleset := generator.getPhpCsFixerMigrationRuleset(); migrationRuleset != "" {
+		rules = append(rules, migrationRuleset)
+	}
+
+	return rules
+}
+
+/**
+ * Return the PHP CS Fixer rule set modernizing the code up to the PHP version of the project
+ */
+func (generator *Generator) getPhpCsFixerMigrationRuleset() string {
+	versionId, _ := strconv.Atoi(project.PhpVersionId(generator.Config.PhpVersion))
+	migrationRuleset := ""
+
+	for _, version := range []int{54, 56, 70, 71, 73, 74, 80, 81, 82, 83, 84} {
+		if versionId >= version/10*10000+version%10*100 {
+			migrationRuleset = "@PHP" + strconv.Itoa(version) + "Migration"
+		}
+	}
+
+	return migrationRuleset
+}
+
+/**
+ * Return the paths where PHP_CodeSniffer finds the installed standards in its vendor directory, the composer installer
+ * plugin is not used so that no plugin needs to be trusted
+ */
+func (generator *Generator) getPhpCSInstalledPaths(vendorDir string) []string {
+	switch generator.Config.PhpCS.Standard {
+	case "Symfony":
+		return []string{vendorDir + "/escapestudios/symfony2-coding-standard"}
+	case "Slevomat":
+		return []string{vendorDir + "/slevomat/coding-standard"}
+	case "Doctrine":
+		return []string{vendorDir + "/doctrine/coding-standard/lib", vendorDir + "/slevomat/coding-standard"}
+	case "WordPress":
+		return []string{vendorDir + "/wp-coding-standards/wpcs", vendorDir + "/phpcsstandards/phpcsutils", vendorDir + "/phpcsstandards/phpcsextra"}
+	case "Drupal":
+		return []string{vendorDir + "/drupal/coder/coder_sniffer", vendorDir + "/slevomat/coding-standard", vendorDir + "/sirbrillig/phpcs-variable-analysis"}
+	}
+
+	return []string{}
+}
+
+/**
+ * Read a template from the config-files directory, unless it is overridden by the project or the user,
+ * e.g. config-files/phpstan/phpstan.neon.tmpl is overridden by .phptooling/templates/phpstan/phpstan.neon.tmpl
+ */
+func (generator *Generator) readTemplate(filePath string) (string, error) {
+	// Templates of plugins are read from their own directory
+	if filepath.IsAbs(filePath) {
+		data, err := os.ReadFile(filePath)
+
+		return string(data), failure.Wrap(failure.Configuration, "read the template "+filePath, err)
+	}
+
+	templates := generator.Templates()
+
+	if layer, found := templates.Layer(filePath); found && layer != "embedded" {
+		slog.Info("Using template override", "file", filePath, "layer", layer)
+	}
+
+	data, err := fs.ReadFile(templates, filePath)
+
+	return string(data), failure.Wrap(failure.Configuration, "read the template "+filePath, err)
+}
+
+/**
+ * Return the variant of the template for the framework of the project when there is one,
+ * e.g. config-files/frameworks/laravel/phpstan/phpstan.neon.tmpl for config-files/phpstan/phpstan.neon.tmpl
+ */
+func (generator *Generator) getFrameworkTemplate(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+
+	frameworkPath := path.Join("config-files", "frameworks", string(generator.Config.Framework), strings.TrimPrefix(filePath, "config-files/"))
+
+	_, err := fs.Stat(generator.Templates(), frameworkPath)
+
+	if err == nil {
+		return frameworkPath
+	}
+
+	return filePath
+}
+
+/**
+ * Render a template from the config-files directory with the answers of the wizard
+ */
+func (generator *Generator) RenderTemplate(filePath string) (string, error) {
+	filePath = generator.getFrameworkTemplate(filePath)
+	text, readErr := generator.readTemplate(filePath)
+
+	if readErr != nil {
+		return "", readErr
+	}
+
+	tmpl, parseErr := template.New(path.Base(filePath)).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+
+	if parseErr != nil {
+		return "", failure.Wrap(failure.Configuration, "parse the template "+filePath, parseErr)
+	}
+
+	data, dataErr := generator.getTemplateData()
+
+	if dataErr != nil {
+		return "", dataErr
+	}
+
+	var content strings.Builder
+
+	executeErr := tmpl.Execute(&content, data)
+
+	if executeErr != nil {
+		return "", failure.Wrap(failure.Configuration, "render the template "+filePath, executeErr)
+	}
+
+	return content.String(), nil
+}
+
+/**
+ * Render a template from the config-files directory to destination (relative to the project),
+ * asking the Config what to do when the file already exists with a different content (a conflict error without resolver)
+ */
+func (generator *Generator) CopyTemplate(filePath string, destination string) error {
+	content, renderErr := generator.RenderTemplate(filePath)
+
+	if renderErr != nil {
+		return renderErr
+	}
+
+	existing, readErr := generator.readText(destination)
+
+	if readErr == nil {
+		lines := diffLines(existing, content)
+
+		if !hasDifferences(lines) {
+			return generator.recordFile(destination, filePath)
+		}
+
+		// Existing files are never overwritten silently
+		if generator.Config.ResolveConflict == nil {
+			return failure.New(failure.FileConflict, "write "+destination, "the file already exists with a different content")
+		}
+
+		switch generator.Config.ResolveConflict(destination, formatDiff(lines)) {
+		case config.Skip:
+			slog.Info("Keeping the existing file", "file", destination)
+			return nil
+		case config.Merge:
+			content = mergeWithConflictMarkers(lines)
+			slog.Warn("Resolve the conflict markers written in the file", "file", destination)
+		}
+	} else {
+		previewed, write := generator.previewChange(destination, content, false)
+
+		if !write {
+			return nil
+		}
+
+		content = previewed
+	}
+
+	return generator.writeGeneratedFile(destination, filePath, content)
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/runner"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Options of the tools giving their configuration file, followed by the file or by = and the file
+var configOptions = []string{"-c", "--config", "--configuration", "--standard", "--ruleset"}
+
+/**
+ * Check that the recipes of the justfile blocks recorded in the lock refer to files which exist: the binaries and
+ * directories of the project (e.g. in the tools directory) and the configuration files given to the tools. Each
+ * missing file is returned as a message naming its block.
+ */
+func (generator *Generator) ValidateRecipes() ([]string, error) {
+	projectLock, lockErr := generator.Lock()
+
+	if lockErr != nil {
+		return nil, lockErr
+	}
+
+	workingDir, workingDirErr := generator.WorkingDirectory()
+
+	if workingDirErr != nil {
+		return nil, workingDirErr
+	}
+
+	var problems []string
+
+	for _, block := range projectLock.Blocks {
+		if block.File != "justfile" && block.File != config.ImportedJustFile {
+			continue
+		}
+
+		data, readErr := generator.readText(block.File)
+		startMarker, endMarker := getBlockMarkers(block.Name)
+		start, end, found := findBlock(data, startMarker, endMarker)
+
+		// Blocks without markers were written by previous versions
+		if readErr != nil || !found {
+			continue
+		}
+
+		for _, file := range getReferencedFiles(data[start:end], workingDir) {
+			if _, statErr := generator.Files.Stat(file); statErr != nil {
+				problems = append(problems, "the "+block.Name+" recipes of "+block.File+" refer to "+file+", which doesn't exist")
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+/**
+ * Return the files of the project the recipes refer to, relative to the project: the paths in the working directory
+ * of the commands and the configuration files. Paths using justfile variables or parameters are left out.
+ */
+func getReferencedFiles(recipes string, workingDir string) []string {
+	var files []string
+
+	for _, line := range strings.Split(recipes, "\n") {
+		// Recipe bodies are indented
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		// Quoted paths are unquoted, e.g. when the project directory has spaces
+		fields := runner.SplitCommandLine(line)
+
+		for i, field := range fields {
+			option, value, hasValue := strings.Cut(field, "=")
+			file := ""
+
+			if strings.HasPrefix(field, workingDir+"/") {
+				file = strings.TrimPrefix(field, workingDir+"/")
+			} else if hasValue && strings.HasPrefix(value, workingDir+"/") {
+				file = strings.TrimPrefix(value, workingDir+"/")
+			} else if hasValue && slices.Contains(configOptions, option) && isConfigFile(value) {
+				file = value
+			} else if slices.Contains(configOptions, field) && i+1 < len(fields) && isConfigFile(fields[i+1]) {
+				file = fields[i+1]
+			}
+
+			// Quotes and separators of shell commands
+			file = strings.Trim(file, `"';`)
+
+			if file != "" && !strings.Contains(file, "{{") && !strings.Contains(file, "$") && !slices.Contains(files, path.Clean(file)) {
+				files = append(files, path.Clean(file))
+			}
+		}
+	}
+
+	return files
+}
+
+/**
+ * Whether the value of a configuration option is a file, e.g. phpstan.neon but not the PSR12 standard
+ */
+func isConfigFile(value string) bool {
+	return strings.Contains(path.Base(value), ".") && !strings.HasPrefix(value, "-")
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/lock"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Keyserver the public keys checking the signatures are received from
+const keyserver = "hkps://keys.openpgp.org"
+
+/**
+ * Check the SHA-256 of the downloaded content when one is expected
+ */
+func verifySha256(operation string, data []byte, expectedSha256 string) error {
+	if expectedSha256 == "" || strings.EqualFold(lock.Hash(string(data)), expectedSha256) {
+		return nil
+	}
+
+	return failure.New(failure.Verification, operation, "the SHA-256 differs from the expected one "+expectedSha256)
+}
+
+/**
+ * Check that the detached signature of the content was made with one of the keys, which are the only ones of a
+ * keyring created for the check. Fails when gpg can't be run rather than skipping the check.
+ */
+func verifySignature(ctx context.Context, operation string, data []byte, signature []byte, keys []string) error {
+	return withKeyring(ctx, operation, keys, func(keyring string) error {
+		dataFile := path.Join(keyring, "data")
+		signatureFile := path.Join(keyring, "data.asc")
+		writeErr := errors.Join(os.WriteFile(dataFile, data, 0600), os.WriteFile(signatureFile, signature, 0600))
+
+		if writeErr != nil {
+			return failure.Wrap(failure.FileSystem, operation, writeErr)
+		}
+
+		return runGpg(ctx, operation, "check the signature", keyring, "--verify", signatureFile, dataFile)
+	})
+}
+
+/**
+ * Call the function with a keyring holding the keys received from the keyserver, ready for gpg and git through
+ * GNUPGHOME
+ */
+func withKeyring(ctx context.Context, operation string, keys []string, use func(keyring string) error) error {
+	keyring, tempErr := os.MkdirTemp("", "phptooling-gpg-")
+
+	if tempErr != nil {
+		return failure.Wrap(failure.FileSystem, operation, tempErr)
+	}
+
+	defer os.RemoveAll(keyring)
+
+	receiveErr := runGpg(ctx, operation, "receive the keys from "+keyserver, keyring, append([]string{"--keyserver", keyserver, "--recv-keys"}, keys...)...)
+
+	if receiveErr != nil {
+		return receiveErr
+	}
+
+	return use(keyring)
+}
+
+func runGpg(ctx context.Context, operation string, step string, keyring string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gpg", append([]string{"--batch", "--no-tty"}, args...)...)
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+keyring)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+
+	if errors.Is(err, exec.ErrNotFound) {
+		return failure.New(failure.Verification, operation, "gpg is needed to check the signature, install it or choose another install method")
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return failure.Wrap(failure.Aborted, operation, ctx.Err())
+	}
+
+	if err != nil {
+		return &failure.Error{Kind: failure.Verification, Operation: operation, Err: errors.New("gpg couldn't " + step + ": " + err.Error()), Output: strings.TrimSpace(output.String())}
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package generator
+
+import (
+	"bytes"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/json"
+	"log/slog"
+	"path"
+	"slices"
+)
+
+// Files of the VS Code workspace, relative to the project
+const (
+	vsCodeSettingsFile   = ".vscode/settings.json"
+	vsCodeTasksFile      = ".vscode/tasks.json"
+	vsCodeExtensionsFile = ".vscode/extensions.json"
+)
+
+// Extensions of VS Code running the tools, the ones configured by getVsCodeSettings
+var vsCodeExtensions = map[tools.Tool]string{
+	tools.PhpStan:    "SanderRonde.phpstan-vscode",
+	tools.PhpCsFixer: "junstyle.php-cs-fixer",
+	tools.PhpCS:      "ValeryanM.vscode-phpsab",
+	tools.PhpMD:      "ecodes.vscode-phpmd",
+	tools.Psalm:      "getpsalm.psalm-vscode-plugin",
+}
+
+// Extensions of the PHP language servers, phpactor is recommended to the projects already using it
+const (
+	intelephenseExtension = "bmewburn.vscode-intelephense-client"
+	phpactorExtension     = "phpactor.vscode-phpactor"
+)
+
+// Variable of VS Code replaced by the directory of the project
+const vsCodeWorkspaceFolder = "${workspaceFolder}"
+
+/**
+ * Point the popular VS Code extensions of the tools at their binaries and configuration files in .vscode/settings.json,
+ * recommend them in .vscode/extensions.json and add a task running each recipe of phptooling to .vscode/tasks.json.
+ * The other settings, recommendations and tasks are kept.
+ */
+func (generator *Generator) GenerateVsCodeConfig() error {
+	settings, err := generator.getVsCodeSettings()
+
+	if err != nil {
+		return err
+	}
+
+	if generator.Config.Environment != config.Local {
+		slog.Warn("VS Code extensions run the tools with the PHP of the host, not the one of " + string(generator.Config.Environment))
+	}
+
+	settingsErr := generator.mergeJsonFile(vsCodeSettingsFile, func(document map[string]interface{}) {
+		for key, value := range settings {
+			document[key] = value
+		}
+	})
+
+	if settingsErr != nil {
+		return settingsErr
+	}
+
+	extensions, err := generator.getVsCodeExtensions()
+
+	if err != nil {
+		return err
+	}
+
+	extensionsErr := generator.mergeJsonFile(vsCodeExtensionsFile, func(document map[string]interface{}) {
+		recommended, _ := document["recommendations"].([]interface{})
+		unwanted, _ := document["unwantedRecommendations"].([]interface{})
+
+		for _, extension := range extensions {
+			if !slices.Contains(recommended, interface{}(extension)) && !slices.Contains(unwanted, interface{}(extension)) {
+				recommended = append(recommended, extension)
+			}
+		}
+
+		document["recommendations"] = recommended
+	})
+
+	if extensionsErr != nil {
+		return extensionsErr
+	}
+
+	tasks, err := generator.getVsCodeTasks()
+
+	if err != nil || len(tasks) == 0 {
+		return err
+	}
+
+	return generator.mergeJsonFile(vsCodeTasksFile, func(document map[string]interface{}) {
+		var labels []string
+
+		for _, task := range tasks {
+			labels = append(labels, task["label"].(string))
+		}
+
+		// The tasks of previous runs are replaced, e.g. when recipes changed
+		kept := []interface{}{}
+		existing, _ := document["tasks"].([]interface{})
+
+		for _, task := range existing {
+			fields, isObject := task.(map[string]interface{})
+			label, _ := fields["label"].(string)
+
+			if !isObject || fields["command"] != "just" || !slices.Contains(labels, label) {
+				kept = append(kept, task)
+			}
+		}
+
+		for _, task := range tasks {
+			kept = append(kept, task)
+		}
+
+		document["version"] = "2.0.0"
+		document["tasks"] = kept
+	})
+}
+
+/**
+ * Return the settings of the extensions of the tools of the project by key: SanderRonde.phpstan-vscode,
+ * junstyle.php-cs-fixer, ValeryanM.vscode-phpsab, ecodes.vscode-phpmd and getpsalm.psalm-vscode-plugin
+ */
+func (generator *Generator) getVsCodeSettings() (map[string]interface{}, error) {
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+
+	for _, tool := range projectTools {
+		definition, _ := tools.Get(tool)
+		hostBinary, binaryErr := generator.getHostBinary(tool, generator.Config.Binary(tool))
+
+		if binaryErr != nil {
+			return nil, binaryErr
+		}
+
+		binary := getWorkspacePath(hostBinary)
+		configFile := ""
+
+		if files := definition.ConfigFiles(string(generator.Config.Framework)); len(files) > 0 {
+			configFile = files[0].Destination
+		}
+
+		switch tool {
+		case tools.PhpStan:
+			settings["phpstan.binPath"] = binary
+			settings["phpstan.configFile"] = configFile
+		case tools.PhpCsFixer:
+			settings["php-cs-fixer.executablePath"] = binary
+			settings["php-cs-fixer.config"] = configFile
+		case tools.PhpCS:
+			beautifier, beautifierErr := generator.getHostBinary(tool, definition.Fix.Binary)
+
+			if beautifierErr != nil {
+				return nil, beautifierErr
+			}
+
+			settings["phpsab.executablePathCS"] = binary
+			settings["phpsab.executablePathCBF"] = getWorkspacePath(beautifier)
+			settings["phpsab.standard"] = getWorkspacePath(configFile)
+		case tools.PhpMD:
+			settings["phpmd.command"] = binary
+			settings["phpmd.rules"] = getWorkspacePath(configFile)
+		case tools.Psalm:
+			settings["psalm.psalmScriptPath"] = binary
+			settings["psalm.configPaths"] = []string{configFile}
+		}
+	}
+
+	return settings, nil
+}
+
+/**
+ * Return the extensions of the tools of the project and of a PHP language server, along with the one of EditorConfig
+ * when the .editorconfig is generated
+ */
+func (generator *Generator) getVsCodeExtensions() ([]string, error) {
+	projectTools, err := generator.getProjectTools()
+
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := []string{intelephenseExtension}
+
+	if generator.usesPhpactor() {
+		extensions = []string{phpactorExtension}
+	}
+
+	for _, tool := range projectTools {
+		if extension, found := vsCodeExtensions[tool]; found {
+			extensions = append(extensions, extension)
+		}
+	}
+
+	if generator.Config.HasOutput(config.EditorConfig) {
+		extensions = append(extensions, "EditorConfig.EditorConfig")
+	}
+
+	return extensions, nil
+}
+
+/**
+ * Return the path of the binary on the host for the editors, relative to the project unless the tool is installed
+ * globally
+ */
+func (generator *Generator) getHostBinary(tool tools.Tool, binary string) (string, error) {
+	if generator.Config.InstallsGlobally(tool) {
+		return generator.ToolBinary(tool, binary)
+	}
+
+	if generator.Config.UsesRequireDev() {
+		return projectBinDirectory + "/" + path.Base(binary), nil
+	}
+
+	return generator.RelativeToolsDirectory() + "/" + binary, nil
+}
+
+/**
+ * Return the path for the VS Code settings, relative paths start from the workspace
+ */
+func getWorkspacePath(file string) string {
+	if path.IsAbs(file) {
+		return file
+	}
+
+	return vsCodeWorkspaceFolder + "/" + file
+}
+
+/**
+ * Return a task running just for each recipe of phptooling, described by the comment of the recipe
+ */
+func (generator *Generator) getVsCodeTasks() ([]map[string]interface{}, error) {
+	recipes, err := generator.getProjectRecipes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []map[string]interface{}
+
+	for _, recipe := range recipes {
+		tasks = append(tasks, map[string]interface{}{
+			"label":          "just " + recipe.name,
+			"detail":         recipe.description,
+			"type":           "shell",
+			"command":        "just",
+			"args":           []string{recipe.name},
+			"problemMatcher": []string{},
+		})
+	}
+
+	return tasks, nil
+}
+
+/**
+ * Update the JSON file of the project with the callback, created when missing. Files the editors read with comments or
+ * trailing commas can't be decoded and are left as they are.
+ */
+func (generator *Generator) mergeJsonFile(relativePath string, update func(document map[string]interface{})) error {
+	document := make(map[string]interface{})
+	existing, readErr := generator.readText(relativePath)
+
+	if readErr == nil {
+		decodeErr := json.Unmarshal([]byte(existing), &document)
+
+		if decodeErr != nil {
+			slog.Warn("Leaving "+relativePath+" as it is, it isn't plain JSON", "error", decodeErr)
+			return nil
+		}
+	}
+
+	update(document)
+
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	// Recipe descriptions and paths with &, < or > are kept readable
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	_ = encoder.Encode(document)
+
+	if content.String() == existing {
+		return nil
+	}
+
+	return generator.WriteProjectFile(relativePath, content.String())
+}
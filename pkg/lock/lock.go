@@ -0,0 +1,207 @@
+package lock
+
+import (
+	"crypto/sha256"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/filesystem"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// File recording what phptooling installed in the project, relative to the project
+const FileName = ".phptooling.lock"
+
+// Lock records what was installed so that later commands don't have to guess it from the filesystem
+type Lock struct {
+	ToolsDirectory string              `json:"tools-directory"`
+	Tools          map[tools.Tool]Tool `json:"tools"`
+	// Generated files by path relative to the project
+	Files map[string]File `json:"files"`
+	// Blocks appended to files of the project, e.g. the justfile recipes of each tool
+	Blocks []Block `json:"blocks"`
+}
+
+type Tool struct {
+	// Installed version by composer package, empty when composer.lock can't be read or for phars
+	Packages map[string]string `json:"packages,omitempty"`
+	// Set when the tool is installed as a phar
+	Phar *Phar `json:"phar,omitempty"`
+	// Set when the tool is installed with PHIVE, which pins it in .phive/phars.xml
+	Phive *Phive `json:"phive,omitempty"`
+	// Set when the tool is installed with composer global require, Packages are then read from the global composer.lock
+	Global bool `json:"global,omitempty"`
+	// Set when the tool is in the require-dev of the project, Packages are then read from its composer.lock
+	RequireDev bool `json:"require-dev,omitempty"`
+	// Version constraint the tool was installed with, empty for the latest version
+	Constraint string `json:"constraint,omitempty"`
+}
+
+type Phive struct {
+	Alias string `json:"alias"`
+	// Installed version, empty when .phive/phars.xml can't be read
+	Version string `json:"version,omitempty"`
+}
+
+// Phar pins the release of a tool installed as a phar
+type Phar struct {
+	// URL of the release, next installs download the same one
+	Url string `json:"url"`
+	// SHA-256 of the phar, checked by next installs
+	Sha256 string `json:"sha256"`
+}
+
+type File struct {
+	// Template the file was rendered from, empty for files generated from code
+	Template string `json:"template,omitempty"`
+	// SHA-256 of the generated content, a different hash means the file was edited since
+	Hash string `json:"hash"`
+}
+
+type Block struct {
+	File string `json:"file"`
+	Name string `json:"name"`
+	// SHA-256 of the content of blocks surrounded by markers, a different one means the block was edited since
+	Hash string `json:"hash,omitempty"`
+}
+
+/**
+ * Read the lock of the project, the second value is false if there is none
+ */
+func Read(projectDirectory string) (*Lock, bool, error) {
+	projectLock := &Lock{Tools: make(map[tools.Tool]Tool), Files: make(map[string]File)}
+	data, readErr := os.ReadFile(filepath.Join(projectDirectory, FileName))
+
+	if readErr != nil {
+		return projectLock, false, nil
+	}
+
+	parseErr := json.Unmarshal(data, projectLock)
+
+	if parseErr != nil {
+		return projectLock, false, failure.Wrap(failure.Configuration, "parse "+FileName, parseErr)
+	}
+
+	if projectLock.Tools == nil {
+		projectLock.Tools = make(map[tools.Tool]Tool)
+	}
+
+	if projectLock.Files == nil {
+		projectLock.Files = make(map[string]File)
+	}
+
+	return projectLock, true, nil
+}
+
+func (projectLock *Lock) Write(projectDirectory string) error {
+	data, _ := json.MarshalIndent(projectLock, "", "    ")
+	writeErr := filesystem.WriteAtomically(filepath.Join(projectDirectory, FileName), append(data, '\n'), 0644)
+
+	return failure.Wrap(failure.FileSystem, "write "+FileName, writeErr)
+}
+
+/**
+ * Return the installed tools in registry order
+ */
+func (projectLock *Lock) InstalledTools() []tools.Tool {
+	var installed []tools.Tool
+
+	for _, tool := range tools.Available {
+		if _, found := projectLock.Tools[tool]; found {
+			installed = append(installed, tool)
+		}
+	}
+
+	return installed
+}
+
+/**
+ * Return the binary of the tool relative to the tools directory, following how it was installed
+ */
+func (tool Tool) Binary(definition tools.Definition) string {
+	if tool.Phar != nil {
+		return definition.PharBinary()
+	}
+
+	if tool.Phive != nil {
+		return definition.PhiveBinary()
+	}
+
+	return definition.Binary
+}
+
+func (projectLock *Lock) AddFile(relativePath string, template string, content string) {
+	projectLock.Files[relativePath] = File{Template: template, Hash: Hash(content)}
+}
+
+/**
+ * Record the block appended to the file, along with the hash of its content when it is surrounded by markers
+ */
+func (projectLock *Lock) AddBlock(file string, name string, content string) {
+	hash := ""
+
+	if content != "" {
+		hash = Hash(content)
+	}
+
+	for i, block := range projectLock.Blocks {
+		if block.File == file && block.Name == name {
+			projectLock.Blocks[i].Hash = hash
+			return
+		}
+	}
+
+	projectLock.Blocks = append(projectLock.Blocks, Block{File: file, Name: name, Hash: hash})
+	sort.Slice(projectLock.Blocks, func(i, j int) bool {
+		if projectLock.Blocks[i].File != projectLock.Blocks[j].File {
+			return projectLock.Blocks[i].File < projectLock.Blocks[j].File
+		}
+
+		return projectLock.Blocks[i].Name < projectLock.Blocks[j].Name
+	})
+}
+
+func Hash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(hash[:])
+}
+
+/**
+ * Return the versions of the packages locked in the composer.lock of the directory, missing packages are omitted
+ */
+func ReadComposerVersions(directory string, packages []string) map[string]string {
+	versions := make(map[string]string)
+	data, readErr := os.ReadFile(filepath.Join(directory, "composer.lock"))
+
+	if readErr != nil {
+		return versions
+	}
+
+	var composerLock struct {
+		Packages    []lockedPackage `json:"packages"`
+		PackagesDev []lockedPackage `json:"packages-dev"`
+	}
+
+	if json.Unmarshal(data, &composerLock) != nil {
+		return versions
+	}
+
+	for _, locked := range append(composerLock.Packages, composerLock.PackagesDev...) {
+		for _, name := range packages {
+			if locked.Name == name {
+				versions[name] = locked.Version
+			}
+		}
+	}
+
+	return versions
+}
+
+type lockedPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
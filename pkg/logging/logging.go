@@ -0,0 +1,232 @@
+package logging
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Attribute holding the output of a command, only written to the log file as it is already streamed to the console
+const OutputKey = "output"
+
+// Lowest level of the messages shown on the console, Info unless Setup changed it
+var ConsoleLevel = new(slog.LevelVar)
+
+var logFile string
+
+// Receives the events in JSON output, nil otherwise
+var events *slog.Logger
+
+// Where messages and command outputs meant for people go, stdout unless it is reserved to the events
+var console = os.Stdout
+
+type Options struct {
+	// Show debug messages and the error output of commands
+	Verbose bool
+	// Only show warnings and errors, commands output is hidden
+	Quiet bool
+	// File receiving every message as JSON lines whatever the console level, appended to when it exists
+	File string
+	// Write the events of the run to stdout as JSON lines for programs driving phptooling, the messages and the
+	// output of commands then go to stderr
+	Json bool
+}
+
+/**
+ * Install the default logger following the options, the returned function closes the log file
+ */
+func Setup(options Options) (func() error, error) {
+	ConsoleLevel.Set(slog.LevelInfo)
+	logFile = ""
+	events = nil
+	console = os.Stdout
+
+	if options.Json {
+		console = os.Stderr
+		events = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: renameEventAttr}))
+	}
+
+	if options.Verbose {
+		ConsoleLevel.Set(slog.LevelDebug)
+	} else if options.Quiet {
+		ConsoleLevel.Set(slog.LevelWarn)
+	}
+
+	handlers := []slog.Handler{&consoleHandler{stdout: console, stderr: os.Stderr}}
+	closeFile := func() error { return nil }
+
+	if options.File != "" {
+		file, fileErr := os.OpenFile(options.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+		if fileErr != nil {
+			return closeFile, failure.Wrap(failure.FileSystem, "open the log file", fileErr)
+		}
+
+		handlers = append(handlers, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		closeFile = file.Close
+		logFile = options.File
+	}
+
+	slog.SetDefault(slog.New(&multiHandler{handlers: handlers}))
+
+	return closeFile, nil
+}
+
+/**
+ * Return where messages and command outputs meant for people are written
+ */
+func Console() *os.File {
+	return console
+}
+
+/**
+ * Write an event of the run (e.g. a command run or a file written) with its attributes when the events are output as
+ * JSON, e.g. Event("file", "path", "phpstan.neon", "action", "written")
+ */
+func Event(name string, attrs ...any) {
+	if events != nil {
+		events.Info(name, attrs...)
+	}
+}
+
+/**
+ * Name the message of the event records "event" and drop their level, which is always Info
+ */
+func renameEventAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+
+	switch attr.Key {
+	case slog.MessageKey:
+		attr.Key = "event"
+	case slog.LevelKey:
+		return slog.Attr{}
+	}
+
+	return attr
+}
+
+/**
+ * Whether messages of the level are shown on the console
+ */
+func OnConsole(level slog.Level) bool {
+	return level >= ConsoleLevel.Level()
+}
+
+/**
+ * Whether messages are also written to a log file, the output of commands is then captured to be logged
+ */
+func ToFile() bool {
+	return logFile != ""
+}
+
+// consoleHandler writes the message followed by its attributes, warnings and errors going to stderr
+type consoleHandler struct {
+	stdout io.Writer
+	stderr io.Writer
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (handler *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return OnConsole(level)
+}
+
+func (handler *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var line strings.Builder
+	writer := handler.stdout
+
+	switch {
+	case record.Level >= slog.LevelError:
+		line.WriteString("Error: ")
+		writer = handler.stderr
+	case record.Level >= slog.LevelWarn:
+		line.WriteString("Warning: ")
+		writer = handler.stderr
+	}
+
+	line.WriteString(record.Message)
+
+	appendAttr := func(attr slog.Attr) bool {
+		if attr.Key == OutputKey || attr.Equal(slog.Attr{}) {
+			return true
+		}
+
+		// Values are written as is, the log file keeps them structured
+		key := strings.Join(append(append([]string{}, handler.groups...), attr.Key), ".")
+		line.WriteString(" " + key + "=" + attr.Value.Resolve().String())
+
+		return true
+	}
+
+	for _, attr := range handler.attrs {
+		appendAttr(attr)
+	}
+
+	record.Attrs(appendAttr)
+
+	return printAbove(writer, line.String())
+}
+
+func (handler *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &consoleHandler{stdout: handler.stdout, stderr: handler.stderr, attrs: append(append([]slog.Attr{}, handler.attrs...), attrs...), groups: handler.groups}
+}
+
+func (handler *consoleHandler) WithGroup(name string) slog.Handler {
+	return &consoleHandler{stdout: handler.stdout, stderr: handler.stderr, attrs: handler.attrs, groups: append(append([]string{}, handler.groups...), name)}
+}
+
+// multiHandler sends each record to every handler enabled for its level
+type multiHandler struct {
+	handlers []slog.Handler
+}
+
+func (handler *multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, child := range handler.handlers {
+		if child.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (handler *multiHandler) Handle(ctx context.Context, record slog.Record) error {
+	for _, child := range handler.handlers {
+		if !child.Enabled(ctx, record.Level) {
+			continue
+		}
+
+		err := child.Handle(ctx, record.Clone())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (handler *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	children := make([]slog.Handler, len(handler.handlers))
+
+	for i, child := range handler.handlers {
+		children[i] = child.WithAttrs(attrs)
+	}
+
+	return &multiHandler{handlers: children}
+}
+
+func (handler *multiHandler) WithGroup(name string) slog.Handler {
+	children := make([]slog.Handler, len(handler.handlers))
+
+	for i, child := range handler.handlers {
+		children[i] = child.WithGroup(name)
+	}
+
+	return &multiHandler{handlers: children}
+}
@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delay between two renderings of the status line, e.g. the frames of a spinner
+const statusInterval = 100 * time.Millisecond
+
+// statusLine is redrawn at the bottom of the console while commands run, messages are written above it. It may span
+// several lines.
+type statusLine struct {
+	sync.Mutex
+	render func(frame int) string
+	frame  int
+	// Number of lines currently drawn
+	height int
+	paused bool
+	stop   chan struct{}
+	done   chan struct{}
+	writer io.Writer
+}
+
+var status statusLine
+
+/**
+ * Show the lines rendered again at each frame below the console messages, the output of the commands is hidden meanwhile
+ * to be shown on failure. Nothing is shown when the console isn't a terminal (or a dumb one) or shows debug messages,
+ * which are meant to follow the whole output.
+ */
+func StartStatus(render func(frame int) string) {
+	status.Lock()
+	defer status.Unlock()
+
+	// Dumb terminals can't move the cursor up to redraw the lines
+	if status.render != nil || ConsoleLevel.Level() != slog.LevelInfo || !isTerminal(console) || os.Getenv("TERM") == "dumb" {
+		return
+	}
+
+	status.render = render
+	status.frame = 0
+	status.height = 0
+	status.paused = false
+	status.writer = console
+	status.stop = make(chan struct{})
+	status.done = make(chan struct{})
+	status.draw()
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		defer close(done)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				status.Lock()
+				status.frame++
+				status.draw()
+				status.Unlock()
+			}
+		}
+	}(status.stop, status.done)
+}
+
+/**
+ * Remove the status line, the output of the commands is shown again
+ */
+func StopStatus() {
+	status.Lock()
+
+	if status.render == nil {
+		status.Unlock()
+		return
+	}
+
+	close(status.stop)
+	done := status.done
+	status.clear()
+	status.render = nil
+	status.Unlock()
+
+	<-done
+}
+
+/**
+ * Hide the status line while the user answers a question, the returned function shows it again
+ */
+func PauseStatus() func() {
+	status.Lock()
+	defer status.Unlock()
+
+	if status.render == nil || status.paused {
+		return func() {}
+	}
+
+	status.clear()
+	status.paused = true
+
+	return func() {
+		status.Lock()
+		defer status.Unlock()
+
+		status.paused = false
+		status.draw()
+	}
+}
+
+/**
+ * Whether the status line is shown, the output of commands is then captured instead of streamed
+ */
+func StatusShown() bool {
+	status.Lock()
+	defer status.Unlock()
+
+	return status.render != nil
+}
+
+/**
+ * Write a line to the console above the status line
+ */
+func Println(line string) {
+	printAbove(console, line)
+}
+
+func printAbove(writer io.Writer, line string) error {
+	status.Lock()
+	defer status.Unlock()
+
+	status.clear()
+	_, err := fmt.Fprintln(writer, line)
+	status.draw()
+
+	return err
+}
+
+// Called with the lock held, like clear
+func (line *statusLine) draw() {
+	if line.render == nil || line.paused {
+		return
+	}
+
+	line.clear()
+	rendered := line.render(line.frame)
+	fmt.Fprint(line.writer, rendered)
+	line.height = strings.Count(rendered, "\n") + 1
+}
+
+/**
+ * Erase the lines drawn, the cursor goes back to the start of the first one
+ */
+func (line *statusLine) clear() {
+	if line.render == nil || line.paused || line.height == 0 {
+		return
+	}
+
+	if line.height > 1 {
+		fmt.Fprintf(line.writer, "\033[%dA", line.height-1)
+	}
+
+	fmt.Fprint(line.writer, "\r\033[J")
+	line.height = 0
+}
+
+/**
+ * Tell whether questions can be asked, false when the standard input or output isn't a terminal (CI, pipes) and in
+ * quiet mode, which is meant for scripts
+ */
+func IsInteractive() bool {
+	return ConsoleLevel.Level() <= slog.LevelInfo && isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,283 @@
+package pipeline
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"time"
+)
+
+// Status of a step, reported each time it changes
+type Status string
+
+const (
+	// Waiting for the steps it depends on, reported for every step when the pipeline starts
+	Pending   Status = "pending"
+	Running   Status = "running"
+	Retrying  Status = "retrying"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	// Not run because a step failed or the pipeline was interrupted
+	Skipped Status = "skipped"
+)
+
+// Step is a unit of work run once the steps it depends on succeeded
+type Step struct {
+	Name      string
+	DependsOn []string
+	// Number of additional attempts after a failure, e.g. for commands downloading packages
+	Retries int
+	// Concurrent steps don't touch shared state and may run alongside any step, the others run one at a time
+	Concurrent bool
+	// Expected duration of the step, e.g. measured by the previous runs, zero when unknown
+	Estimate time.Duration
+	Run      func() error
+}
+
+// Event tells that a step changed of status
+type Event struct {
+	Step   string
+	Status Status
+	// Attempt starting at 1, greater when the step is retried
+	Attempt  int
+	Duration time.Duration
+	// Estimate of the step
+	Estimate time.Duration
+	Err      error
+}
+
+// Pipeline runs steps following their dependencies, in declaration order when several are ready
+type Pipeline struct {
+	Steps []Step
+	// Maximum number of steps running at the same time, steps run one by one below 2
+	Parallelism int
+	// Called from the goroutine calling Run, before the step runs for Running events, may be nil
+	Report func(event Event)
+	// Waiting time before the first retry, doubled for each following one (one second when zero)
+	RetryDelay time.Duration
+}
+
+// message is sent by running steps, either an event to report or the end of the step with its error
+type message struct {
+	index    int
+	event    Event
+	finished bool
+	err      error
+}
+
+/**
+ * Run the steps and return the error of the first failed one, the steps not started yet are then skipped while the
+ * running ones are waited for. An interruption of the context stops the pipeline with an Aborted error.
+ */
+func (pipeline Pipeline) Run(ctx context.Context) error {
+	validateErr := pipeline.validate()
+
+	if validateErr != nil {
+		return validateErr
+	}
+
+	// Every step is reported first, e.g. so that the steps are all listed from the start
+	for _, step := range pipeline.Steps {
+		pipeline.report(Event{Step: step.Name, Status: Pending})
+	}
+
+	parallelism := max(pipeline.Parallelism, 1)
+	statuses := make([]Status, len(pipeline.Steps))
+	messages := make(chan message)
+	running := 0
+	serialRunning := false
+	var firstErr error
+
+	for {
+		if firstErr == nil && ctx.Err() != nil {
+			firstErr = failure.Wrap(failure.Aborted, "run the steps", ctx.Err())
+		}
+
+		for i, step := range pipeline.Steps {
+			if firstErr != nil || running >= parallelism {
+				break
+			}
+
+			if statuses[i] != "" || !pipeline.isReady(i, statuses) || (!step.Concurrent && serialRunning) {
+				continue
+			}
+
+			statuses[i] = Running
+			running++
+
+			if !step.Concurrent {
+				serialRunning = true
+			}
+
+			// Reported before the step runs, e.g. so that its output is hidden behind a spinner from the start
+			pipeline.report(Event{Step: step.Name, Status: Running, Attempt: 1})
+
+			go func(index int) {
+				err := pipeline.runStep(ctx, index, messages)
+				messages <- message{index: index, finished: true, err: err}
+			}(i)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		next := <-messages
+
+		if !next.finished {
+			pipeline.report(next.event)
+			continue
+		}
+
+		running--
+
+		if !pipeline.Steps[next.index].Concurrent {
+			serialRunning = false
+		}
+
+		if next.err != nil {
+			statuses[next.index] = Failed
+
+			if firstErr == nil {
+				firstErr = next.err
+			}
+		} else {
+			statuses[next.index] = Succeeded
+		}
+	}
+
+	for i, step := range pipeline.Steps {
+		if statuses[i] == "" {
+			pipeline.report(Event{Step: step.Name, Status: Skipped})
+		}
+	}
+
+	return firstErr
+}
+
+/**
+ * Run the step with its retries, events are sent to the channel to be reported from the goroutine of Run
+ */
+func (pipeline Pipeline) runStep(ctx context.Context, index int, messages chan<- message) error {
+	step := pipeline.Steps[index]
+	delay := pipeline.RetryDelay
+
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			messages <- message{index: index, event: Event{Step: step.Name, Status: Running, Attempt: attempt}}
+		}
+
+		start := time.Now()
+		err := step.Run()
+		event := Event{Step: step.Name, Status: Succeeded, Attempt: attempt, Duration: time.Since(start), Err: err}
+
+		if err == nil {
+			messages <- message{index: index, event: event}
+			return nil
+		}
+
+		// Interruptions and invalid answers won't go away by trying again
+		if attempt > step.Retries || ctx.Err() != nil || !isRetryable(err) {
+			event.Status = Failed
+			messages <- message{index: index, event: event}
+			return err
+		}
+
+		event.Status = Retrying
+		messages <- message{index: index, event: event}
+
+		select {
+		case <-ctx.Done():
+			return failure.Wrap(failure.Aborted, step.Name, ctx.Err())
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+func isRetryable(err error) bool {
+	kind := failure.KindOf(err)
+
+	return kind == failure.Composer || kind == failure.Command
+}
+
+func (pipeline Pipeline) isReady(index int, statuses []Status) bool {
+	for _, dependency := range pipeline.Steps[index].DependsOn {
+		if statuses[pipeline.indexOf(dependency)] != Succeeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (pipeline Pipeline) indexOf(name string) int {
+	for i, step := range pipeline.Steps {
+		if step.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/**
+ * Check that step names are unique and that dependencies exist without forming a cycle
+ */
+func (pipeline Pipeline) validate() error {
+	visited := make([]int, len(pipeline.Steps))
+
+	var visit func(index int) error
+	visit = func(index int) error {
+		switch visited[index] {
+		case 1:
+			return failure.New(failure.Configuration, "run the steps", "the dependencies of "+pipeline.Steps[index].Name+" form a cycle")
+		case 2:
+			return nil
+		}
+
+		visited[index] = 1
+
+		for _, dependency := range pipeline.Steps[index].DependsOn {
+			dependencyIndex := pipeline.indexOf(dependency)
+
+			if dependencyIndex < 0 {
+				return failure.New(failure.Configuration, "run the steps", pipeline.Steps[index].Name+" depends on the unknown step "+dependency)
+			}
+
+			err := visit(dependencyIndex)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		visited[index] = 2
+
+		return nil
+	}
+
+	for i, step := range pipeline.Steps {
+		if pipeline.indexOf(step.Name) != i {
+			return failure.New(failure.Configuration, "run the steps", "several steps are named "+step.Name)
+		}
+
+		err := visit(i)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pipeline Pipeline) report(event Event) {
+	if pipeline.Report != nil {
+		event.Estimate = pipeline.Steps[pipeline.indexOf(event.Step)].Estimate
+		pipeline.Report(event)
+	}
+}
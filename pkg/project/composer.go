@@ -0,0 +1,181 @@
+package project
+
+import (
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+type ComposerJson struct {
+	Require    map[string]string `json:"require"`
+	RequireDev map[string]string `json:"require-dev"`
+	Config     struct {
+		Platform map[string]string `json:"platform"`
+	} `json:"config"`
+	Extra struct {
+		BinPlugin struct {
+			TargetDirectory string `json:"target-directory"`
+		} `json:"bamarni-bin"`
+	} `json:"extra"`
+}
+
+// Package of the plugin installing tools in namespaces of the project, see https://github.com/bamarni/composer-bin-plugin
+const BinPluginPackage = "bamarni/composer-bin-plugin"
+
+// Directory of the namespaces when the plugin doesn't configure another one
+const DefaultBinPluginDirectory = "vendor-bin"
+
+/**
+ * Read the composer.json of the project, the second value is false if there is none
+ */
+func ReadComposerJson(projectDirectory string) (ComposerJson, bool, error) {
+	var composerJson ComposerJson
+
+	file, fileErr := os.ReadFile(filepath.Join(projectDirectory, "composer.json"))
+
+	if fileErr != nil {
+		return composerJson, false, nil
+	}
+
+	parseErr := json.Unmarshal(file, &composerJson)
+
+	if parseErr != nil {
+		return composerJson, false, failure.Wrap(failure.Environment, "parse composer.json", parseErr)
+	}
+
+	return composerJson, true, nil
+}
+
+/**
+ * Whether the project requires the package, as a dependency or a dev one
+ */
+func (composerJson ComposerJson) Requires(name string) bool {
+	_, dependency := composerJson.Require[name]
+	_, devDependency := composerJson.RequireDev[name]
+
+	return dependency || devDependency
+}
+
+/**
+ * Return the directory of the namespaces of bamarni/composer-bin-plugin when the project requires it, the second value
+ * is false otherwise
+ */
+func DetectBinPlugin(projectDirectory string) (string, bool, error) {
+	composerJson, found, err := ReadComposerJson(projectDirectory)
+
+	if !found || !composerJson.Requires(BinPluginPackage) {
+		return "", false, err
+	}
+
+	if composerJson.Extra.BinPlugin.TargetDirectory != "" {
+		return composerJson.Extra.BinPlugin.TargetDirectory, true, nil
+	}
+
+	return DefaultBinPluginDirectory, true, nil
+}
+
+/**
+ * Return the version from the platform config of composer.json, or the lowest version allowed by its php requirement
+ */
+func DetectPhpVersion(projectDirectory string) (string, error) {
+	composerJson, found, err := ReadComposerJson(projectDirectory)
+
+	if !found {
+		return "", err
+	}
+
+	if platformVersion := MinimumPhpVersion(composerJson.Config.Platform["php"]); platformVersion != "" {
+		return platformVersion, nil
+	}
+
+	return MinimumPhpVersion(composerJson.Require["php"]), nil
+}
+
+/**
+ * Return the lowest major.minor version found in a composer constraint (e.g. "7.4" for "^8.1 || ^7.4")
+ */
+func MinimumPhpVersion(constraint string) string {
+	minimum := ""
+	minimumId := 0
+
+	for _, match := range regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.[0-9*x]+)*`).FindAllStringSubmatch(constraint, -1) {
+		major, _ := strconv.Atoi(match[1])
+		minor, _ := strconv.Atoi(match[2])
+
+		if minimum == "" || major*100+minor < minimumId {
+			minimum = strconv.Itoa(major) + "." + strconv.Itoa(minor)
+			minimumId = major*100 + minor
+		}
+	}
+
+	return minimum
+}
+
+/**
+ * Return the PHP_VERSION_ID like number of the version (e.g. 80200 for 8.2), as expected by PHP_CodeSniffer
+ */
+func PhpVersionId(phpVersion string) string {
+	var major, minor int
+
+	_, err := fmt.Sscanf(phpVersion, "%d.%d", &major, &minor)
+
+	if err != nil {
+		return ""
+	}
+
+	return strconv.Itoa(major*10000 + minor*100)
+}
+
+/**
+ * Guess the framework from the dependencies of composer.json, along with the analysed paths following its
+ * conventions (nil to keep the default ones)
+ */
+func DetectFramework(projectDirectory string) (config.Framework, []string, error) {
+	composerJson, found, err := ReadComposerJson(projectDirectory)
+
+	if !found {
+		return config.Symfony, nil, err
+	}
+
+	has := func(packages ...string) bool {
+		for _, name := range packages {
+			if _, ok := composerJson.Require[name]; ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch {
+	case has("laravel/framework"):
+		return config.Laravel, []string{"app", "tests"}, nil
+	case has("drupal/core", "drupal/core-recommended"):
+		return config.Drupal, []string{"web/modules/custom", "web/themes/custom"}, nil
+	case has("johnpbloch/wordpress", "roots/wordpress", "roots/wordpress-no-content"):
+		return config.WordPress, nil, nil
+	case has("symfony/framework-bundle"):
+		return config.Symfony, nil, nil
+	}
+
+	return config.NoFramework, nil, nil
+}
+
+/**
+ * Return the test framework required by the project, Pest first as it requires PHPUnit, empty when it has none
+ */
+func DetectTestFramework(composerJson ComposerJson) config.TestFramework {
+	switch {
+	case composerJson.Requires("pestphp/pest"):
+		return config.PestTests
+	case composerJson.Requires("phpunit/phpunit"):
+		return config.PhpUnitTests
+	}
+
+	return ""
+}
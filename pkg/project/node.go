@@ -0,0 +1,46 @@
+package project
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type NodePackage struct {
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	LintStaged      json.RawMessage   `json:"lint-staged"`
+}
+
+func (nodePackage NodePackage) HasDependency(name string) bool {
+	_, dependency := nodePackage.Dependencies[name]
+	_, devDependency := nodePackage.DevDependencies[name]
+
+	return dependency || devDependency
+}
+
+func (nodePackage NodePackage) UsesHusky() bool {
+	return nodePackage.HasDependency("husky")
+}
+
+func (nodePackage NodePackage) UsesLintStaged() bool {
+	return nodePackage.HasDependency("lint-staged")
+}
+
+/**
+ * Read the package.json of the project, an empty package is returned if there is none
+ */
+func ReadNodePackage(projectDirectory string) (NodePackage, error) {
+	var nodePackage NodePackage
+
+	file, fileErr := os.ReadFile(filepath.Join(projectDirectory, "package.json"))
+
+	if fileErr != nil {
+		return nodePackage, nil
+	}
+
+	parseErr := json.Unmarshal(file, &nodePackage)
+
+	return nodePackage, failure.Wrap(failure.Environment, "parse package.json", parseErr)
+}
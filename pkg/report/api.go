@@ -0,0 +1,62 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/**
+ * Send the body encoded as JSON to the API of the platform (GitHub or GitLab) and decode its answer into the result,
+ * when given
+ */
+func callApi(ctx context.Context, operation string, platform string, method string, url string, headers map[string]string, body any, result any) error {
+	var content io.Reader
+
+	if body != nil {
+		data, _ := json.Marshal(body)
+		content = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	request, requestErr := http.NewRequestWithContext(ctx, method, url, content)
+
+	if requestErr != nil {
+		return failure.Wrap(failure.Configuration, operation, requestErr)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, sendErr := http.DefaultClient.Do(request)
+
+	if sendErr != nil {
+		return failure.Wrap(failure.Environment, operation, sendErr)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+
+		return failure.New(failure.Environment, operation, platform+" answered "+response.Status+": "+strings.TrimSpace(string(message)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	decodeErr := json.NewDecoder(response.Body).Decode(result)
+
+	return failure.Classify(failure.Environment, operation, decodeErr)
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/tools"
+	"encoding/json"
+	"strconv"
+)
+
+// Badge is read by the endpoint badges of shields.io, see https://shields.io/badges/endpoint-badge
+type Badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// Name of the badge counting the issues of all the tools, the badge of each tool is named after its id
+const IssuesBadge = "issues"
+
+/**
+ * Return the badges of the results by name: the number of issues of all the tools, then of each tool. Errors turn the
+ * badges red, other issues yellow.
+ */
+func Badges(results []Result) map[string]Badge {
+	var issues []Issue
+	badges := make(map[string]Badge)
+
+	for _, result := range results {
+		badge := getIssuesBadge(tools.Name(result.Tool), result.Issues)
+
+		if len(result.Issues) == 0 {
+			badge.Message = "passing"
+		}
+
+		badges[string(result.Tool)] = badge
+		issues = append(issues, result.Issues...)
+	}
+
+	badges[IssuesBadge] = getIssuesBadge("issues", issues)
+
+	return badges
+}
+
+/**
+ * Return the badge of the rule level of PHPStan (0 to 9 or max), green from level 6
+ */
+func PhpStanLevelBadge(level string) Badge {
+	badge := Badge{SchemaVersion: 1, Label: "PHPStan", Message: "level " + level, Color: "brightgreen"}
+	number, err := strconv.Atoi(level)
+
+	switch {
+	case err != nil:
+		// max
+	case number >= 6:
+		badge.Color = "green"
+	case number >= 3:
+		badge.Color = "yellow"
+	default:
+		badge.Color = "orange"
+	}
+
+	return badge
+}
+
+func getIssuesBadge(label string, issues []Issue) Badge {
+	badge := Badge{SchemaVersion: 1, Label: label, Message: strconv.Itoa(len(issues)), Color: "brightgreen"}
+
+	for _, issue := range issues {
+		if issue.Severity == Error {
+			badge.Color = "red"
+			break
+		}
+
+		badge.Color = "yellow"
+	}
+
+	return badge
+}
+
+/**
+ * Return the JSON file served to shields.io
+ */
+func (badge Badge) JSON() []byte {
+	data, _ := json.Marshal(badge)
+
+	return append(data, '\n')
+}
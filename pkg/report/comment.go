@@ -0,0 +1,157 @@
+package report
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// First line of the comment, hidden once rendered, through which it is found and updated by the next runs
+const commentMarker = "<!-- phptooling-report -->"
+
+// Comments listed by request when looking for the previous one
+const commentsByPage = 100
+
+// comment is a comment of a pull request on GitHub or a note of a merge request on GitLab
+type comment struct {
+	Id   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+var pullRequestRefPattern = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+/**
+ * Post the summary as a comment of the pull request (GitHub Actions) or merge request (GitLab CI) being checked, the
+ * comment posted by a previous run is updated instead so that there is only one
+ */
+func PostComment(ctx context.Context, summary string) error {
+	body := commentMarker + "\n" + summary
+
+	if os.Getenv("GITHUB_ACTIONS") != "" {
+		return commentOnGitHub(ctx, body)
+	}
+
+	if os.Getenv("GITLAB_CI") != "" {
+		return commentOnGitLab(ctx, body)
+	}
+
+	return failure.New(failure.Configuration, "comment the report", "the comment is posted from GitHub Actions or GitLab CI, neither was detected")
+}
+
+/**
+ * Comment the pull request of the workflow, GITHUB_TOKEN needs the pull-requests: write permission
+ */
+func commentOnGitHub(ctx context.Context, body string) error {
+	operation := "comment the report on the pull request"
+	token := os.Getenv("GITHUB_TOKEN")
+
+	if token == "" {
+		return failure.New(failure.Configuration, operation, "GITHUB_TOKEN is not set")
+	}
+
+	number := getPullRequestNumber()
+
+	if number == "" {
+		return failure.New(failure.Configuration, operation, "the workflow is not running for a pull request")
+	}
+
+	repository := getGitHubApi() + "/repos/" + os.Getenv("GITHUB_REPOSITORY")
+	headers := getGitHubHeaders(token)
+	previous, found, listErr := findComment(ctx, operation, "GitHub", repository+"/issues/"+number+"/comments", headers)
+
+	if listErr != nil {
+		return listErr
+	}
+
+	if found {
+		return callApi(ctx, operation, "GitHub", http.MethodPatch, repository+"/issues/comments/"+strconv.FormatInt(previous, 10), headers, comment{Body: body}, nil)
+	}
+
+	return callApi(ctx, operation, "GitHub", http.MethodPost, repository+"/issues/"+number+"/comments", headers, comment{Body: body}, nil)
+}
+
+/**
+ * Return the number of the pull request the workflow runs for, read from its event or else from its ref, empty for
+ * the other events
+ */
+func getPullRequestNumber() string {
+	if eventFile := os.Getenv("GITHUB_EVENT_PATH"); eventFile != "" {
+		var event struct {
+			PullRequest struct {
+				Number int `json:"number"`
+			} `json:"pull_request"`
+		}
+
+		if content, readErr := os.ReadFile(eventFile); readErr == nil && json.Unmarshal(content, &event) == nil && event.PullRequest.Number > 0 {
+			return strconv.Itoa(event.PullRequest.Number)
+		}
+	}
+
+	if match := pullRequestRefPattern.FindStringSubmatch(os.Getenv("GITHUB_REF")); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
+
+/**
+ * Comment the merge request of the pipeline, GITLAB_TOKEN must be an access token with the api scope as the job token
+ * can't write notes
+ */
+func commentOnGitLab(ctx context.Context, body string) error {
+	operation := "comment the report on the merge request"
+	token := os.Getenv("GITLAB_TOKEN")
+
+	if token == "" {
+		return failure.New(failure.Configuration, operation, "GITLAB_TOKEN is not set, it must be an access token with the api scope")
+	}
+
+	iid := os.Getenv("CI_MERGE_REQUEST_IID")
+
+	if iid == "" {
+		return failure.New(failure.Configuration, operation, "the pipeline is not running for a merge request")
+	}
+
+	notes := strings.TrimSuffix(os.Getenv("CI_API_V4_URL"), "/") + "/projects/" + os.Getenv("CI_PROJECT_ID") + "/merge_requests/" + iid + "/notes"
+	headers := map[string]string{"PRIVATE-TOKEN": token}
+	previous, found, listErr := findComment(ctx, operation, "GitLab", notes, headers)
+
+	if listErr != nil {
+		return listErr
+	}
+
+	if found {
+		return callApi(ctx, operation, "GitLab", http.MethodPut, notes+"/"+strconv.FormatInt(previous, 10), headers, comment{Body: body}, nil)
+	}
+
+	return callApi(ctx, operation, "GitLab", http.MethodPost, notes, headers, comment{Body: body}, nil)
+}
+
+/**
+ * Return the id of the comment posted by a previous run, going through the pages of the comments
+ */
+func findComment(ctx context.Context, operation string, platform string, url string, headers map[string]string) (int64, bool, error) {
+	for page := 1; ; page++ {
+		var comments []comment
+		listErr := callApi(ctx, operation, platform, http.MethodGet, url+"?per_page="+strconv.Itoa(commentsByPage)+"&page="+strconv.Itoa(page), headers, nil, &comments)
+
+		if listErr != nil {
+			return 0, false, listErr
+		}
+
+		for _, previous := range comments {
+			if strings.HasPrefix(previous.Body, commentMarker) {
+				return previous.Id, true, nil
+			}
+		}
+
+		if len(comments) < commentsByPage {
+			return 0, false, nil
+		}
+	}
+}
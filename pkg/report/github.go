@@ -0,0 +1,60 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// API used when GITHUB_API_URL isn't set, i.e. outside GitHub Actions
+const gitHubApi = "https://api.github.com"
+
+/**
+ * Upload the SARIF log to GitHub code scanning for the commit being checked, the repository, commit, ref and token
+ * are read from the variables of GitHub Actions (GITHUB_TOKEN needs the security-events: write permission)
+ */
+func UploadToCodeScanning(ctx context.Context, sarif []byte) error {
+	operation := "upload the report to GitHub code scanning"
+	variables := map[string]string{}
+
+	for _, name := range []string{"GITHUB_TOKEN", "GITHUB_REPOSITORY", "GITHUB_SHA", "GITHUB_REF"} {
+		if variables[name] = os.Getenv(name); variables[name] == "" {
+			return failure.New(failure.Configuration, operation, name+" is not set, the upload is meant to run in GitHub Actions")
+		}
+	}
+
+	// The API expects the log compressed then encoded
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(sarif)
+	writer.Close()
+
+	body := map[string]string{
+		"commit_sha": variables["GITHUB_SHA"],
+		"ref":        variables["GITHUB_REF"],
+		"sarif":      base64.StdEncoding.EncodeToString(compressed.Bytes()),
+		"tool_name":  "phptooling",
+	}
+
+	return callApi(ctx, operation, "GitHub", http.MethodPost, getGitHubApi()+"/repos/"+variables["GITHUB_REPOSITORY"]+"/code-scanning/sarifs", getGitHubHeaders(variables["GITHUB_TOKEN"]), body, nil)
+}
+
+/**
+ * Return the API of GitHub, the one of the GitHub Enterprise server running the workflow when set
+ */
+func getGitHubApi() string {
+	if url := os.Getenv("GITHUB_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	return gitHubApi
+}
+
+func getGitHubHeaders(token string) map[string]string {
+	return map[string]string{"Accept": "application/vnd.github+json", "Authorization": "Bearer " + token}
+}
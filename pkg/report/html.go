@@ -0,0 +1,179 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/tools"
+	"html/template"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities from the most to the least important, as listed by the page
+var severities = []Severity{Error, Warning, Note}
+
+// Label of the issues of the whole project in the table by directory
+const projectDirectoryLabel = "(project)"
+
+// severityCount is a number of issues by severity
+type severityCount struct {
+	Name   string
+	Counts map[Severity]int
+	Total  int
+}
+
+type htmlTool struct {
+	severityCount
+	Id     string
+	Issues []Issue
+	Output string
+}
+
+var htmlPage = template.Must(template.New("report").Funcs(template.FuncMap{"location": getLocation}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Quality report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 72rem; padding: 0 1rem; color: #1f2328; }
+h1 { margin-bottom: 0; }
+.generated { color: #59636e; margin-top: .25rem; }
+table { border-collapse: collapse; width: 100%; margin: 1rem 0 2rem; }
+th, td { border-bottom: 1px solid #d1d9e0; padding: .4rem .6rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.number, th.number { text-align: right; }
+.error { color: #cf222e; }
+.warning { color: #9a6700; }
+.note { color: #0969da; }
+.totals { display: flex; gap: 1rem; }
+.totals div { border: 1px solid #d1d9e0; border-radius: 6px; padding: .75rem 1.25rem; }
+.totals strong { display: block; font-size: 2rem; }
+pre { background: #f6f8fa; padding: 1rem; overflow: auto; max-height: 30rem; }
+code { font-size: .9em; }
+</style>
+</head>
+<body>
+<h1>Quality report</h1>
+<p class="generated">Generated by phptooling on {{ .Generated }}</p>
+
+<div class="totals">
+{{- range .Severities }}
+<div class="{{ . }}"><strong>{{ index $.Totals.Counts . }}</strong>{{ . }}s</div>
+{{- end }}
+</div>
+
+<h2>By tool</h2>
+<table>
+<tr><th>Tool</th>{{ range .Severities }}<th class="number">{{ . }}s</th>{{ end }}<th class="number">Total</th><th></th></tr>
+{{- range .Tools }}
+<tr><td><a href="#{{ .Id }}">{{ .Name }}</a></td>{{ $counts := .Counts }}{{ range $.Severities }}<td class="number">{{ index $counts . }}</td>{{ end }}<td class="number">{{ .Total }}</td><td><a href="#{{ .Id }}-output">raw output</a></td></tr>
+{{- end }}
+</table>
+
+<h2>By directory</h2>
+<table>
+<tr><th>Directory</th>{{ range .Severities }}<th class="number">{{ . }}s</th>{{ end }}<th class="number">Total</th></tr>
+{{- range .Directories }}
+<tr><td><code>{{ .Name }}</code></td>{{ $counts := .Counts }}{{ range $.Severities }}<td class="number">{{ index $counts . }}</td>{{ end }}<td class="number">{{ .Total }}</td></tr>
+{{- end }}
+</table>
+
+{{- range .Tools }}
+
+<h2 id="{{ .Id }}">{{ .Name }}</h2>
+{{- if .Issues }}
+<table>
+<tr><th>Location</th><th>Severity</th><th>Rule</th><th>Message</th></tr>
+{{- range .Issues }}
+<tr><td><code>{{ location . }}</code></td><td class="{{ .Severity }}">{{ .Severity }}</td><td><code>{{ .Rule }}</code></td><td>{{ .Message }}</td></tr>
+{{- end }}
+</table>
+{{- else }}
+<p>No issues.</p>
+{{- end }}
+<details id="{{ .Id }}-output">
+<summary>Raw output of {{ .Name }}</summary>
+<pre>{{ .Output }}</pre>
+</details>
+{{- end }}
+</body>
+</html>
+`))
+
+/**
+ * Write the results as a self-contained HTML page counting the issues by tool, directory and severity, followed by
+ * the issues and the raw output of each tool
+ */
+func HTML(results []Result) ([]byte, error) {
+	data := struct {
+		Generated   string
+		Severities  []Severity
+		Totals      severityCount
+		Tools       []htmlTool
+		Directories []severityCount
+	}{Generated: time.Now().Format("2006-01-02 15:04"), Severities: severities, Totals: newCount("")}
+
+	directories := make(map[string]*severityCount)
+
+	for _, result := range results {
+		tool := htmlTool{severityCount: newCount(tools.Name(result.Tool)), Id: string(result.Tool), Issues: result.Issues, Output: result.Output}
+
+		for _, issue := range result.Issues {
+			directory := projectDirectoryLabel
+
+			if issue.File != "" {
+				directory = path.Dir(issue.File)
+			}
+
+			if directories[directory] == nil {
+				count := newCount(directory)
+				directories[directory] = &count
+			}
+
+			tool.add(issue.Severity)
+			data.Totals.add(issue.Severity)
+			directories[directory].add(issue.Severity)
+		}
+
+		data.Tools = append(data.Tools, tool)
+	}
+
+	for _, directory := range sortedKeys(directories) {
+		data.Directories = append(data.Directories, *directories[directory])
+	}
+
+	// The directories with the most issues first
+	sort.SliceStable(data.Directories, func(i, j int) bool {
+		return data.Directories[i].Total > data.Directories[j].Total
+	})
+
+	var page strings.Builder
+	err := htmlPage.Execute(&page, data)
+
+	return []byte(page.String()), err
+}
+
+func newCount(name string) severityCount {
+	return severityCount{Name: name, Counts: make(map[Severity]int)}
+}
+
+func (count *severityCount) add(severity Severity) {
+	count.Counts[severity]++
+	count.Total++
+}
+
+/**
+ * Return the file and line of the issue as shown by editors, e.g. src/Foo.php:12
+ */
+func getLocation(issue Issue) string {
+	if issue.File == "" {
+		return projectDirectoryLabel
+	}
+
+	if issue.Line > 0 {
+		return issue.File + ":" + strconv.Itoa(issue.Line)
+	}
+
+	return issue.File
+}
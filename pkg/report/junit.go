@@ -0,0 +1,115 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/tools"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+/**
+ * Merge the results into a JUnit XML report holding a test suite per tool, where each file with issues is a failed
+ * test case so that CI servers follow its history. A tool without issues has a single passed test case.
+ */
+func JUnit(results []Result) []byte {
+	document := junitTestSuites{Name: "phptooling"}
+
+	for _, result := range results {
+		suite := junitTestSuite{Name: tools.Name(result.Tool)}
+		var files []string
+		issuesByFile := make(map[string][]Issue)
+
+		for _, issue := range result.Issues {
+			if _, found := issuesByFile[issue.File]; !found {
+				files = append(files, issue.File)
+			}
+
+			issuesByFile[issue.File] = append(issuesByFile[issue.File], issue)
+		}
+
+		for _, file := range files {
+			suite.Cases = append(suite.Cases, getJUnitTestCase(result.Tool, file, issuesByFile[file]))
+		}
+
+		if len(suite.Cases) == 0 {
+			suite.Cases = []junitTestCase{{Name: tools.Name(result.Tool), ClassName: string(result.Tool)}}
+		}
+
+		suite.Tests = len(suite.Cases)
+		suite.Failures = len(files)
+		document.Suites = append(document.Suites, suite)
+		document.Tests += suite.Tests
+		document.Failures += suite.Failures
+	}
+
+	data, _ := xml.MarshalIndent(document, "", "    ")
+
+	return append([]byte(xml.Header), append(data, '\n')...)
+}
+
+/**
+ * Return the failed test case of the issues of a file, the issues of the whole project are named after the tool
+ */
+func getJUnitTestCase(tool tools.Tool, file string, issues []Issue) junitTestCase {
+	testCase := junitTestCase{Name: file, ClassName: string(tool), File: file}
+
+	if file == "" {
+		testCase.Name = tools.Name(tool)
+	}
+
+	var lines []string
+
+	for _, issue := range issues {
+		location := file
+
+		if issue.Line > 0 {
+			location += ":" + strconv.Itoa(issue.Line)
+		}
+
+		line := string(issue.Severity) + ": " + issue.Message
+
+		if issue.Rule != "" {
+			line += " (" + issue.Rule + ")"
+		}
+
+		lines = append(lines, strings.TrimPrefix(location+" "+line, " "))
+	}
+
+	message := strconv.Itoa(len(issues)) + " issues"
+
+	if len(issues) == 1 {
+		message = issues[0].Message
+	}
+
+	testCase.Failure = &junitFailure{Message: message, Type: string(tool), Text: strings.Join(lines, "\n")}
+
+	return testCase
+}
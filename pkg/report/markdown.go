@@ -0,0 +1,74 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/tools"
+	"strconv"
+	"strings"
+)
+
+// Issues listed by tool in the summary, the others are only counted
+const markdownIssuesByTool = 20
+
+/**
+ * Summarise the results in Markdown, e.g. for a comment of a pull request: a table counting the issues of each tool
+ * by severity, then the first issues of each tool in collapsed sections
+ */
+func Markdown(results []Result) string {
+	var summary strings.Builder
+
+	summary.WriteString("### Quality report\n\n| Tool | Errors | Warnings | Notes |\n| --- | ---: | ---: | ---: |\n")
+
+	for _, result := range results {
+		count := newCount(tools.Name(result.Tool))
+
+		for _, issue := range result.Issues {
+			count.add(issue.Severity)
+		}
+
+		status := ":white_check_mark:"
+
+		if count.Counts[Error] > 0 {
+			status = ":x:"
+		} else if count.Total > 0 {
+			status = ":warning:"
+		}
+
+		summary.WriteString("| " + status + " " + count.Name + " | " + strconv.Itoa(count.Counts[Error]) + " | " + strconv.Itoa(count.Counts[Warning]) + " | " + strconv.Itoa(count.Counts[Note]) + " |\n")
+	}
+
+	summary.WriteString("\n**" + strconv.Itoa(Count(results)) + " issues** found by phptooling.\n")
+
+	for _, result := range results {
+		if len(result.Issues) == 0 {
+			continue
+		}
+
+		summary.WriteString("\n<details><summary>" + tools.Name(result.Tool) + ": " + strconv.Itoa(len(result.Issues)) + " issues</summary>\n\n")
+
+		for i, issue := range result.Issues {
+			if i == markdownIssuesByTool {
+				summary.WriteString("- and " + strconv.Itoa(len(result.Issues)-i) + " more\n")
+				break
+			}
+
+			summary.WriteString("- `" + getLocation(issue) + "` " + string(issue.Severity) + ": " + escapeMarkdown(issue.Message))
+
+			if issue.Rule != "" {
+				summary.WriteString(" (`" + issue.Rule + "`)")
+			}
+
+			summary.WriteString("\n")
+		}
+
+		summary.WriteString("\n</details>\n")
+	}
+
+	return summary.String()
+}
+
+/**
+ * Keep the messages on one line, without the HTML they may contain being rendered, e.g. the types of PHPStan
+ */
+func escapeMarkdown(message string) string {
+	return strings.NewReplacer("\n", " ", "<", "&lt;", ">", "&gt;").Replace(message)
+}
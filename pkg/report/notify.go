@@ -0,0 +1,86 @@
+package report
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/tools"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Post whether each tool passed and the number of issues it found to the webhook of a Slack (slack) or Microsoft Teams
+ * (teams) channel, variables of the URL are expanded
+ */
+func Notify(ctx context.Context, service string, url string, title string, results []Result) error {
+	operation := "notify " + service
+	url = os.ExpandEnv(url)
+
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return failure.New(failure.Configuration, operation, "the URL of the webhook is not an HTTP one, are its variables set?")
+	}
+
+	title += ": " + strconv.Itoa(Count(results)) + " issues"
+
+	if service == config.TeamsNotification {
+		return callApi(ctx, operation, "Teams", http.MethodPost, url, nil, getTeamsMessage(title, results), nil)
+	}
+
+	return callApi(ctx, operation, "Slack", http.MethodPost, url, nil, getSlackMessage(title, results), nil)
+}
+
+/**
+ * Return the message of a Slack incoming webhook, in mrkdwn
+ */
+func getSlackMessage(title string, results []Result) map[string]string {
+	text := "*" + title + "*"
+
+	for _, result := range results {
+		status := ":white_check_mark:"
+
+		if len(result.Issues) > 0 {
+			status = ":x:"
+		}
+
+		text += "\n" + status + " " + tools.Name(result.Tool) + ": " + getIssuesSummary(result)
+	}
+
+	return map[string]string{"text": text}
+}
+
+/**
+ * Return the message of a Teams workflow webhook, an adaptive card listing the tools as facts
+ */
+func getTeamsMessage(title string, results []Result) map[string]any {
+	var facts []map[string]string
+
+	for _, result := range results {
+		facts = append(facts, map[string]string{"title": tools.Name(result.Tool), "value": getIssuesSummary(result)})
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []map[string]any{
+			{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "wrap": true},
+			{"type": "FactSet", "facts": facts},
+		},
+	}
+
+	return map[string]any{
+		"type":        "message",
+		"attachments": []map[string]any{{"contentType": "application/vnd.microsoft.card.adaptive", "content": card}},
+	}
+}
+
+func getIssuesSummary(result Result) string {
+	if len(result.Issues) == 0 {
+		return "passed"
+	}
+
+	return "failed with " + strconv.Itoa(len(result.Issues)) + " issues"
+}
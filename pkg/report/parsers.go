@@ -0,0 +1,320 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Read the output of phpstan analyse --error-format=json
+ */
+func parsePhpStan(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		// An empty list instead of an object when there is no error
+		Files  json.RawMessage `json:"files"`
+		Errors []string        `json:"errors"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]struct {
+		Messages []struct {
+			Message    string `json:"message"`
+			Line       int    `json:"line"`
+			Identifier string `json:"identifier"`
+		} `json:"messages"`
+	})
+
+	if bytes.HasPrefix(bytes.TrimSpace(output.Files), []byte("{")) {
+		if err := json.Unmarshal(output.Files, &files); err != nil {
+			return nil, err
+		}
+	}
+
+	var issues []Issue
+
+	for _, file := range sortedKeys(files) {
+		for _, message := range files[file].Messages {
+			issues = append(issues, Issue{File: relative(file), Line: message.Line, Rule: message.Identifier, Message: message.Message, Severity: Error})
+		}
+	}
+
+	// e.g. a class which couldn't be loaded
+	for _, message := range output.Errors {
+		issues = append(issues, Issue{Message: message, Severity: Error})
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the output of psalm --output-format=json
+ */
+func parsePsalm(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output []struct {
+		Severity string `json:"severity"`
+		Line     int    `json:"line_from"`
+		Column   int    `json:"column_from"`
+		Type     string `json:"type"`
+		Message  string `json:"message"`
+		File     string `json:"file_path"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, entry := range output {
+		severity := Error
+
+		// Issues below the error level of psalm.xml
+		if entry.Severity != "error" {
+			severity = Note
+		}
+
+		issues = append(issues, Issue{File: relative(entry.File), Line: entry.Line, Column: entry.Column, Rule: entry.Type, Message: entry.Message, Severity: severity})
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the output of phpcs --report=json
+ */
+func parsePhpCS(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		Files map[string]struct {
+			Messages []struct {
+				Message string `json:"message"`
+				Source  string `json:"source"`
+				Type    string `json:"type"`
+				Line    int    `json:"line"`
+				Column  int    `json:"column"`
+			} `json:"messages"`
+		} `json:"files"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, file := range sortedKeys(output.Files) {
+		for _, message := range output.Files[file].Messages {
+			severity := Warning
+
+			if message.Type == "ERROR" {
+				severity = Error
+			}
+
+			issues = append(issues, Issue{File: relative(file), Line: message.Line, Column: message.Column, Rule: message.Source, Message: message.Message, Severity: severity})
+		}
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the output of phpmd in the json format
+ */
+func parsePhpMD(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		Files []struct {
+			File       string `json:"file"`
+			Violations []struct {
+				Line        int    `json:"beginLine"`
+				Description string `json:"description"`
+				Rule        string `json:"rule"`
+				Priority    int    `json:"priority"`
+			} `json:"violations"`
+		} `json:"files"`
+		// Files which couldn't be parsed
+		Errors []struct {
+			File    string `json:"fileName"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, file := range output.Files {
+		for _, violation := range file.Violations {
+			severity := Warning
+
+			// Priorities go from 1 (highest) to 5
+			if violation.Priority <= 2 {
+				severity = Error
+			}
+
+			issues = append(issues, Issue{File: relative(file.File), Line: violation.Line, Rule: violation.Rule, Message: violation.Description, Severity: severity})
+		}
+	}
+
+	for _, parseError := range output.Errors {
+		issues = append(issues, Issue{File: relative(parseError.File), Message: parseError.Message, Severity: Error})
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the output of php-cs-fixer fix --dry-run --format=json, which tells the rules each file breaks but not where
+ */
+func parsePhpCsFixer(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		Files []struct {
+			Name          string   `json:"name"`
+			AppliedFixers []string `json:"appliedFixers"`
+		} `json:"files"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, file := range output.Files {
+		for _, fixer := range file.AppliedFixers {
+			issues = append(issues, Issue{File: relative(file.Name), Rule: fixer, Message: "The file doesn't follow the " + fixer + " rule", Severity: Warning})
+		}
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the output of composer-require-checker check --output=json, its issues are about composer.json
+ */
+func parseComposerRequireChecker(data []byte, _ func(file string) string) ([]Issue, error) {
+	var output struct {
+		// Guessed packages by symbol
+		UnknownSymbols map[string][]string `json:"unknown-symbols"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, symbol := range sortedKeys(output.UnknownSymbols) {
+		message := symbol + " is used without requiring the package defining it"
+
+		if guessed := output.UnknownSymbols[symbol]; len(guessed) > 0 {
+			message += ", e.g. " + strings.Join(guessed, " or ")
+		}
+
+		issues = append(issues, Issue{File: "composer.json", Rule: "unknown-symbol", Message: message, Severity: Error})
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read the PMD-CPD XML of phpcpd --log-pmd, each duplication is reported on its first occurrence
+ */
+func parsePmdCpd(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		Duplications []struct {
+			Lines int `xml:"lines,attr"`
+			Files []struct {
+				Path string `xml:"path,attr"`
+				Line int    `xml:"line,attr"`
+			} `xml:"file"`
+		} `xml:"duplication"`
+	}
+
+	if err := xml.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, duplication := range output.Duplications {
+		if len(duplication.Files) == 0 {
+			continue
+		}
+
+		var copies []string
+
+		for _, file := range duplication.Files[1:] {
+			copies = append(copies, relative(file.Path)+":"+strconv.Itoa(file.Line))
+		}
+
+		first := duplication.Files[0]
+		message := strconv.Itoa(duplication.Lines) + " lines are duplicated in " + strings.Join(copies, ", ")
+		issues = append(issues, Issue{File: relative(first.Path), Line: first.Line, Rule: "duplication", Message: message, Severity: Warning})
+	}
+
+	return issues, nil
+}
+
+/**
+ * Read a checkstyle XML report, supported by many tools
+ */
+func parseCheckstyle(data []byte, relative func(file string) string) ([]Issue, error) {
+	var output struct {
+		Files []struct {
+			Name   string `xml:"name,attr"`
+			Errors []struct {
+				Line     int    `xml:"line,attr"`
+				Column   int    `xml:"column,attr"`
+				Severity string `xml:"severity,attr"`
+				Message  string `xml:"message,attr"`
+				Source   string `xml:"source,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+
+	if err := xml.Unmarshal(data, &output); err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, file := range output.Files {
+		for _, entry := range file.Errors {
+			severity := Error
+
+			switch entry.Severity {
+			case "warning":
+				severity = Warning
+			case "info", "ignore":
+				severity = Note
+			}
+
+			issues = append(issues, Issue{File: relative(file.Name), Line: entry.Line, Column: entry.Column, Rule: entry.Source, Message: entry.Message, Severity: severity})
+		}
+	}
+
+	return issues, nil
+}
+
+/**
+ * Return the keys of the map in order, so that reports don't change from one run to the other
+ */
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/tools"
+	"path"
+	"strings"
+)
+
+// Severity of an issue, named after the levels of SARIF
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Note    Severity = "note"
+)
+
+// Issue is something a tool found, whatever the format it was read from
+type Issue struct {
+	// Relative to the project, empty for the issues of the whole project
+	File string
+	// Starting at 1, 0 when the tool doesn't tell
+	Line   int
+	Column int
+	// Identifier of the check which failed, e.g. the sniff of PHP_CodeSniffer, empty when the tool has none
+	Rule     string
+	Message  string
+	Severity Severity
+}
+
+// Result holds the issues found by a tool
+type Result struct {
+	Tool   tools.Tool
+	Issues []Issue
+	// Raw output the issues were read from
+	Output string
+}
+
+// parser reads the issues of an output, paths are given to relative to be made relative to the project
+type parser func(data []byte, relative func(file string) string) ([]Issue, error)
+
+// Parsers of the machine formats of the tools, by format of the registry
+var parsers = map[string]parser{
+	"phpstan":                  parsePhpStan,
+	"psalm":                    parsePsalm,
+	"phpcs":                    parsePhpCS,
+	"phpmd":                    parsePhpMD,
+	"php-cs-fixer":             parsePhpCsFixer,
+	"composer-require-checker": parseComposerRequireChecker,
+	"pmd-cpd":                  parsePmdCpd,
+	"checkstyle":               parseCheckstyle,
+}
+
+/**
+ * Read the issues of an output in the format, paths of the working directory the tool was run from are made
+ * relative to the project
+ */
+func Parse(format string, data []byte, workingDirectory string) ([]Issue, error) {
+	parse, found := parsers[format]
+
+	if !found {
+		return nil, failure.New(failure.Configuration, "read the report", "unknown report format "+format)
+	}
+
+	issues, err := parse(data, func(file string) string {
+		return relativePath(file, workingDirectory)
+	})
+
+	if err != nil {
+		return nil, failure.Wrap(failure.Command, "read the "+format+" report", err)
+	}
+
+	return issues, nil
+}
+
+/**
+ * Return the path relative to the working directory when it is inside it, e.g. the absolute paths of a container
+ */
+func relativePath(file string, workingDirectory string) string {
+	if file == "" {
+		return ""
+	}
+
+	file = path.Clean(file)
+
+	if relative, found := strings.CutPrefix(file, path.Clean(workingDirectory)+"/"); found {
+		return relative
+	}
+
+	return file
+}
+
+/**
+ * Return the number of issues of the results
+ */
+func Count(results []Result) int {
+	count := 0
+
+	for _, result := range results {
+		count += len(result.Issues)
+	}
+
+	return count
+}
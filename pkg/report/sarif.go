@@ -0,0 +1,120 @@
+package report
+
+import (
+	"ecohead/phptooling/pkg/tools"
+	"encoding/json"
+)
+
+// Version of SARIF written, the one supported by GitHub code scanning
+const sarifVersion = "2.1.0"
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Base of the artifact URIs, standing for the root of the repository
+const sarifRoot = "%SRCROOT%"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun holds the results of one tool
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	Id string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     Severity        `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+/**
+ * Merge the results into a SARIF log holding a run per tool
+ */
+func Sarif(results []Result) []byte {
+	document := sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{}}
+
+	for _, result := range results {
+		run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: tools.Name(result.Tool)}}, Results: []sarifResult{}}
+		known := make(map[string]bool)
+
+		for _, issue := range result.Issues {
+			// Code scanning groups the alerts by rule
+			rule := issue.Rule
+
+			if rule == "" {
+				rule = string(result.Tool)
+			}
+
+			if !known[rule] {
+				known[rule] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{Id: rule})
+			}
+
+			run.Results = append(run.Results, sarifResult{RuleId: rule, Level: issue.Severity, Message: sarifMessage{Text: issue.Message}, Locations: getSarifLocations(issue)})
+		}
+
+		document.Runs = append(document.Runs, run)
+	}
+
+	data, _ := json.MarshalIndent(document, "", "    ")
+
+	return append(data, '\n')
+}
+
+/**
+ * Return where the issue is, none for the issues of the whole project
+ */
+func getSarifLocations(issue Issue) []sarifLocation {
+	if issue.File == "" {
+		return nil
+	}
+
+	location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{Uri: issue.File, UriBaseId: sarifRoot}}
+
+	if issue.Line > 0 {
+		location.Region = &sarifRegion{StartLine: issue.Line, StartColumn: issue.Column}
+	}
+
+	return []sarifLocation{{PhysicalLocation: location}}
+}
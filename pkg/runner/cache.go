@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// CachingRunner asks the working directory to the runner it wraps once, as it runs a command in containers
+type CachingRunner struct {
+	CommandRunner
+	mutex            sync.Mutex
+	workingDirectory string
+}
+
+func WithCachedWorkingDirectory(commandRunner CommandRunner) *CachingRunner {
+	return &CachingRunner{CommandRunner: commandRunner}
+}
+
+/**
+ * Return the working directory of the wrapped runner, failed lookups are tried again on the next call
+ */
+func (runner *CachingRunner) WorkingDirectory(ctx context.Context) (string, error) {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	if runner.workingDirectory != "" {
+		return runner.workingDirectory, nil
+	}
+
+	workingDirectory, err := runner.CommandRunner.WorkingDirectory(ctx)
+
+	if err == nil {
+		runner.workingDirectory = workingDirectory
+	}
+
+	return workingDirectory, err
+}
+
+/**
+ * Forget the working directory, e.g. when the container of the wrapped runner was recreated
+ */
+func (runner *CachingRunner) Refresh() {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	runner.workingDirectory = ""
+}
+
+/**
+ * Close the wrapped runner when it keeps something open between commands, e.g. a SessionRunner
+ */
+func (runner *CachingRunner) Close() error {
+	if closer, ok := runner.CommandRunner.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
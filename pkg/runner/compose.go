@@ -0,0 +1,415 @@
+package runner
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**
+ * Return the docker compose file of the project, or an empty string if it doesn't use docker compose
+ */
+func DetectComposeFile(projectDirectory string) string {
+	composeFilePossibilities := []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"}
+
+	for _, file := range composeFilePossibilities {
+		_, err := os.Stat(filepath.Join(projectDirectory, file))
+
+		if err == nil {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// ComposeService is a service of the docker compose file of the project
+type ComposeService struct {
+	Name  string
+	Image string
+	// Whether the service likely runs PHP, from its name, image or Dockerfile
+	Php bool
+}
+
+// Words found in the names and images of the services running PHP
+var phpServiceHints = []string{"php", "fpm", "wordpress", "drupal", "laravel", "symfony", "frankenphp"}
+
+// Time given to docker compose to resolve the compose file, after which it is parsed by phptooling
+const composeConfigTimeout = 10 * time.Second
+
+// Services extending each other further are considered as a loop
+const maxExtendsDepth = 10
+
+/**
+ * Return the services of the docker compose file, the ones likely running PHP first then by name. The file is
+ * resolved by docker compose config when docker is available, with its override file, extends and variables,
+ * otherwise it is parsed here: anchors, extends and variables of the environment and of .env are supported.
+ */
+func ComposeServices(projectDirectory string, composeFile string) ([]ComposeService, error) {
+	services, resolveErr := resolveComposeServices(projectDirectory)
+
+	if resolveErr != nil {
+		slog.Debug("Could not resolve the compose file with docker compose config, parsing it", "error", resolveErr)
+
+		var parseErr error
+		services, parseErr = parseComposeServices(projectDirectory, composeFile)
+
+		if parseErr != nil {
+			return nil, parseErr
+		}
+	}
+
+	var servicesList []ComposeService
+
+	for name, attributes := range services {
+		service := ComposeService{Name: name}
+		service.Image, _ = attributes["image"].(string)
+		service.Php = hasPhpHint(service.Name) || hasPhpHint(service.Image) ||
+			hasPhpHint(readDockerfileImages(projectDirectory, attributes["build"]))
+
+		servicesList = append(servicesList, service)
+	}
+
+	sort.Slice(servicesList, func(i, j int) bool {
+		if servicesList[i].Php != servicesList[j].Php {
+			return servicesList[i].Php
+		}
+
+		return servicesList[i].Name < servicesList[j].Name
+	})
+
+	return servicesList, nil
+}
+
+/**
+ * Return the attributes of the services as resolved by docker compose from the project directory, the way the
+ * commands of the justfile will find them
+ */
+func resolveComposeServices(projectDirectory string) (map[string]map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), composeConfigTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker", "compose", "config", "--format", "json")
+	cmd.Dir = projectDirectory
+	output, err := cmd.Output()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var model struct {
+		Services map[string]map[string]interface{} `json:"services"`
+	}
+
+	unmarshalErr := json.Unmarshal(output, &model)
+
+	return model.Services, unmarshalErr
+}
+
+/**
+ * Parse the services of the compose file, with their variables replaced and the attributes of the services they
+ * extend. Services which aren't mappings are left out.
+ */
+func parseComposeServices(projectDirectory string, composeFile string) (map[string]map[string]interface{}, error) {
+	services, readErr := readComposeServices(projectDirectory, composeFile)
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	env := readDotEnv(projectDirectory)
+	resolved := make(map[string]map[string]interface{})
+
+	for name := range services {
+		attributes, extendsErr := extendComposeService(projectDirectory, composeFile, services, name, 0)
+
+		if extendsErr != nil {
+			slog.Warn("Ignoring the "+name+" service of "+composeFile, "error", extendsErr)
+			continue
+		}
+
+		resolved[name] = interpolateComposeValue(attributes, env).(map[string]interface{})
+	}
+
+	return resolved, nil
+}
+
+/**
+ * Read the services of the compose file, relative to the project, keyed by name
+ */
+func readComposeServices(projectDirectory string, composeFile string) (map[string]interface{}, error) {
+	m := make(map[interface{}]interface{})
+
+	file, fileErr := os.ReadFile(filepath.Join(projectDirectory, composeFile))
+
+	if fileErr != nil {
+		return nil, failure.Wrap(failure.Environment, "read "+composeFile, fileErr)
+	}
+
+	parseErr := yaml.Unmarshal(file, &m)
+
+	if parseErr != nil {
+		return nil, failure.Wrap(failure.Environment, "parse "+composeFile, parseErr)
+	}
+
+	services, isMap := m["services"].(map[interface{}]interface{})
+
+	if m["services"] != nil && !isMap {
+		return nil, failure.New(failure.Environment, "parse "+composeFile, "services must be a mapping of the services by name")
+	}
+
+	byName := make(map[string]interface{}, len(services))
+
+	for name, definition := range services {
+		byName[fmt.Sprint(name)] = definition
+	}
+
+	return byName, nil
+}
+
+/**
+ * Return the attributes of the service merged over the ones of the service it extends, the extended service may be
+ * defined in another file relative to the compose file
+ */
+func extendComposeService(projectDirectory string, composeFile string, services map[string]interface{}, name string, depth int) (map[string]interface{}, error) {
+	if depth > maxExtendsDepth {
+		return nil, errors.New("the services extend each other in a loop")
+	}
+
+	definition, found := services[name]
+
+	if !found {
+		return nil, errors.New("the " + name + " service doesn't exist in " + composeFile)
+	}
+
+	// e.g. a service defined as a list of ports by mistake
+	definitionMap, isMap := definition.(map[interface{}]interface{})
+
+	if !isMap && definition != nil {
+		return nil, errors.New("the definition of the " + name + " service isn't a mapping")
+	}
+
+	attributes := toStringMap(definitionMap)
+	var base, baseFile string
+
+	switch extends := attributes["extends"].(type) {
+	case nil:
+		return attributes, nil
+	case string:
+		base, baseFile = extends, composeFile
+	case map[string]interface{}:
+		base, _ = extends["service"].(string)
+		baseFile = composeFile
+
+		if file, ok := extends["file"].(string); ok {
+			baseFile = filepath.ToSlash(filepath.Join(filepath.Dir(composeFile), file))
+		}
+	default:
+		return nil, errors.New("the extends of the " + name + " service must name a service")
+	}
+
+	baseServices := services
+
+	if baseFile != composeFile {
+		var readErr error
+		baseServices, readErr = readComposeServices(projectDirectory, baseFile)
+
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	baseAttributes, baseErr := extendComposeService(projectDirectory, baseFile, baseServices, base, depth+1)
+
+	if baseErr != nil {
+		return nil, baseErr
+	}
+
+	delete(attributes, "extends")
+
+	for key, value := range attributes {
+		baseAttributes[key] = value
+	}
+
+	return baseAttributes, nil
+}
+
+/**
+ * Convert the mappings parsed by yaml to maps keyed by strings, like the ones of docker compose config
+ */
+func toStringMap(m map[interface{}]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(m))
+
+	for key, value := range m {
+		if valueMap, isMap := value.(map[interface{}]interface{}); isMap {
+			value = toStringMap(valueMap)
+		}
+
+		converted[fmt.Sprint(key)] = value
+	}
+
+	return converted
+}
+
+// Variables of compose files: $$ for a dollar, ${NAME}, ${NAME:-default} and the like, or $NAME
+var composeVariablePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+/**
+ * Replace the variables of the strings of the value by the environment, then by the .env file of the project, as
+ * docker compose does
+ */
+func interpolateComposeValue(value interface{}, env map[string]string) interface{} {
+	switch value := value.(type) {
+	case string:
+		return composeVariablePattern.ReplaceAllStringFunc(value, func(variable string) string {
+			if variable == "$$" {
+				return "$"
+			}
+
+			match := composeVariablePattern.FindStringSubmatch(variable)
+			name, operator, argument := match[1]+match[4], match[2], match[3]
+			current, set := os.LookupEnv(name)
+
+			if !set {
+				current, set = env[name]
+			}
+
+			empty := !set || current == ""
+
+			switch operator {
+			case ":-":
+				if empty {
+					return argument
+				}
+			case "-":
+				if !set {
+					return argument
+				}
+			case ":+":
+				if empty {
+					return ""
+				}
+
+				return argument
+			case "+":
+				if !set {
+					return ""
+				}
+
+				return argument
+			}
+
+			// Required variables (:? and ?) stop docker compose when missing, they are left empty to list the services
+			return current
+		})
+	case map[string]interface{}:
+		for key, attribute := range value {
+			value[key] = interpolateComposeValue(attribute, env)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = interpolateComposeValue(item, env)
+		}
+	}
+
+	return value
+}
+
+/**
+ * Return the variables of the .env file of the project, empty when it has none
+ */
+func readDotEnv(projectDirectory string) map[string]string {
+	env := make(map[string]string)
+	content, readErr := os.ReadFile(filepath.Join(projectDirectory, ".env"))
+
+	if readErr != nil {
+		return env
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		name, value, found := strings.Cut(line, "=")
+
+		if !found || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env[strings.TrimSpace(name)] = value
+	}
+
+	return env
+}
+
+func hasPhpHint(value string) bool {
+	value = strings.ToLower(value)
+
+	for _, hint := range phpServiceHints {
+		if strings.Contains(value, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Return the FROM lines of the Dockerfile built for a service, given as a context or as a context and a dockerfile
+ */
+func readDockerfileImages(projectDirectory string, build interface{}) string {
+	directory, dockerfile := "", "Dockerfile"
+
+	switch build := build.(type) {
+	case string:
+		directory = build
+	case map[string]interface{}:
+		directory, _ = build["context"].(string)
+
+		if file, ok := build["dockerfile"].(string); ok {
+			dockerfile = file
+		}
+	default:
+		return ""
+	}
+
+	// docker compose config gives absolute paths
+	if !filepath.IsAbs(directory) {
+		directory = filepath.Join(projectDirectory, directory)
+	}
+
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(directory, dockerfile)
+	}
+
+	content, readErr := os.ReadFile(dockerfile)
+
+	if readErr != nil {
+		return ""
+	}
+
+	var images []string
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && strings.EqualFold(fields[0], "FROM") {
+			images = append(images, fields[1])
+		}
+	}
+
+	return strings.Join(images, " ")
+}
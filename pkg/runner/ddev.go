@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DdevRunner runs commands in the web container of a ddev project
+type DdevRunner struct{}
+
+/**
+ * Whether the project is configured for ddev
+ */
+func DetectDdev(projectDirectory string) bool {
+	_, err := os.Stat(filepath.Join(projectDirectory, ".ddev", "config.yaml"))
+
+	return err == nil
+}
+
+func (runner DdevRunner) Run(ctx context.Context, command []string) error {
+	return run(ctx, getCommandLine(runner.Prefix(), command), command[0], nil, nil)
+}
+
+func (runner DdevRunner) RunWithInput(ctx context.Context, command []string, input io.Reader) error {
+	return run(ctx, getCommandLine(runner.NonInteractivePrefix(), command), command[0], nil, input)
+}
+
+func (runner DdevRunner) WorkingDirectory(ctx context.Context) (string, error) {
+	return getContainerWorkingDirectory(ctx, runner.NonInteractivePrefix(), "the ddev web container")
+}
+
+func (runner DdevRunner) Prefix() string {
+	return "ddev exec"
+}
+
+// ddev exec only allocates a TTY when run from a terminal
+func (runner DdevRunner) NonInteractivePrefix() string {
+	return "ddev exec"
+}
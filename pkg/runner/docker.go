@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Where the composer cache is mounted in run containers
+const containerComposerCache = "/tmp/composer-cache"
+
+// Composer home of run containers when an auth.json is mounted in it
+const containerComposerHome = "/tmp/composer-home"
+
+// Time given to the docker daemon to answer, it is considered as stopped past it
+const dockerInfoTimeout = 10 * time.Second
+
+// ComposeRunner runs commands in a service of the docker compose file of the project
+type ComposeRunner struct {
+	Service string
+	// exec to run commands in the running container, run to start a new one for each command
+	Command string
+	// Named volume or host directory (e.g. ~/.composer/cache) mounted as the composer cache of run containers, so
+	// that they don't start with a cold cache, none when empty
+	ComposerCache string
+	// auth.json of the host mounted in run containers, exec ones receive its content as COMPOSER_AUTH from the
+	// environment of the command line. None when empty.
+	ComposerAuth string
+}
+
+func (runner ComposeRunner) Run(ctx context.Context, command []string) error {
+	return run(ctx, getCommandLine(runner.Prefix(), command), command[0], nil, nil)
+}
+
+func (runner ComposeRunner) RunWithInput(ctx context.Context, command []string, input io.Reader) error {
+	return run(ctx, getCommandLine(runner.NonInteractivePrefix(), command), command[0], nil, input)
+}
+
+/**
+ * Return the directory commands are run from inside the container, asked to the service on each call
+ */
+func (runner ComposeRunner) WorkingDirectory(ctx context.Context) (string, error) {
+	return getContainerWorkingDirectory(ctx, runner.NonInteractivePrefix(), "the "+runner.Service+" service")
+}
+
+func (runner ComposeRunner) Prefix() string {
+	if runner.Command == "exec" {
+		return "docker compose exec " + runner.getAuthOptions() + runner.Service
+	}
+
+	return "docker compose run --rm " + runner.getCacheOptions() + runner.getAuthOptions() + runner.Service
+}
+
+func (runner ComposeRunner) NonInteractivePrefix() string {
+	if runner.Command == "exec" {
+		return "docker compose exec -T " + runner.getAuthOptions() + runner.Service
+	}
+
+	return "docker compose run --rm -T " + runner.getCacheOptions() + runner.getAuthOptions() + runner.Service
+}
+
+/**
+ * Return the options mounting the composer cache followed by a space, empty without cache
+ */
+func (runner ComposeRunner) getCacheOptions() string {
+	if runner.ComposerCache == "" {
+		return ""
+	}
+
+	return "-v " + QuotePath(runner.ComposerCache+":"+containerComposerCache) + " -e COMPOSER_CACHE_DIR=" + containerComposerCache + " "
+}
+
+/**
+ * Return the options giving the credentials of composer followed by a space, empty without auth.json
+ */
+func (runner ComposeRunner) getAuthOptions() string {
+	if runner.ComposerAuth == "" {
+		return ""
+	}
+
+	if runner.Command == "exec" {
+		return "-e COMPOSER_AUTH "
+	}
+
+	return "-v " + QuotePath(runner.ComposerAuth+":"+containerComposerHome+"/auth.json:ro") + " -e COMPOSER_HOME=" + containerComposerHome + " "
+}
+
+/**
+ * Check that docker is installed and that its daemon answers, along with ddev when it runs the commands, before the
+ * first command fails in a less explicit way
+ */
+func CheckDocker(ctx context.Context, ddev bool) error {
+	if _, err := exec.LookPath("ddev"); ddev && err != nil {
+		return failure.New(failure.Environment, "find ddev", "ddev isn't installed or isn't in the PATH")
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		return failure.New(failure.Environment, "find docker", "docker isn't installed or isn't in the PATH")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dockerInfoTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}").CombinedOutput()
+
+	if err != nil {
+		reason := strings.TrimSpace(string(output))
+
+		if reason == "" {
+			reason = err.Error()
+		}
+
+		return failure.New(failure.Environment, "reach the docker daemon", "it isn't running or can't be reached: "+reason)
+	}
+
+	return nil
+}
+
+/**
+ * Give the content of the auth.json to the commands run from now on through COMPOSER_AUTH, which exec containers
+ * receive from the environment. A COMPOSER_AUTH already set is kept.
+ */
+func ExportComposerAuth(authFile string) error {
+	if os.Getenv("COMPOSER_AUTH") != "" {
+		return nil
+	}
+
+	data, readErr := os.ReadFile(expandHome([]string{authFile})[0])
+
+	if readErr != nil {
+		return failure.Wrap(failure.Configuration, "read the composer credentials", readErr)
+	}
+
+	return failure.Classify(failure.Environment, "set COMPOSER_AUTH", os.Setenv("COMPOSER_AUTH", strings.TrimSpace(string(data))))
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"context"
+	"io"
+)
+
+// KubernetesRunner runs commands in a container of a pod with kubectl exec
+type KubernetesRunner struct {
+	// Pod or resource owning pods, e.g. deploy/app
+	Target string
+	// Container of the pod, the default one when empty
+	Container string
+	// Namespace of the target, the one of the current context when empty
+	Namespace string
+}
+
+func (runner KubernetesRunner) Run(ctx context.Context, command []string) error {
+	return run(ctx, getCommandLine(runner.Prefix(), command), command[0], nil, nil)
+}
+
+func (runner KubernetesRunner) RunWithInput(ctx context.Context, command []string, input io.Reader) error {
+	return run(ctx, getCommandLine(runner.NonInteractivePrefix(), command), command[0], nil, input)
+}
+
+func (runner KubernetesRunner) WorkingDirectory(ctx context.Context) (string, error) {
+	return getContainerWorkingDirectory(ctx, runner.NonInteractivePrefix(), runner.Target)
+}
+
+func (runner KubernetesRunner) Prefix() string {
+	return runner.getPrefix("-it")
+}
+
+func (runner KubernetesRunner) NonInteractivePrefix() string {
+	return runner.getPrefix("-i")
+}
+
+func (runner KubernetesRunner) getPrefix(terminalFlag string) string {
+	prefix := "kubectl exec " + terminalFlag
+
+	if runner.Namespace != "" {
+		prefix += " -n " + runner.Namespace
+	}
+
+	prefix += " " + runner.Target
+
+	if runner.Container != "" {
+		prefix += " -c " + runner.Container
+	}
+
+	return prefix + " --"
+}
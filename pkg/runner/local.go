@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+)
+
+// LocalRunner runs commands on the host
+type LocalRunner struct{}
+
+func (runner LocalRunner) Run(ctx context.Context, command []string) error {
+	return run(ctx, command, command[0], nil, nil)
+}
+
+func (runner LocalRunner) RunWithInput(ctx context.Context, command []string, input io.Reader) error {
+	return run(ctx, command, command[0], nil, input)
+}
+
+/**
+ * Return the directory of the project with slashes, which php, composer and just accept on Windows too, so that the
+ * paths written in the recipes are the same whatever the OS
+ */
+func (runner LocalRunner) WorkingDirectory(_ context.Context) (string, error) {
+	return filepath.ToSlash(LocalWorkingDirectory()), nil
+}
+
+func (runner LocalRunner) Prefix() string {
+	return ""
+}
+
+func (runner LocalRunner) NonInteractivePrefix() string {
+	return ""
+}
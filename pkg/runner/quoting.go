@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Arguments written as is in the command lines given to a shell, the others are quoted
+var shellSafeArgument = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+/**
+ * Quote the argument when it holds other characters than the safe ones, e.g. the spaces or $ of a path, for sh and for
+ * PowerShell, which runs the recipes of the justfile on Windows. Both take single quoted strings as they are, quotes
+ * are written as "'" between them: sh joins the adjacent strings like PowerShell does for the arguments of commands,
+ * whereas the sh escape '\'' would end the string in PowerShell, which escapes quotes by doubling them.
+ */
+func QuoteArgument(argument string) string {
+	if shellSafeArgument.MatchString(argument) {
+		return argument
+	}
+
+	return "'" + strings.ReplaceAll(argument, "'", `'"'"'`) + "'"
+}
+
+/**
+ * Same as QuoteArgument, leaving ~/ at the start of the path out of the quotes so that the shell expands it
+ */
+func QuotePath(file string) string {
+	if relative, found := strings.CutPrefix(file, "~/"); found {
+		return "~/" + QuoteArgument(relative)
+	}
+
+	return QuoteArgument(file)
+}
+
+/**
+ * Return the command as a line for sh and PowerShell, its arguments quoted when needed
+ */
+func QuoteCommand(command []string) string {
+	quoted := make([]string, len(command))
+
+	for i, argument := range command {
+		quoted[i] = QuoteArgument(argument)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+/**
+ * Split a command line like sh does, without expanding anything: arguments are separated by spaces outside of the
+ * quotes, a backslash escapes the next character outside of single quotes
+ */
+func SplitCommandLine(commandLine string) []string {
+	var arguments []string
+	var argument strings.Builder
+	// Whether an argument was started, it may be empty when given as ''
+	started := false
+	var quote rune
+	escaped := false
+
+	for _, character := range commandLine {
+		switch {
+		case escaped:
+			argument.WriteRune(character)
+			escaped = false
+		case character == '\\' && quote != '\'':
+			escaped = true
+			started = true
+		case quote != 0 && character == quote:
+			quote = 0
+		case quote != 0:
+			argument.WriteRune(character)
+		case character == '\'' || character == '"':
+			quote = character
+			started = true
+		case character == ' ' || character == '\t' || character == '\n':
+			if started {
+				arguments = append(arguments, argument.String())
+				argument.Reset()
+				started = false
+			}
+		default:
+			argument.WriteRune(character)
+			started = true
+		}
+	}
+
+	if started {
+		arguments = append(arguments, argument.String())
+	}
+
+	return arguments
+}
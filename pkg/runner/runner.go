@@ -0,0 +1,392 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/logging"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommandRunner runs the commands of the project in the environment PHP runs in (host, container, ddev, pod...)
+type CommandRunner interface {
+	// Run the command, its output is streamed to the console unless in quiet mode
+	Run(ctx context.Context, command []string) error
+	// Same as Run without a TTY, the input is sent to the command (e.g. the content of a file to write)
+	RunWithInput(ctx context.Context, command []string, input io.Reader) error
+	// Return the directory commands are run from, paths given to commands are based on it
+	WorkingDirectory(ctx context.Context) (string, error)
+	// Return what is put before commands in the justfile to run them in the environment, empty on the host
+	Prefix() string
+	// Same as Prefix, without allocating a TTY (used by git hooks and scripts)
+	NonInteractivePrefix() string
+}
+
+/**
+ * Return how composer is called from the justfile
+ */
+func ComposerAlias(commandRunner CommandRunner) string {
+	return strings.TrimSpace(commandRunner.Prefix() + " composer")
+}
+
+/**
+ * Return how php is called from the justfile
+ */
+func PhpAlias(commandRunner CommandRunner) string {
+	return strings.TrimSpace(commandRunner.Prefix() + " php")
+}
+
+// Variables of the environment of phptooling given to the composer commands run where a prefix runs them, whose
+// environment doesn't inherit it
+var composerVariables = []string{"COMPOSER_MEMORY_LIMIT", "COMPOSER_PROCESS_TIMEOUT"}
+
+/**
+ * Remove the memory limit of composer, which large dependency trees exceed, and give the timeout in seconds of the
+ * processes it runs (e.g. git clones) unless it is 0. The values already set in the environment are kept.
+ */
+func ExportComposerSettings(processTimeout int) error {
+	var err error
+
+	if _, set := os.LookupEnv("COMPOSER_MEMORY_LIMIT"); !set {
+		err = os.Setenv("COMPOSER_MEMORY_LIMIT", "-1")
+	}
+
+	if _, set := os.LookupEnv("COMPOSER_PROCESS_TIMEOUT"); !set && processTimeout > 0 && err == nil {
+		err = os.Setenv("COMPOSER_PROCESS_TIMEOUT", strconv.Itoa(processTimeout))
+	}
+
+	return failure.Classify(failure.Environment, "set the composer settings", err)
+}
+
+/**
+ * Return the command line running the command after the prefix of its environment, composer commands receive the
+ * composer settings of phptooling there
+ */
+func getCommandLine(prefix string, command []string) []string {
+	if prefix == "" {
+		return command
+	}
+
+	return append(expandHome(SplitCommandLine(prefix)), withComposerEnv(command)...)
+}
+
+/**
+ * Return the composer command run by env with the composer settings of the environment of phptooling, other
+ * commands are returned as they are
+ */
+func withComposerEnv(command []string) []string {
+	if command[0] != "composer" {
+		return command
+	}
+
+	env := []string{"env"}
+
+	for _, name := range composerVariables {
+		if value, set := os.LookupEnv(name); set {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	if len(env) == 1 {
+		return command
+	}
+
+	return append(env, command...)
+}
+
+/**
+ * Run the shell script on the host, the variables are added to the environment of phptooling. Windows has no sh, the
+ * script is run by PowerShell there.
+ */
+func RunShell(ctx context.Context, script string, env []string) error {
+	shell := []string{"sh", "-c"}
+
+	if runtime.GOOS == "windows" {
+		shell = []string{"powershell.exe", "-NoLogo", "-NoProfile", "-Command"}
+	}
+
+	return run(ctx, append(shell, script), shell[0], env, nil)
+}
+
+/**
+ * Run the command line, its output is streamed to the console unless in quiet mode and logged along with its
+ * duration, the program is the one run in the environment and tells how failures are classified. The command reads
+ * the terminal without input.
+ */
+func run(ctx context.Context, commandLine []string, program string, env []string, input io.Reader) error {
+	cmd := exec.CommandContext(ctx, commandLine[0], commandLine[1:]...)
+
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	cmd.Cancel = func() error {
+		return interrupt(cmd)
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	slog.Log(ctx, getRunningLevel(), "Running", "command", cmd.String())
+
+	var output, errorOutput bytes.Buffer
+	cmd.Stdin = os.Stdin
+
+	if input != nil {
+		cmd.Stdin = input
+	}
+
+	cmd.Stdout = getOutputWriter(slog.LevelInfo, logging.Console(), &output)
+	cmd.Stderr = getOutputWriter(slog.LevelDebug, os.Stderr, &errorOutput)
+
+	start := time.Now()
+	err := cmd.Run()
+
+	return getCommandError(ctx, cmd.String(), program, start, cmd.ProcessState.ExitCode(), err, output.String(), errorOutput.String())
+}
+
+/**
+ * Return the level commands are listed at when they start: the status line tells which step is running, commands
+ * are then only listed in verbose mode
+ */
+func getRunningLevel() slog.Level {
+	if logging.StatusShown() {
+		return slog.LevelDebug
+	}
+
+	return slog.LevelInfo
+}
+
+/**
+ * Log the end of the command along with its output and classify its error, following the program run in the
+ * environment
+ */
+func getCommandError(ctx context.Context, description string, program string, start time.Time, exitCode int, err error, output string, errorOutput string) error {
+	slog.Debug("Command finished",
+		"command", description,
+		"duration", time.Since(start).Round(time.Millisecond),
+		"exit_code", exitCode,
+		logging.OutputKey, output+errorOutput,
+	)
+	logging.Event("command", "command", description, "exit_code", exitCode, "duration_ms", time.Since(start).Milliseconds())
+
+	if err != nil && ctx.Err() != nil {
+		return failure.Wrap(failure.Aborted, description, ctx.Err())
+	}
+
+	// docker, composer or php is missing
+	if errors.Is(err, exec.ErrNotFound) {
+		return failure.Wrap(failure.Environment, description, err)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	kind := failure.Command
+
+	// Composer writes its errors to the error output, the exit status alone doesn't tell what went wrong
+	if program == "composer" {
+		kind = failure.Composer
+
+		if reason := getComposerReason(errorOutput); reason != "" {
+			err = fmt.Errorf("%w: %s", err, reason)
+		}
+	}
+
+	return &failure.Error{Kind: kind, Operation: description, Err: err, Output: getHiddenOutput(output, errorOutput)}
+}
+
+// Title of the exceptions composer prints in a box, e.g. [RuntimeException], followed by their message
+var composerExceptionPattern = regexp.MustCompile(`^\[[\w\\]+\]$`)
+
+/**
+ * Return why composer failed from its error output: the message of its exception, the unsolvable requirements, or
+ * the last line it wrote
+ */
+func getComposerReason(errorOutput string) string {
+	var lines []string
+
+	for _, line := range strings.Split(errorOutput, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	for i, line := range lines {
+		if composerExceptionPattern.MatchString(line) && i+1 < len(lines) {
+			return lines[i+1]
+		}
+
+		// Followed by the problems, each on several lines
+		if strings.HasPrefix(line, "Your requirements could not be resolved") && i+2 < len(lines) {
+			return line + " " + lines[i+2]
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return lines[len(lines)-1]
+}
+
+/**
+ * Return the outputs of a command which weren't streamed to the console
+ */
+func getHiddenOutput(output string, errorOutput string) string {
+	hidden := ""
+
+	if !isStreamed(slog.LevelInfo) {
+		hidden += output
+	}
+
+	if !isStreamed(slog.LevelDebug) {
+		hidden += errorOutput
+	}
+
+	return strings.TrimSpace(hidden)
+}
+
+/**
+ * Whether the output of commands logged at the level is streamed to the console
+ */
+func isStreamed(level slog.Level) bool {
+	return logging.OnConsole(level) && !logging.StatusShown()
+}
+
+/**
+ * Return where an output of a command goes: the console when messages of the level are shown on it and no status
+ * line hides it, which is kept as the direct output to preserve colors and progress bars unless the output must be
+ * captured for the log file
+ */
+func getOutputWriter(level slog.Level, console io.Writer, capture *bytes.Buffer) io.Writer {
+	switch {
+	case !isStreamed(level):
+		return capture
+	case logging.ToFile():
+		return io.MultiWriter(console, capture)
+	}
+
+	return console
+}
+
+/**
+ * Return the directory commands are run from in a container, by running pwd with the non-interactive prefix
+ */
+func getContainerWorkingDirectory(ctx context.Context, prefix string, description string) (string, error) {
+	commandLine := append(expandHome(SplitCommandLine(prefix)), "pwd")
+	workingDir, err := exec.CommandContext(ctx, commandLine[0], commandLine[1:]...).Output()
+
+	if err != nil {
+		return "", failure.Wrap(failure.Environment, "find the working directory of "+description, err)
+	}
+
+	return strings.TrimSpace(string(workingDir)), nil
+}
+
+/**
+ * Return the trimmed standard output of a short command run where the runner runs commands, e.g. to read a setting
+ */
+func Output(ctx context.Context, commandRunner CommandRunner, command []string, operation string) (string, error) {
+	commandLine := getCommandLine(commandRunner.NonInteractivePrefix(), command)
+	output, err := exec.CommandContext(ctx, commandLine[0], commandLine[1:]...).Output()
+
+	if err != nil {
+		return "", failure.Wrap(failure.Environment, operation, err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Captured is what a command run by Capture wrote
+type Captured struct {
+	Output      string
+	ErrorOutput string
+	ExitCode    int
+}
+
+/**
+ * Interrupt the command when its context is cancelled instead of killing it, so that docker, ddev or kubectl stop the
+ * command they run in the container, it is only killed if it doesn't stop in time. Windows can't send interrupts to
+ * processes, they are killed right away.
+ */
+func interrupt(cmd *exec.Cmd) error {
+	if runtime.GOOS == "windows" {
+		return cmd.Process.Kill()
+	}
+
+	return cmd.Process.Signal(os.Interrupt)
+}
+
+/**
+ * Run the command where the runner runs commands and return what it wrote, e.g. a tool writing its issues in a
+ * machine format. Failing exit codes aren't errors, only a command which couldn't be run is.
+ */
+func Capture(ctx context.Context, commandRunner CommandRunner, command []string) (Captured, error) {
+	commandLine := getCommandLine(commandRunner.NonInteractivePrefix(), command)
+	cmd := exec.CommandContext(ctx, commandLine[0], commandLine[1:]...)
+	cmd.Cancel = func() error {
+		return interrupt(cmd)
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	var output, errorOutput bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &errorOutput
+
+	slog.Debug("Running", "command", cmd.String())
+	start := time.Now()
+	err := cmd.Run()
+	captured := Captured{Output: output.String(), ErrorOutput: errorOutput.String(), ExitCode: cmd.ProcessState.ExitCode()}
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		// The output tells what the tool found
+		err = nil
+	}
+
+	return captured, getCommandError(ctx, cmd.String(), command[0], start, captured.ExitCode, err, captured.Output, captured.ErrorOutput)
+}
+
+/**
+ * Replace ~ at the start of the arguments by the home directory, the justfile relies on the shell to expand it
+ */
+func expandHome(commandLine []string) []string {
+	homeDir, err := os.UserHomeDir()
+
+	if err != nil {
+		return commandLine
+	}
+
+	for i, argument := range commandLine {
+		if strings.HasPrefix(argument, "~/") {
+			commandLine[i] = homeDir + argument[1:]
+		}
+	}
+
+	return commandLine
+}
+
+/**
+ * Return the directory of the project on the host
+ */
+func LocalWorkingDirectory() string {
+	workingDir, err := os.Getwd()
+
+	// Only happens when the directory was removed, relative paths then fail with an explicit error
+	if err != nil {
+		return "."
+	}
+
+	return workingDir
+}
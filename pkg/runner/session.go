@@ -0,0 +1,306 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/logging"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionRunner runs the commands of the runner it wraps through shells started once in its environment, instead of
+// attaching to a container (or starting one) for every command. Commands running at the same time get a shell
+// each, idle shells are reused until Close.
+type SessionRunner struct {
+	CommandRunner
+	mutex sync.Mutex
+	idle  []*session
+	all   []*session
+}
+
+// session is a shell reading commands on its input, each followed by a marker telling its exit code
+type session struct {
+	cmd    *exec.Cmd
+	input  io.WriteCloser
+	output *bufio.Reader
+	errors *bufio.Reader
+	// Unique to the session so that commands can't print it by chance
+	marker   string
+	commands int
+	stopping sync.Once
+}
+
+func WithSessions(commandRunner CommandRunner) *SessionRunner {
+	return &SessionRunner{CommandRunner: commandRunner}
+}
+
+/**
+ * Run the command in an idle shell, or a new one when all are busy. Commands don't read the terminal, a shell is
+ * stopped with its command when the context is cancelled.
+ */
+func (runner *SessionRunner) Run(ctx context.Context, command []string) error {
+	description := runner.describe(command)
+	slog.Log(ctx, getRunningLevel(), "Running", "command", description)
+
+	var output, errorOutput bytes.Buffer
+	start := time.Now()
+	exitCode, err := runner.exec(ctx, withComposerEnv(command), getOutputWriter(slog.LevelInfo, logging.Console(), &output), getOutputWriter(slog.LevelDebug, os.Stderr, &errorOutput))
+
+	return getCommandError(ctx, description, command[0], start, exitCode, err, output.String(), errorOutput.String())
+}
+
+/**
+ * Return the directory commands are run from, by running pwd in a shell
+ */
+func (runner *SessionRunner) WorkingDirectory(ctx context.Context) (string, error) {
+	var output bytes.Buffer
+	_, err := runner.exec(ctx, []string{"pwd"}, &output, io.Discard)
+
+	if err != nil {
+		return "", failure.Wrap(failure.Environment, "find the working directory of "+runner.NonInteractivePrefix(), err)
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
+
+/**
+ * Stop the shells, the next commands start new ones
+ */
+func (runner *SessionRunner) Close() error {
+	runner.mutex.Lock()
+	all := runner.all
+	runner.idle = nil
+	runner.all = nil
+	runner.mutex.Unlock()
+
+	for _, shell := range all {
+		shell.stop()
+	}
+
+	return nil
+}
+
+/**
+ * Run the command in a shell and return its exit code, failing when it isn't 0 like exec.Cmd.Run
+ */
+func (runner *SessionRunner) exec(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) (int, error) {
+	shell, startErr := runner.acquire()
+
+	if startErr != nil {
+		return -1, startErr
+	}
+
+	exitCode, err := shell.run(ctx, command, stdout, stderr)
+
+	if err != nil && exitCode < 0 {
+		// The shell ended or was interrupted along with the command
+		runner.forget(shell)
+		shell.stop()
+
+		return exitCode, err
+	}
+
+	runner.release(shell)
+
+	if exitCode != 0 {
+		return exitCode, errors.New("exit status " + strconv.Itoa(exitCode))
+	}
+
+	return exitCode, nil
+}
+
+func (runner *SessionRunner) acquire() (*session, error) {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	if len(runner.idle) > 0 {
+		shell := runner.idle[len(runner.idle)-1]
+		runner.idle = runner.idle[:len(runner.idle)-1]
+
+		return shell, nil
+	}
+
+	shell, err := startSession(runner.NonInteractivePrefix())
+
+	if err == nil {
+		runner.all = append(runner.all, shell)
+	}
+
+	return shell, err
+}
+
+func (runner *SessionRunner) release(shell *session) {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	runner.idle = append(runner.idle, shell)
+}
+
+func (runner *SessionRunner) forget(shell *session) {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	for i, known := range runner.all {
+		if known == shell {
+			runner.all = append(runner.all[:i], runner.all[i+1:]...)
+			break
+		}
+	}
+}
+
+/**
+ * Return the command line as it would be run without session, for the log and the errors
+ */
+func (runner *SessionRunner) describe(command []string) string {
+	return strings.TrimSpace(runner.NonInteractivePrefix() + " " + strings.Join(command, " "))
+}
+
+/**
+ * Start a shell with the prefix of the environment, e.g. docker compose exec -T php sh
+ */
+func startSession(prefix string) (*session, error) {
+	commandLine := append(expandHome(SplitCommandLine(prefix)), "sh")
+	cmd := exec.Command(commandLine[0], commandLine[1:]...)
+	input, inputErr := cmd.StdinPipe()
+	output, outputErr := cmd.StdoutPipe()
+	errorOutput, errorOutputErr := cmd.StderrPipe()
+
+	if err := errors.Join(inputErr, outputErr, errorOutputErr); err != nil {
+		return nil, failure.Wrap(failure.Environment, "start a shell with "+prefix, err)
+	}
+
+	startErr := cmd.Start()
+
+	if startErr != nil {
+		return nil, failure.Wrap(failure.Environment, "start a shell with "+prefix, startErr)
+	}
+
+	random := make([]byte, 8)
+	rand.Read(random)
+
+	return &session{
+		cmd:    cmd,
+		input:  input,
+		output: bufio.NewReader(output),
+		errors: bufio.NewReader(errorOutput),
+		marker: "__phptooling_" + hex.EncodeToString(random),
+	}, nil
+}
+
+/**
+ * Send the command to the shell and copy its outputs until its markers, the exit code is negative when the shell
+ * ended or the context was cancelled
+ */
+func (shell *session) run(ctx context.Context, command []string, stdout io.Writer, stderr io.Writer) (int, error) {
+	shell.commands++
+	marker := shell.marker + "_" + strconv.Itoa(shell.commands)
+
+	// The command doesn't read the script sent to the shell, the markers follow its outputs on a line of their own
+	script := QuoteCommand(command) + " </dev/null; printf '\\n" + marker + " %d\\n' $?; printf '\\n" + marker + "\\n' >&2\n"
+	_, writeErr := io.WriteString(shell.input, script)
+
+	if writeErr != nil {
+		return -1, failure.Wrap(failure.Environment, "send the command to the shell", writeErr)
+	}
+
+	type result struct {
+		exitCode int
+		err      error
+	}
+
+	results := make(chan result, 2)
+
+	go func() {
+		exitCode, err := copyUntilMarker(shell.output, stdout, marker)
+		results <- result{exitCode, err}
+	}()
+
+	go func() {
+		_, err := copyUntilMarker(shell.errors, stderr, marker)
+		results <- result{0, err}
+	}()
+
+	exitCode := 0
+
+	for received := 0; received < 2; {
+		select {
+		case <-ctx.Done():
+			// Stopping the shell closes its outputs, which ends the copies
+			shell.stop()
+			ctx = context.Background()
+		case next := <-results:
+			received++
+
+			if next.err != nil {
+				return -1, next.err
+			}
+
+			exitCode = max(exitCode, next.exitCode)
+		}
+	}
+
+	return exitCode, nil
+}
+
+/**
+ * Interrupt the shell and its command, it is killed if it doesn't stop in time
+ */
+func (shell *session) stop() {
+	shell.stopping.Do(func() {
+		shell.input.Close()
+		shell.cmd.Process.Signal(os.Interrupt)
+
+		done := make(chan struct{})
+
+		go func() {
+			shell.cmd.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			shell.cmd.Process.Kill()
+		}
+	})
+}
+
+/**
+ * Copy the lines read to the writer until the marker, returning the exit code following it if any
+ */
+func copyUntilMarker(reader *bufio.Reader, writer io.Writer, marker string) (int, error) {
+	// Held back until the next line tells whether it ends with the line break put before the marker
+	pending := ""
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if strings.HasPrefix(line, marker) {
+			io.WriteString(writer, strings.TrimSuffix(pending, "\n"))
+			exitCode, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, marker)))
+
+			return exitCode, nil
+		}
+
+		io.WriteString(writer, pending)
+		pending = line
+
+		if err != nil {
+			io.WriteString(writer, pending)
+
+			return -1, failure.Wrap(failure.Environment, "read the output of the shell", fmt.Errorf("the shell ended: %w", err))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Endpoint receiving the events, set at build time for release binaries (-ldflags "-X ...telemetry.Endpoint=...")
+// and overridden by PHPTOOLING_TELEMETRY_URL, nothing is sent when empty
+var Endpoint = ""
+
+// Consent is the answer to the telemetry prompt, stored for the user so that it is asked once
+type Consent struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Event describes a run without anything identifying the user or the project: no paths, names or hostname
+type Event struct {
+	Command     string   `json:"command"`
+	ExitCode    int      `json:"exit-code"`
+	OS          string   `json:"os"`
+	Arch        string   `json:"arch"`
+	Environment string   `json:"environment"`
+	Docker      bool     `json:"docker"`
+	Framework   string   `json:"framework"`
+	Tools       []string `json:"tools"`
+	Outputs     []string `json:"outputs"`
+	HookManager string   `json:"hook-manager"`
+}
+
+/**
+ * Whether telemetry may be asked for or sent, DO_NOT_TRACK disables it like the flag
+ */
+func Allowed() bool {
+	return os.Getenv("DO_NOT_TRACK") == "" || os.Getenv("DO_NOT_TRACK") == "0"
+}
+
+func consentFile() (string, error) {
+	homeDir, err := os.UserHomeDir()
+
+	return filepath.Join(homeDir, ".config", "phptooling", "telemetry.json"), err
+}
+
+/**
+ * Return the stored consent, the second value is false when the user was never asked
+ */
+func ReadConsent() (Consent, bool) {
+	var consent Consent
+	file, fileErr := consentFile()
+
+	if fileErr != nil {
+		return consent, false
+	}
+
+	data, readErr := os.ReadFile(file)
+
+	if readErr != nil || json.Unmarshal(data, &consent) != nil {
+		return consent, false
+	}
+
+	return consent, true
+}
+
+func SaveConsent(consent Consent) error {
+	file, fileErr := consentFile()
+
+	if fileErr != nil {
+		return failure.Wrap(failure.FileSystem, "find the telemetry consent file", fileErr)
+	}
+
+	mkdirErr := os.MkdirAll(filepath.Dir(file), 0755)
+
+	if mkdirErr != nil {
+		return failure.Wrap(failure.FileSystem, "save the telemetry consent", mkdirErr)
+	}
+
+	data, _ := json.Marshal(consent)
+	writeErr := os.WriteFile(file, data, 0644)
+
+	return failure.Wrap(failure.FileSystem, "save the telemetry consent", writeErr)
+}
+
+/**
+ * Return the event of the command run with the Config
+ */
+func NewEvent(command string, cfg *config.Config, exitCode int) Event {
+	event := Event{
+		Command:     command,
+		ExitCode:    exitCode,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Environment: string(cfg.Environment),
+		Docker:      cfg.Environment == config.DockerCompose || cfg.Environment == config.Ddev,
+		Framework:   string(cfg.Framework),
+		HookManager: string(cfg.Hooks.Manager),
+	}
+
+	for _, tool := range cfg.Tools {
+		event.Tools = append(event.Tools, string(tool))
+	}
+
+	for _, output := range cfg.Outputs {
+		event.Outputs = append(event.Outputs, string(output))
+	}
+
+	return event
+}
+
+/**
+ * Post the event to the endpoint, giving up after a few seconds so that the command is never slowed down
+ */
+func Send(ctx context.Context, event Event) error {
+	endpoint := Endpoint
+
+	if url := os.Getenv("PHPTOOLING_TELEMETRY_URL"); url != "" {
+		endpoint = url
+	}
+
+	if endpoint == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	data, _ := json.Marshal(event)
+	request, requestErr := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+
+	if requestErr != nil {
+		return failure.Wrap(failure.Configuration, "send the telemetry", requestErr)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	response, sendErr := http.DefaultClient.Do(request)
+
+	if sendErr != nil {
+		return failure.Wrap(failure.Environment, "send the telemetry", sendErr)
+	}
+
+	return response.Body.Close()
+}
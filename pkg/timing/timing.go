@@ -0,0 +1,109 @@
+package timing
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/filesystem"
+	"ecohead/phptooling/pkg/tools"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File of the durations measured in the project, relative to it. They depend on the machine, it isn't versioned.
+const FileName = ".phptooling/timings.json"
+
+// Number of durations kept by step or tool, estimates are their average
+const keptDurations = 5
+
+// History holds the last durations of the installation steps and of the runs of the tools in the project
+type History struct {
+	// Durations of the steps of the install command by name, e.g. "PHPStan packages"
+	Install map[string][]time.Duration `json:"install"`
+	// Durations of the tools run on the analysed paths by the report command
+	Runs map[tools.Tool][]time.Duration `json:"runs"`
+}
+
+/**
+ * Read the history of the project, which is empty when it is missing or can't be read as it is only informative
+ */
+func Read(projectDirectory string) *History {
+	history := &History{}
+	data, readErr := os.ReadFile(filepath.Join(projectDirectory, FileName))
+
+	if readErr == nil {
+		_ = json.Unmarshal(data, history)
+	}
+
+	if history.Install == nil {
+		history.Install = make(map[string][]time.Duration)
+	}
+
+	if history.Runs == nil {
+		history.Runs = make(map[tools.Tool][]time.Duration)
+	}
+
+	return history
+}
+
+/**
+ * Write the history to the project
+ */
+func (history *History) Write(projectDirectory string) error {
+	data, _ := json.MarshalIndent(history, "", "  ")
+	file := filepath.Join(projectDirectory, FileName)
+
+	mkdirErr := os.MkdirAll(filepath.Dir(file), 0755)
+
+	if mkdirErr != nil {
+		return failure.Wrap(failure.FileSystem, "write "+FileName, mkdirErr)
+	}
+
+	return failure.Wrap(failure.FileSystem, "write "+FileName, filesystem.WriteAtomically(file, data, 0644))
+}
+
+func (history *History) RecordInstall(step string, duration time.Duration) {
+	record(history.Install, step, duration)
+}
+
+func (history *History) RecordRun(tool tools.Tool, duration time.Duration) {
+	record(history.Runs, tool, duration)
+}
+
+/**
+ * Return the expected duration of the installation step, zero when it never ran
+ */
+func (history *History) EstimateInstall(step string) time.Duration {
+	return average(history.Install[step])
+}
+
+/**
+ * Return the expected duration of a run of the tool on the analysed paths, zero when it never ran
+ */
+func (history *History) EstimateRun(tool tools.Tool) time.Duration {
+	return average(history.Runs[tool])
+}
+
+func record[K comparable](durations map[K][]time.Duration, key K, duration time.Duration) {
+	kept := append(durations[key], duration)
+
+	if len(kept) > keptDurations {
+		kept = kept[len(kept)-keptDurations:]
+	}
+
+	durations[key] = kept
+}
+
+func average(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+
+	for _, duration := range durations {
+		total += duration
+	}
+
+	return total / time.Duration(len(durations))
+}
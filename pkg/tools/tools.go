@@ -0,0 +1,605 @@
+package tools
+
+import (
+	"ecohead/phptooling/pkg/failure"
+	_ "embed"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type Tool string
+
+// Tools of the registry referenced by the wizard and the generated files
+const (
+	PhpCsFixer             Tool = "phpcsfixer"
+	PhpStan                Tool = "phpstan"
+	PhpCS                  Tool = "phpcs"
+	PhpMD                  Tool = "phpmd"
+	PhpCPD                 Tool = "phpcpd"
+	ComposerRequireChecker Tool = "composer-require-checker"
+	Psalm                  Tool = "psalm"
+)
+
+// These checks are not installed in the tools directory, they are only available from git hooks
+const (
+	PhpLint Tool = "php-lint"
+	PhpUnit Tool = "phpunit"
+)
+
+// Git hooks from which a tool is proposed
+const (
+	PreCommitHook = "pre-commit"
+	PrePushHook   = "pre-push"
+)
+
+// Maintenance of a tool no longer maintained, see Definition
+const AbandonedTool = "abandoned"
+
+// Definition describes how a tool is installed, configured and run, see registry.yaml
+type Definition struct {
+	Id   Tool   `yaml:"id"`
+	Name string `yaml:"name"`
+	// One line telling what the tool checks, shown by the wizard
+	Description string `yaml:"description"`
+	// AbandonedTool when the tool gets no more releases, it is still proposed for the projects using it
+	Maintenance string `yaml:"maintenance"`
+	// Frameworks whose projects the wizard recommends the tool for
+	Recommended []string `yaml:"recommended"`
+	// Tools doing part of the same job, the wizard asks which ones to keep when they are selected together
+	Overlaps []Overlap `yaml:"overlaps"`
+	// Versions of PHP the tool runs on, checked against the PHP of the environment before installing it
+	Php []PhpSupport `yaml:"php"`
+	// PHP extensions the tool needs (as listed by php -m), checked before installing it
+	Extensions     []string     `yaml:"extensions"`
+	Binary         string       `yaml:"binary"`
+	Packages       []Package    `yaml:"packages"`
+	CheckArguments string       `yaml:"check_arguments"`
+	DiffArguments  string       `yaml:"diff_arguments"`
+	Hook           string       `yaml:"hook"`
+	Fix            *Fix         `yaml:"fix"`
+	Configs        []ConfigFile `yaml:"configs"`
+	Recipe         string       `yaml:"recipe"`
+	Baseline       *Baseline    `yaml:"baseline"`
+	Phar           *Phar        `yaml:"phar"`
+	Phive          *Phive       `yaml:"phive"`
+	Report         *Report      `yaml:"report"`
+	CI             *CI          `yaml:"ci"`
+	// Ids of the GPG keys signing the phars, whose signatures (<phar URL>.asc) are then checked. PHIVE trusts them
+	// without asking as the installation isn't interactive.
+	SigningKeys []string `yaml:"signing_keys"`
+}
+
+// Overlap is another tool doing part of the job of a tool
+type Overlap struct {
+	Tool Tool `yaml:"tool"`
+	// How to choose between the tools or make them work together
+	Guidance string `yaml:"guidance"`
+}
+
+// SelectedOverlap is an Overlap between two selected tools
+type SelectedOverlap struct {
+	Tool     Tool
+	Other    Tool
+	Guidance string
+}
+
+// PhpSupport is a range of PHP versions (major.minor) a tool runs on, given by its lowest and highest version
+type PhpSupport struct {
+	// Only the versions of the tool below this one are concerned, e.g. 3.65 when it supports PHP 8.4 since 3.65
+	Below string `yaml:"below"`
+	Min   string `yaml:"min"`
+	Max   string `yaml:"max"`
+	// Whether the tool can't be installed at all on the other versions, it is only a warning otherwise
+	Blocking bool `yaml:"blocking"`
+	// What to install instead, e.g. a version supporting the PHP of the environment
+	Guidance string `yaml:"guidance"`
+}
+
+// PhpIncompatibility is a PhpSupport of a selected tool not met by the PHP of the environment
+type PhpIncompatibility struct {
+	Tool     Tool
+	Blocking bool
+	// Why the tool doesn't run on this PHP, e.g. "PHP CPD supports PHP up to 8.2"
+	Reason   string
+	Guidance string
+}
+
+type Package struct {
+	Name       string   `yaml:"name"`
+	Frameworks []string `yaml:"frameworks"`
+	Standards  []string `yaml:"standards"`
+}
+
+// Fix describes how the issues reported on some files are fixed, the binary of the tool is used when none is given
+type Fix struct {
+	Binary    string `yaml:"binary"`
+	Arguments string `yaml:"arguments"`
+	// Exit code meaning that every issue was fixed, when it isn't 0
+	FixedExitCode int `yaml:"fixed_exit_code"`
+}
+
+type ConfigFile struct {
+	Template    string   `yaml:"template"`
+	Destination string   `yaml:"destination"`
+	Frameworks  []string `yaml:"frameworks"`
+}
+
+// Directory of the tools directory where PHIVE copies the phars it installs
+const PhiveDirectory = "phive"
+
+// Phive describes the tool as known by PHIVE (see https://phar.io/)
+type Phive struct {
+	// Name of the tool for PHIVE, e.g. php-cs-fixer
+	Alias string `yaml:"alias"`
+}
+
+// Phar describes the archive downloaded instead of the composer packages when the tool is installed as a phar
+type Phar struct {
+	// Download URL of the latest release, the URL it redirects to is recorded to always download the same release
+	Url string `yaml:"url"`
+	// Download URL of a given release, a template receiving .Version (e.g. 1.12.7). Without it, the version of the
+	// phar can't be pinned.
+	VersionedUrl string `yaml:"versioned_url"`
+	// Name of the file in the directory of the tool
+	File string `yaml:"file"`
+}
+
+// Baseline describes how the errors of the existing code are ignored
+type Baseline struct {
+	File           string `yaml:"file"`
+	InitialContent string `yaml:"initial_content"`
+	Arguments      string `yaml:"arguments"`
+	KeepExisting   bool   `yaml:"keep_existing"`
+	Recipe         string `yaml:"recipe"`
+}
+
+// Report describes how the report command reads the issues of the tool from one of its machine formats
+type Report struct {
+	// Parser of the output: phpstan, psalm, phpcs, phpmd, php-cs-fixer, composer-require-checker, pmd-cpd or
+	// checkstyle for the tools supporting it
+	Format string `yaml:"format"`
+	// Template receiving .Paths and .File
+	Arguments string `yaml:"arguments"`
+	// Whether the tool writes the report to .File, relative to the project, instead of its standard output
+	ToFile bool `yaml:"to_file"`
+}
+
+// Formats of the CI arguments of the tools
+const (
+	// Workflow commands printed by the tool, shown by GitHub Actions as annotations of the changed lines
+	GitHubFormat = "github"
+	// Checkstyle XML, converted to annotations by cs2pr and kept as an artifact
+	CheckstyleFormat = "checkstyle"
+)
+
+// CI describes how the tool reports its issues so that CI servers annotate the code with them
+type CI struct {
+	// github or checkstyle
+	Format string `yaml:"format"`
+	// Template receiving .Paths, as check_arguments
+	Arguments string `yaml:"arguments"`
+	// Followed by the files to check, as diff_arguments
+	DiffArguments string `yaml:"diff_arguments"`
+}
+
+//go:embed registry.yaml
+var registryData []byte
+
+var (
+	registry []Definition
+	// Available lists the tools which can be installed in the tools directory, in the order they are proposed
+	Available []Tool
+)
+
+func init() {
+	parseErr := yaml.UnmarshalStrict(registryData, &registry)
+
+	// The registry is embedded, it can only be invalid in a development build
+	if parseErr != nil {
+		panic(parseErr)
+	}
+
+	for _, definition := range registry {
+		Available = append(Available, definition.Id)
+	}
+}
+
+/**
+ * Return the definition of the tool, the second value is false if it isn't in the registry
+ */
+func Get(tool Tool) (Definition, bool) {
+	for _, definition := range registry {
+		if definition.Id == tool {
+			return definition, true
+		}
+	}
+
+	return Definition{}, false
+}
+
+/**
+ * Return the overlaps between the selected tools, once for each pair whichever of them declares it
+ */
+func FindOverlaps(selected []Tool) []SelectedOverlap {
+	var overlaps []SelectedOverlap
+
+	for _, tool := range selected {
+		definition, _ := Get(tool)
+
+		for _, overlap := range definition.Overlaps {
+			found := slices.ContainsFunc(overlaps, func(selectedOverlap SelectedOverlap) bool {
+				return selectedOverlap.Tool == overlap.Tool && selectedOverlap.Other == tool
+			})
+
+			if slices.Contains(selected, overlap.Tool) && !found {
+				overlaps = append(overlaps, SelectedOverlap{tool, overlap.Tool, overlap.Guidance})
+			}
+		}
+	}
+
+	return overlaps
+}
+
+/**
+ * Return the PHP versions the selected tools don't run on, for the major.minor PHP version of the environment. The
+ * lowest version allowed by the version constraint of each tool (e.g. 3.40 for ^3.40), if any, tells which ranges
+ * with a below version apply, they are ignored for the tools installed in their latest version.
+ */
+func FindPhpIncompatibilities(selected []Tool, phpVersion string, toolVersions map[Tool]string) []PhpIncompatibility {
+	var incompatibilities []PhpIncompatibility
+	current, parsed := parseVersion(phpVersion)
+
+	if !parsed {
+		return nil
+	}
+
+	for _, tool := range selected {
+		definition, _ := Get(tool)
+
+		for _, support := range definition.Php {
+			if support.Below != "" {
+				toolVersion, toolParsed := parseVersion(toolVersions[tool])
+				below, belowParsed := parseVersion(support.Below)
+
+				if !toolParsed || !belowParsed || toolVersion >= below {
+					continue
+				}
+			}
+
+			reason := ""
+
+			if minimum, found := parseVersion(support.Min); found && current < minimum {
+				reason = definition.Name + " needs PHP " + support.Min + " or later"
+			} else if maximum, found := parseVersion(support.Max); found && current > maximum {
+				reason = definition.Name + " supports PHP up to " + support.Max
+			}
+
+			if reason == "" {
+				continue
+			}
+
+			if support.Below != "" {
+				reason += " before " + support.Below
+			}
+
+			incompatibilities = append(incompatibilities, PhpIncompatibility{tool, support.Blocking, reason, support.Guidance})
+		}
+	}
+
+	return incompatibilities
+}
+
+/**
+ * Return a comparable number for a major.minor version, e.g. 80003 for 8.3. False when it isn't a version.
+ */
+func parseVersion(version string) (int, bool) {
+	var major, minor int
+
+	_, err := fmt.Sscanf(version, "%d.%d", &major, &minor)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return major*10000 + minor, true
+}
+
+func Name(tool Tool) string {
+	switch tool {
+	case PhpLint:
+		return "PHP lint"
+	case PhpUnit:
+		return "PHPUnit"
+	}
+
+	if definition, found := Get(tool); found {
+		return definition.Name
+	}
+
+	return string(tool)
+}
+
+/**
+ * Return the binary of the tool, relative to the tools directory (each tool is installed in a directory named after it)
+ */
+func Binary(tool Tool) string {
+	definition, _ := Get(tool)
+
+	return definition.Binary
+}
+
+/**
+ * Return the phar of the tool relative to the tools directory
+ */
+func (definition Definition) PhiveBinary() string {
+	return PhiveDirectory + "/" + definition.Phive.Alias
+}
+
+/**
+ * Whether the tool can be installed with PHIVE, under the same conditions as phars
+ */
+func (definition Definition) SupportsPhive(framework string, standard string) bool {
+	return definition.Phive != nil && len(definition.PackageNames(framework, standard)) == 1
+}
+
+func (definition Definition) PharBinary() string {
+	return string(definition.Id) + "/" + definition.Phar.File
+}
+
+/**
+ * Whether the tool can be installed as a phar for the framework and the PHP_CodeSniffer standard of the project,
+ * extensions such as larastan are only available with composer
+ */
+func (definition Definition) SupportsPhar(framework string, standard string) bool {
+	return definition.Phar != nil && len(definition.PackageNames(framework, standard)) == 1
+}
+
+/**
+ * Return the arguments used to run the tool on the analysed paths in check mode (i.e. without fixing anything)
+ */
+func CheckArguments(tool Tool, paths []string) (string, error) {
+	definition, _ := Get(tool)
+
+	return Render(definition.CheckArguments, struct{ Paths []string }{paths})
+}
+
+/**
+ * Return the arguments used to run the tool on the analysed paths in CI along with the format of their output, the
+ * check arguments without any format when the tool has no CI format
+ */
+func CIArguments(tool Tool, paths []string) (string, string, error) {
+	definition, _ := Get(tool)
+
+	if definition.CI == nil {
+		arguments, err := CheckArguments(tool, paths)
+
+		return arguments, "", err
+	}
+
+	arguments, err := Render(definition.CI.Arguments, struct{ Paths []string }{paths})
+
+	return arguments, definition.CI.Format, err
+}
+
+/**
+ * Same as CIArguments for a list of files appended afterward, see DiffArguments
+ */
+func CIDiffArguments(tool Tool) (string, string) {
+	definition, _ := Get(tool)
+
+	if definition.CI == nil || definition.CI.DiffArguments == "" {
+		return DiffArguments(tool), ""
+	}
+
+	return definition.CI.DiffArguments, definition.CI.Format
+}
+
+/**
+ * Return the arguments used to run the tool in check mode on a list of files appended afterward,
+ * or an empty string if the tool can't be restricted to some files
+ */
+func DiffArguments(tool Tool) string {
+	definition, _ := Get(tool)
+
+	return definition.DiffArguments
+}
+
+/**
+ * Return the tools which can be restricted to a list of files
+ */
+func DiffTools(selected []Tool) []Tool {
+	var diffTools []Tool
+
+	for _, tool := range selected {
+		if DiffArguments(tool) != "" {
+			diffTools = append(diffTools, tool)
+		}
+	}
+
+	return diffTools
+}
+
+/**
+ * Return the composer packages to install for the framework and the PHP_CodeSniffer standard of the project
+ */
+func (definition Definition) PackageNames(framework string, standard string) []string {
+	var names []string
+
+	for _, composerPackage := range definition.Packages {
+		if len(composerPackage.Frameworks) > 0 && !slices.Contains(composerPackage.Frameworks, framework) {
+			continue
+		}
+
+		if len(composerPackage.Standards) > 0 && !slices.Contains(composerPackage.Standards, standard) {
+			continue
+		}
+
+		names = append(names, composerPackage.Name)
+	}
+
+	return names
+}
+
+/**
+ * Return the config files to generate for the framework of the project
+ */
+func (definition Definition) ConfigFiles(framework string) []ConfigFile {
+	var files []ConfigFile
+
+	for _, file := range definition.Configs {
+		if len(file.Frameworks) == 0 || slices.Contains(file.Frameworks, framework) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+/**
+ * Render an argument or recipe template of the registry
+ */
+func Render(text string, data interface{}) (string, error) {
+	tmpl, parseErr := template.New("registry").Delims("[[", "]]").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+
+	if parseErr != nil {
+		return "", failure.Wrap(failure.Configuration, "parse the tool template "+strconv.Quote(text), parseErr)
+	}
+
+	var content strings.Builder
+
+	executeErr := tmpl.Execute(&content, data)
+
+	if executeErr != nil {
+		return "", failure.Wrap(failure.Configuration, "render the tool template "+strconv.Quote(text), executeErr)
+	}
+
+	return content.String(), nil
+}
+
+/**
+ * Return the tools already installed in the tools directory of the project
+ */
+func DetectInstalled(projectDirectory string, toolsDirectory string) []Tool {
+	var installedTools []Tool
+
+	for _, tool := range Available {
+		_, err := os.Stat(filepath.Join(projectDirectory, toolsDirectory, string(tool), "vendor"))
+
+		if definition, _ := Get(tool); err != nil && definition.Phar != nil {
+			_, err = os.Stat(filepath.Join(projectDirectory, toolsDirectory, definition.PharBinary()))
+		}
+
+		if definition, _ := Get(tool); err != nil && definition.Phive != nil {
+			_, err = os.Stat(filepath.Join(projectDirectory, toolsDirectory, definition.PhiveBinary()))
+		}
+
+		if err == nil {
+			installedTools = append(installedTools, tool)
+		}
+	}
+
+	return installedTools
+}
+
+/**
+ * Return the directories from which tool definitions are loaded in addition to the registry, by order of precedence
+ */
+func PluginDirectories(projectDirectory string) []string {
+	directories := []string{filepath.Join(projectDirectory, ".phptooling", "tools")}
+
+	homeDir, err := os.UserHomeDir()
+
+	if err == nil {
+		directories = append(directories, filepath.Join(homeDir, ".config", "phptooling", "tools"))
+	}
+
+	return directories
+}
+
+/**
+ * Add the tools defined in the .yaml, .yml and .json files of the directories to the registry, each file holding
+ * one definition or a list of definitions in the format of registry.yaml. Config templates are relative to the file
+ * declaring them, a tool already defined by the registry or a directory of higher precedence is ignored
+ */
+func LoadPlugins(directories []string) error {
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			extension := path.Ext(entry.Name())
+
+			if entry.IsDir() || (extension != ".yaml" && extension != ".yml" && extension != ".json") {
+				continue
+			}
+
+			definitions, err := readPlugin(filepath.Join(directory, entry.Name()))
+
+			if err != nil {
+				return err
+			}
+
+			for _, definition := range definitions {
+				if _, found := Get(definition.Id); found {
+					continue
+				}
+
+				registry = append(registry, definition)
+				Available = append(Available, definition.Id)
+			}
+		}
+	}
+
+	return nil
+}
+
+func readPlugin(file string) ([]Definition, error) {
+	data, readErr := os.ReadFile(file)
+
+	if readErr != nil {
+		return nil, failure.Wrap(failure.FileSystem, "read "+file, readErr)
+	}
+
+	var definitions []Definition
+
+	// JSON documents are valid YAML
+	if parseErr := yaml.UnmarshalStrict(data, &definitions); parseErr != nil {
+		var definition Definition
+
+		singleErr := yaml.UnmarshalStrict(data, &definition)
+
+		if singleErr != nil {
+			return nil, failure.Wrap(failure.Configuration, "parse the tool definition "+file, singleErr)
+		}
+
+		definitions = []Definition{definition}
+	}
+
+	for i, definition := range definitions {
+		if definition.Id == "" || definition.Binary == "" {
+			return nil, failure.New(failure.Configuration, "parse the tool definition "+file, "id and binary are required")
+		}
+
+		if definition.Name == "" {
+			definitions[i].Name = string(definition.Id)
+		}
+
+		for j, configFile := range definition.Configs {
+			if !filepath.IsAbs(configFile.Template) {
+				definitions[i].Configs[j].Template = filepath.Join(filepath.Dir(file), configFile.Template)
+			}
+		}
+	}
+
+	return definitions, nil
+}
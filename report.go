@@ -0,0 +1,335 @@
+package phptooling
+
+import (
+	"context"
+	"ecohead/phptooling/pkg/config"
+	"ecohead/phptooling/pkg/failure"
+	"ecohead/phptooling/pkg/generator"
+	"ecohead/phptooling/pkg/lock"
+	"ecohead/phptooling/pkg/logging"
+	"ecohead/phptooling/pkg/report"
+	"ecohead/phptooling/pkg/runner"
+	"ecohead/phptooling/pkg/timing"
+	"ecohead/phptooling/pkg/tools"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var phpStanLevelPattern = regexp.MustCompile(`(?m)^\s+level:\s*(\w+)\s*$`)
+
+/**
+ * Run the tools installed in the project with their machine formats and write the issues they found to the report
+ * of the Config in its format (a directory of badges for the badges format). The webhooks of the Config are notified,
+ * SARIF logs are uploaded to GitHub code scanning and a summary is commented on the pull or merge request when asked.
+ * Issues only fail the command when asked and found by blocking tools, tools without a machine format are left out.
+ */
+func Report(ctx context.Context, cfg *Config) error {
+	projectDirectory := runner.LocalWorkingDirectory()
+	projectLock, locked, lockErr := lock.Read(projectDirectory)
+
+	if lockErr != nil {
+		return lockErr
+	}
+
+	if !locked {
+		return failure.New(failure.Configuration, "report", "no tool was installed by phptooling in this project, run phptooling install first")
+	}
+
+	restoreInstall(cfg, projectLock)
+	g, err := newGenerator(ctx, cfg)
+
+	if err != nil {
+		return err
+	}
+
+	defer g.Close()
+
+	var results []report.Result
+	history := timing.Read(projectDirectory)
+
+	for _, tool := range cfg.Tools {
+		definition, _ := tools.Get(tool)
+
+		if definition.Report == nil {
+			slog.Info(definition.Name + " has no machine format, it is left out of the report")
+			continue
+		}
+
+		slog.Info("Running " + definition.Name)
+		start := time.Now()
+		result, runErr := runReportedTool(g, definition, projectLock.Tools[tool])
+
+		if runErr != nil {
+			return runErr
+		}
+
+		duration := time.Since(start)
+		history.RecordRun(tool, duration)
+		slog.Info(definition.Name+" found "+strconv.Itoa(len(result.Issues))+" issues", "tool", tool, "duration", duration.Round(time.Millisecond))
+		logging.Event("report", "tool", tool, "issues", len(result.Issues), "duration_ms", duration.Milliseconds())
+		results = append(results, result)
+	}
+
+	// Shown next to the tools proposed for the git hooks
+	historyErr := history.Write(projectDirectory)
+
+	if historyErr != nil {
+		slog.Debug("Could not record the durations of the tools", "error", historyErr)
+	}
+
+	writeErr := writeReport(projectDirectory, cfg.Report, results)
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	for _, notification := range cfg.Report.Notifications {
+		notifyErr := report.Notify(ctx, notification.Type, notification.Url, "Quality report of "+filepath.Base(projectDirectory), results)
+
+		if notifyErr != nil {
+			return notifyErr
+		}
+
+		slog.Info("Notified " + notification.Type)
+	}
+
+	if cfg.Report.Comment {
+		commentErr := report.PostComment(ctx, report.Markdown(results))
+
+		if commentErr != nil {
+			return commentErr
+		}
+
+		slog.Info("Posted the summary of the report on the pull request")
+	}
+
+	if cfg.Report.Upload {
+		uploadErr := report.UploadToCodeScanning(ctx, report.Sarif(results))
+
+		if uploadErr != nil {
+			return uploadErr
+		}
+
+		slog.Info("Uploaded the report to GitHub code scanning")
+	}
+
+	if cfg.Report.FailOnIssues {
+		return getBlockingIssuesError(cfg, results)
+	}
+
+	return nil
+}
+
+/**
+ * Return an error listing the blocking tools which found issues, nil when all of them passed. The issues of advisory
+ * tools are only reported.
+ */
+func getBlockingIssuesError(cfg *Config, results []report.Result) error {
+	var failed []string
+
+	for _, result := range results {
+		if len(result.Issues) == 0 {
+			continue
+		}
+
+		if cfg.IsAdvisory(result.Tool) {
+			slog.Info(tools.Name(result.Tool) + " is advisory, its issues don't fail the report")
+			continue
+		}
+
+		failed = append(failed, tools.Name(result.Tool))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return failure.New(failure.Issues, "report", "blocking tools found issues: "+strings.Join(failed, ", "))
+}
+
+/**
+ * Write the results to the file of the report in its format
+ */
+func writeReport(projectDirectory string, options config.ReportConfig, results []report.Result) error {
+	if options.Format == config.BadgesReport {
+		return writeBadges(projectDirectory, options.File, results)
+	}
+
+	var data []byte
+	var formatErr error
+
+	switch options.Format {
+	case config.SarifReport:
+		data = report.Sarif(results)
+	case config.JUnitReport:
+		data = report.JUnit(results)
+	case config.HTMLReport:
+		data, formatErr = report.HTML(results)
+	}
+
+	if formatErr != nil {
+		return failure.Wrap(failure.Unknown, "write the "+string(options.Format)+" report", formatErr)
+	}
+
+	writeErr := os.WriteFile(filepath.Join(projectDirectory, options.File), data, 0644)
+
+	if writeErr != nil {
+		return failure.Wrap(failure.FileSystem, "write "+options.File, writeErr)
+	}
+
+	slog.Info("Wrote the report to "+options.File, "issues", report.Count(results))
+
+	return nil
+}
+
+/**
+ * Write a shields.io endpoint file per badge of the results in the directory, along with the level of PHPStan when it
+ * was run
+ */
+func writeBadges(projectDirectory string, directory string, results []report.Result) error {
+	badges := report.Badges(results)
+
+	for _, result := range results {
+		if result.Tool != tools.PhpStan {
+			continue
+		}
+
+		if level := readPhpStanLevel(projectDirectory); level != "" {
+			badges["phpstan-level"] = report.PhpStanLevelBadge(level)
+		}
+	}
+
+	mkdirErr := os.MkdirAll(filepath.Join(projectDirectory, directory), 0755)
+
+	if mkdirErr != nil {
+		return failure.Wrap(failure.FileSystem, "create "+directory, mkdirErr)
+	}
+
+	for name, badge := range badges {
+		file := path.Join(directory, name+".json")
+		writeErr := os.WriteFile(filepath.Join(projectDirectory, file), badge.JSON(), 0644)
+
+		if writeErr != nil {
+			return failure.Wrap(failure.FileSystem, "write "+file, writeErr)
+		}
+	}
+
+	slog.Info("Wrote "+strconv.Itoa(len(badges))+" badges to "+directory, "issues", report.Count(results))
+
+	return nil
+}
+
+/**
+ * Return the rule level set in phpstan.neon, empty when it can't be read
+ */
+func readPhpStanLevel(projectDirectory string) string {
+	content, err := os.ReadFile(filepath.Join(projectDirectory, "phpstan.neon"))
+
+	if err != nil {
+		return ""
+	}
+
+	if match := phpStanLevelPattern.FindSubmatch(content); match != nil {
+		return string(match[1])
+	}
+
+	return ""
+}
+
+/**
+ * Set how the tools recorded in the lock were installed in the Config, for the commands running them
+ */
+func restoreInstall(cfg *Config, projectLock *lock.Lock) {
+	cfg.ToolsDirectory = projectLock.ToolsDirectory
+	cfg.Tools = projectLock.InstalledTools()
+
+	for _, tool := range cfg.Tools {
+		if installed := projectLock.Tools[tool]; installed.Global {
+			cfg.GlobalTools = append(cfg.GlobalTools, tool)
+		} else if installed.RequireDev {
+			cfg.InstallMethod = config.RequireDevInstall
+		}
+	}
+}
+
+/**
+ * Run the tool with its machine format and return the issues it found, an output which can't be read means that the
+ * tool failed
+ */
+func runReportedTool(g *generator.Generator, definition tools.Definition, installed lock.Tool) (report.Result, error) {
+	result := report.Result{Tool: definition.Id}
+	binary, binaryErr := g.ToolBinary(definition.Id, installed.Binary(definition))
+
+	if binaryErr != nil {
+		return result, binaryErr
+	}
+
+	// In the cache directory, which is ignored by git
+	reportFile := path.Join(g.RelativeCacheDirectory(), string(definition.Id)+"-report")
+	var quotedPaths []string
+
+	for _, analysed := range g.Config.Paths {
+		quotedPaths = append(quotedPaths, runner.QuoteArgument(analysed))
+	}
+
+	// The values are quoted so that the rendered arguments are split like the shell of the recipes would
+	arguments, renderErr := tools.Render(definition.Report.Arguments, struct {
+		Paths []string
+		File  string
+	}{quotedPaths, runner.QuoteArgument(reportFile)})
+
+	if renderErr != nil {
+		return result, renderErr
+	}
+
+	if definition.Report.ToFile {
+		mkdirErr := g.Files.MkdirAll(g.RelativeCacheDirectory(), 0755)
+
+		if mkdirErr != nil {
+			return result, failure.Classify(failure.FileSystem, "create "+g.RelativeCacheDirectory(), mkdirErr)
+		}
+
+		defer g.Files.Remove(reportFile)
+	}
+
+	captured, runErr := g.Capture(append([]string{"php", binary}, runner.SplitCommandLine(arguments)...))
+
+	if runErr != nil {
+		return result, runErr
+	}
+
+	result.Output = captured.Output
+
+	if definition.Report.ToFile {
+		// The tool failed before writing it when it is missing
+		output, _ := g.Files.ReadFile(reportFile)
+		result.Output = string(output)
+	}
+
+	workingDir, workingDirErr := g.WorkingDirectory()
+
+	if workingDirErr != nil {
+		return result, workingDirErr
+	}
+
+	issues, parseErr := report.Parse(definition.Report.Format, []byte(result.Output), workingDir)
+
+	if failure.KindOf(parseErr) == failure.Command {
+		return result, &failure.Error{
+			Kind:      failure.Command,
+			Operation: definition.Name + " failed with exit code " + strconv.Itoa(captured.ExitCode),
+			Err:       parseErr,
+			Output:    strings.TrimSpace(captured.Output + captured.ErrorOutput),
+		}
+	}
+
+	result.Issues = issues
+
+	return result, parseErr
+}